@@ -28,6 +28,9 @@ Options:
   General:
     --version, -v
       show binary release number.
+    --error-format <fmt>
+      write errors to stderr as 'text' or 'json'.
+      default: text.
     --help, -h
       show help.
 
@@ -123,7 +126,10 @@ func main() {
 
 	handleCompletions()
 
+	if err := lib.InitErrorFormat(); err != nil {
+		lib.ExitWithError(err)
+	}
 	if err := cmd(); err != nil {
-		fmt.Fprintf(os.Stderr, "err: %v\n", err)
+		lib.ExitWithError(err)
 	}
 }
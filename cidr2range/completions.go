@@ -9,6 +9,7 @@ var completions = &complete.Command{
 	Flags: map[string]complete.Predictor{
 		"-v":                    predict.Nothing,
 		"--version":             predict.Nothing,
+		"--error-format":        predict.Set([]string{"text", "json"}),
 		"-h":                    predict.Nothing,
 		"--help":                predict.Nothing,
 		"--completions-install": predict.Nothing,
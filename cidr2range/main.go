@@ -45,6 +45,9 @@ Options:
   General:
     --version, -v
       show binary release number.
+    --error-format <fmt>
+      write errors to stderr as 'text' or 'json'.
+      default: text.
     --help, -h
       show help.
 
@@ -140,7 +143,10 @@ func main() {
 
 	handleCompletions()
 
+	if err := lib.InitErrorFormat(); err != nil {
+		lib.ExitWithError(err)
+	}
 	if err := cmd(); err != nil {
-		fmt.Fprintf(os.Stderr, "err: %v\n", err)
+		lib.ExitWithError(err)
 	}
 }
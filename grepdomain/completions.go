@@ -9,6 +9,7 @@ var completions = &complete.Command{
 	Flags: map[string]complete.Predictor{
 		"-o":                    predict.Nothing,
 		"--only-matching":       predict.Nothing,
+		"--error-format":        predict.Set([]string{"text", "json"}),
 		"-h":                    predict.Nothing,
 		"--no-filename":         predict.Nothing,
 		"--no-recurse":          predict.Nothing,
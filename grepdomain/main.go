@@ -28,6 +28,9 @@ Options:
       don't recurse into more directories in directory sources.
     --version
       show binary release number.
+    --error-format <fmt>
+      write errors to stderr as 'text' or 'json'.
+      default: text.
     --help
       show help.
 
@@ -130,7 +133,10 @@ func main() {
 
 	handleCompletions()
 
+	if err := lib.InitErrorFormat(); err != nil {
+		lib.ExitWithError(err)
+	}
 	if err := cmd(); err != nil {
-		fmt.Fprintf(os.Stderr, "err: %v\n", err)
+		lib.ExitWithError(err)
 	}
 }
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ipinfo/cli/lib"
+	"github.com/ipinfo/go/v2/ipinfo"
+)
+
+// the defaults for the batch flags.
+const (
+	defaultBatchConcurrency = 20
+	defaultBatchTimeout     = 30 * time.Minute
+)
+
+// batchReqOpts returns the batch request options set by the --concurrency,
+// --batch-size and --timeout flags, falling back to the config and then to
+// the defaults.
+func batchReqOpts() (ipinfo.BatchReqOpts, error) {
+	concurrency := fConcurrency
+	if concurrency == 0 {
+		concurrency = gConfig.Concurrency
+	}
+	if concurrency == 0 {
+		concurrency = defaultBatchConcurrency
+	}
+	if concurrency < 0 {
+		return ipinfo.BatchReqOpts{}, lib.UsageErrorf("concurrency must be positive")
+	}
+
+	batchSize := fBatchSize
+	if batchSize == 0 {
+		batchSize = gConfig.BatchSize
+	}
+	if batchSize == 0 {
+		batchSize = batchMaxSize
+	}
+	if batchSize < 0 || batchSize > batchMaxSize {
+		return ipinfo.BatchReqOpts{}, lib.UsageErrorf("batch size must be between 1 and %d", batchMaxSize)
+	}
+
+	timeout := fTimeout
+	if timeout == 0 && gConfig.Timeout != "" {
+		var err error
+		timeout, err = time.ParseDuration(gConfig.Timeout)
+		if err != nil {
+			return ipinfo.BatchReqOpts{}, fmt.Errorf("invalid config timeout: %w", err)
+		}
+	}
+	if timeout == 0 {
+		timeout = defaultBatchTimeout
+	}
+	if timeout < 0 {
+		return ipinfo.BatchReqOpts{}, lib.UsageErrorf("timeout must be positive")
+	}
+
+	if fRate < 0 {
+		return ipinfo.BatchReqOpts{}, lib.UsageErrorf("rate must be positive")
+	}
+
+	return ipinfo.BatchReqOpts{
+		BatchSize:                    uint32(batchSize),
+		TimeoutPerBatch:              int64(math.Ceil(timeout.Seconds())),
+		ConcurrentBatchRequestsLimit: concurrency,
+	}, nil
+}
+
+// returns the max requests per second set by the --rate flag or the config,
+// or 0 for no limit.
+func requestRate() float64 {
+	if fRate > 0 {
+		return fRate
+	}
+	return gConfig.Rate
+}
+
+// rateLimitTransport spaces out requests so that at most `rate` are sent per
+// second, smoothing the bursts of concurrent batch requests.
+type rateLimitTransport struct {
+	base     http.RoundTripper
+	interval time.Duration
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+// newRateLimitTransport returns a transport sending at most `rate` requests
+// per second through `base`.
+func newRateLimitTransport(base http.RoundTripper, rate float64) *rateLimitTransport {
+	return &rateLimitTransport{
+		base:     base,
+		interval: time.Duration(float64(time.Second) / rate),
+	}
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.wait(req.Context()); err != nil {
+		return nil, err
+	}
+	return t.base.RoundTrip(req)
+}
+
+// waits for the next free slot to send a request in.
+func (t *rateLimitTransport) wait(ctx context.Context) error {
+	t.mu.Lock()
+	now := time.Now()
+	at := t.next
+	if at.Before(now) {
+		at = now
+	}
+	t.next = at.Add(t.interval)
+	t.mu.Unlock()
+
+	wait := time.Until(at)
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
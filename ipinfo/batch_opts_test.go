@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Flags take precedence over the config, which takes precedence over the
+// defaults.
+func TestBatchReqOpts(t *testing.T) {
+	defer func() {
+		fConcurrency, fBatchSize, fTimeout = 0, 0, 0
+		gConfig = Config{}
+	}()
+
+	opts, err := batchReqOpts()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if opts.ConcurrentBatchRequestsLimit != 20 || opts.BatchSize != 1000 || opts.TimeoutPerBatch != 30*60 {
+		t.Errorf("unexpected defaults: %+v", opts)
+	}
+
+	gConfig = Config{Concurrency: 4, BatchSize: 100, Timeout: "1m"}
+	fBatchSize, fTimeout = 10, 1500*time.Millisecond
+	opts, err = batchReqOpts()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if opts.ConcurrentBatchRequestsLimit != 4 || opts.BatchSize != 10 || opts.TimeoutPerBatch != 2 {
+		t.Errorf("unexpected options: %+v", opts)
+	}
+
+	fBatchSize = 1001
+	if _, err := batchReqOpts(); err == nil {
+		t.Errorf("expected an error for a batch size over the maximum")
+	}
+}
+
+// Requests are spaced out to the rate, however many are sent at once.
+func TestRateLimitTransport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	c := &http.Client{Transport: newRateLimitTransport(http.DefaultTransport, 50)}
+	start := time.Now()
+	done := make(chan error)
+	for i := 0; i < 6; i++ {
+		go func() {
+			resp, err := c.Get(srv.URL)
+			if err == nil {
+				resp.Body.Close()
+			}
+			done <- err
+		}()
+	}
+	for i := 0; i < 6; i++ {
+		if err := <-done; err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	// the first request is sent right away, then one every 20ms.
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("expected at least 100ms for 6 requests at 50/s, took %v", elapsed)
+	}
+}
@@ -6,7 +6,8 @@ import (
 	"fmt"
 	"path/filepath"
 	"reflect"
-	"sort"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ipinfo/go/v2/ipinfo"
@@ -23,7 +24,31 @@ const (
 var II_CACHE_BUCKET []byte = []byte("ii")
 
 type BoltdbCache struct {
-	db *bbolt.DB
+	path   string
+	policy cachePolicy
+
+	// the db while it's in use, which is closed when it's idle so that other
+	// processes can use it too; see `view` and `update`.
+	//
+	// `dbMu` is read-locked by transactions, so they run concurrently, and
+	// locked by opening and closing the db.
+	dbMu        sync.RWMutex
+	db          *bbolt.DB
+	lastUse     atomic.Int64
+	idleTimer   *time.Timer
+	openTimeout time.Duration
+
+	// whether the db couldn't be locked for writing, and the error if it
+	// couldn't be locked at all.
+	readOnly bool
+	locked   error
+
+	// the accesses not yet written to the db, which are written once there
+	// are `flushSize` of them, after a while, or on close.
+	mu        sync.Mutex
+	pending   cacheAccesses
+	lastFlush time.Time
+	flushSize int
 }
 
 type CacheItem struct {
@@ -61,33 +86,48 @@ func BoltdbCachePath() (string, error) {
 	return filepath.Join(confDir, "cache.boltdb"), nil
 }
 
-// Create a new Boltdb-based cache.
-func NewBoltdbCache() (*BoltdbCache, error) {
+// Create a new Boltdb-based cache, which keeps values as long as `policy`
+// says.
+//
+// The db is only opened while it's in use, so other processes can use it at
+// the same time.
+func NewBoltdbCache(policy cachePolicy) (*BoltdbCache, error) {
 	// get path to database file.
 	path, err := BoltdbCachePath()
 	if err != nil {
 		return nil, err
 	}
 
-	// open db.
-	db, err := bbolt.Open(path, 0660, nil)
-	if err != nil {
-		return nil, fmt.Errorf("error opening database: %w", err)
+	c := &BoltdbCache{
+		path:        path,
+		policy:      policy,
+		openTimeout: cacheOpenTimeout,
+		pending:     cacheAccesses{lats: make(map[string]time.Time)},
+		lastFlush:   time.Now(),
+		flushSize:   cacheAccessFlushSize,
 	}
 
-	// creating root bucket.
-	err = db.Update(func(t *bbolt.Tx) error {
-		_, err := t.CreateBucketIfNotExists(II_CACHE_BUCKET)
-		if err != nil {
-			return fmt.Errorf("error creating root db bucket: %w", err)
-		}
-		return nil
-	})
-	if err != nil {
-		return nil, fmt.Errorf("error setting up db: %w", err)
+	// make sure the db can be opened, if other processes aren't using it.
+	err = c.view(func(t *bbolt.Tx) error { return nil })
+	if err != nil && !cacheUnavailable(err) {
+		return nil, err
 	}
+	return c, nil
+}
 
-	return &BoltdbCache{db: db}, nil
+// Closes the cache's database, after writing the accesses not yet written.
+func (c *BoltdbCache) Close() error {
+	err := c.Flush()
+
+	c.dbMu.Lock()
+	defer c.dbMu.Unlock()
+	if c.idleTimer != nil {
+		c.idleTimer.Stop()
+	}
+	if cerr := c.closeDB(); err == nil {
+		err = cerr
+	}
+	return err
 }
 
 // Gets the value associated with `key` in the cache.
@@ -96,7 +136,8 @@ func NewBoltdbCache() (*BoltdbCache, error) {
 func (c *BoltdbCache) Get(key string) (interface{}, error) {
 	var i interface{}
 	var created time.Time
-	err := c.db.View(func(t *bbolt.Tx) error {
+	var vtype byte
+	err := c.view(func(t *bbolt.Tx) error {
 		val := t.Bucket(II_CACHE_BUCKET).Get([]byte(key))
 		if val == nil {
 			return errors.New("key does not exist")
@@ -104,24 +145,31 @@ func (c *BoltdbCache) Get(key string) (interface{}, error) {
 
 		var err error
 		_, created, i, err = c.decode(val)
+		vtype = val[len(val)-1]
 		return err
 	})
 	if err != nil {
+		c.recordAccess("", time.Time{})
 		return nil, err
 	}
 
 	// update val.
-	if time.Since(created) > 24*time.Hour {
+	if time.Since(created) > c.policy.ttl(vtype) {
 		// has it expired? if so, delete it and return as if we don't have it.
-		err := c.del(key)
-		if err == nil {
+		err := c.update(func(t *bbolt.Tx) error {
+			if err := delCacheEntry(t, []byte(key)); err != nil {
+				return fmt.Errorf("something went wrong while deleting cache: %w", err)
+			}
+			return incrCacheStat(t, II_CACHE_STAT_MISSES)
+		})
+		if err == nil || cacheUnavailable(err) {
 			err = errors.New("key does not exist")
 		}
 		return nil, err
 	}
 
-	// update the same item with an updated last-accessed.
-	c.set(key, CacheItem{LastAccessed: time.Now(), Created: created}, i)
+	// update the item's last-accessed, along with those of other hits.
+	c.recordAccess(key, time.Now())
 	return i, nil
 }
 
@@ -129,15 +177,18 @@ func (c *BoltdbCache) Get(key string) (interface{}, error) {
 //
 // This implements `Set` from the IPinfo Go SDK cache interface.
 func (c *BoltdbCache) Set(key string, val interface{}) error {
-	// if the cache is too full, delete a bunch of stuff in bulk before
-	// proceeding.
-	if c.isTooFull() {
-		c.delBulk()
-	}
+	// if the cache is too full, evict the least recently accessed entries
+	// before proceeding.
+	c.evictIfTooFull()
 
-	// set value.
+	// set value, unless other processes keep the cache from being written,
+	// which isn't an error of the lookup.
 	now := time.Now()
-	return c.set(key, CacheItem{LastAccessed: now, Created: now}, val)
+	err := c.set(key, CacheItem{LastAccessed: now, Created: now}, val)
+	if cacheUnavailable(err) {
+		return nil
+	}
+	return err
 }
 
 // Encodes some data into raw bytes for the cache.
@@ -204,19 +255,19 @@ func (c *BoltdbCache) decode(
 		}
 	case II_CACHE_VTYPE_MAP:
 		i := CacheItemMap{}
-		err := json.Unmarshal(data, &i)
+		err = json.Unmarshal(data, &i)
 		if err == nil {
 			return i.LastAccessed, i.Created, i.Data, nil
 		}
 	case II_CACHE_VTYPE_CORE:
 		i := CacheItemCore{}
-		err := json.Unmarshal(data, &i)
+		err = json.Unmarshal(data, &i)
 		if err == nil {
 			return i.LastAccessed, i.Created, i.Data, nil
 		}
 	case II_CACHE_VTYPE_ASN:
 		i := CacheItemASN{}
-		err := json.Unmarshal(data, &i)
+		err = json.Unmarshal(data, &i)
 		if err == nil {
 			return i.LastAccessed, i.Created, i.Data, nil
 		}
@@ -228,8 +279,8 @@ func (c *BoltdbCache) decode(
 }
 
 func (c *BoltdbCache) del(key string) error {
-	return c.db.Update(func(t *bbolt.Tx) error {
-		err := t.Bucket(II_CACHE_BUCKET).Delete([]byte(key))
+	return c.update(func(t *bbolt.Tx) error {
+		err := delCacheEntry(t, []byte(key))
 		if err != nil {
 			return fmt.Errorf("something went wrong while deleting cache: %w", err)
 		}
@@ -247,8 +298,8 @@ func (c *BoltdbCache) set(
 		return err
 	}
 
-	return c.db.Update(func(t *bbolt.Tx) error {
-		err := t.Bucket(II_CACHE_BUCKET).Put([]byte(key), d)
+	return c.update(func(t *bbolt.Tx) error {
+		err := putCacheEntry(t, []byte(key), d, item.LastAccessed)
 		if err != nil {
 			return fmt.Errorf("error in adding data: %w", err)
 		}
@@ -257,65 +308,63 @@ func (c *BoltdbCache) set(
 	})
 }
 
-func (c *BoltdbCache) isTooFull() bool {
-	var dbsize int64
-	c.db.View(func(t *bbolt.Tx) error {
-		dbsize = t.Size()
+// the size of the cache's data, and that of its index.
+func (c *BoltdbCache) size() int64 {
+	var size int64
+	c.view(func(t *bbolt.Tx) error {
+		size = int64(cacheStat(t.Bucket(II_CACHE_STATS_BUCKET), II_CACHE_STAT_SIZE))
 		return nil
 	})
-	return dbsize > 1024*1024*1024 // 1 GB
-}
-
-type latKey struct {
-	k   string
-	lat time.Time
-}
-
-type latKeys []latKey
-
-func (s latKeys) Len() int {
-	return len(s)
+	return size
 }
 
-func (s latKeys) Less(i, j int) bool {
-	return s[i].lat.Before(s[j].lat)
+func (c *BoltdbCache) isTooFull() bool {
+	return c.size() > c.policy.maxSize
 }
 
-func (s latKeys) Swap(i, j int) {
-	tmp := s[i]
-	s[i] = tmp
-	s[j] = tmp
+// Evicts the least recently accessed entries if the cache is over its max
+// size, by their latest accesses.
+func (c *BoltdbCache) evictIfTooFull() error {
+	if !c.isTooFull() {
+		return nil
+	}
+	if err := c.Flush(); err != nil {
+		return err
+	}
+	return c.evict()
 }
 
-func (c *BoltdbCache) delBulk() {
-	c.db.Update(func(t *bbolt.Tx) error {
-		bucket := t.Bucket(II_CACHE_BUCKET)
-
-		// collect all keys with their last-accessed timestamp.
-		lats := make(latKeys, 0, bucket.Stats().KeyN)
-		err := bucket.ForEach(func(k, v []byte) error {
-			lat, _, _, err := c.decode(v)
-			if err != nil {
-				return err
+// Evicts the least recently accessed entries until the cache is down to its
+// low-water mark, in batches so no transaction grows too large.
+func (c *BoltdbCache) evict() error {
+	lowWater := c.policy.maxSize * cacheLowWaterPct / 100
+	for {
+		var n int
+		var done bool
+		err := c.update(func(t *bbolt.Tx) error {
+			stats := t.Bucket(II_CACHE_STATS_BUCKET)
+			cur := t.Bucket(II_CACHE_LAT_BUCKET).Cursor()
+			for ; n < cacheEvictBatchSize; n++ {
+				if int64(cacheStat(stats, II_CACHE_STAT_SIZE)) <= lowWater {
+					done = true
+					return nil
+				}
+
+				// the index is in order of last access, so the first key is
+				// the least recently accessed.
+				k, _ := cur.First()
+				if k == nil {
+					done = true
+					return nil
+				}
+				if err := delCacheLATIndexKey(t, append([]byte(nil), k...)); err != nil {
+					return err
+				}
 			}
-			lats = append(lats, latKey{k: string(k), lat: lat})
 			return nil
 		})
-		if err != nil {
+		if err != nil || done {
 			return err
 		}
-
-		// sort by last-accessed timestamp, and delete the oldest 10k in bulk.
-		sort.Sort(lats)
-		upperLimit := len(lats)
-		if upperLimit > 10000 {
-			upperLimit = 10000
-		}
-		for i := 0; i < upperLimit; i++ {
-			if err := bucket.Delete([]byte(lats[i].k)); err != nil {
-				return err
-			}
-		}
-		return nil
-	})
+	}
 }
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+const (
+	// the max number of accesses kept in memory before they're written.
+	cacheAccessFlushSize = 1000
+
+	// the max time accesses are kept in memory before they're written.
+	cacheAccessFlushInterval = 5 * time.Second
+)
+
+// cacheAccesses are the accesses to the cache not yet written to it: when
+// entries were last accessed, and the hits and misses.
+type cacheAccesses struct {
+	lats   map[string]time.Time
+	hits   uint64
+	misses uint64
+}
+
+// recordAccess records a hit at `key` at `lat`, or a miss if `key` is empty,
+// writing the accesses so far if there are enough of them or they're old
+// enough.
+func (c *BoltdbCache) recordAccess(key string, lat time.Time) {
+	c.mu.Lock()
+	if key == "" {
+		c.pending.misses++
+	} else {
+		c.pending.hits++
+		c.pending.lats[key] = lat
+	}
+	flush := len(c.pending.lats) >= c.flushSize ||
+		time.Since(c.lastFlush) >= cacheAccessFlushInterval
+	c.mu.Unlock()
+
+	if flush {
+		c.Flush()
+	}
+}
+
+// Flush writes the accesses kept in memory to the cache in one transaction.
+//
+// Entries which were since deleted, or accessed later by another process,
+// are left as they are. The accesses are dropped if the cache can't be
+// written.
+func (c *BoltdbCache) Flush() error {
+	c.mu.Lock()
+	pending := c.pending
+	c.pending = cacheAccesses{
+		lats: make(map[string]time.Time, len(pending.lats)),
+	}
+	c.lastFlush = time.Now()
+	c.mu.Unlock()
+
+	if len(pending.lats) == 0 && pending.hits == 0 && pending.misses == 0 {
+		return nil
+	}
+
+	err := c.update(func(t *bbolt.Tx) error {
+		bucket := t.Bucket(II_CACHE_BUCKET)
+		for key, lat := range pending.lats {
+			k := []byte(key)
+			val := bucket.Get(k)
+			if val == nil {
+				continue
+			}
+			if cur, err := cacheEntryLAT(val); err == nil && !lat.After(cur) {
+				continue
+			}
+
+			_, created, i, err := c.decode(val)
+			if err != nil {
+				continue
+			}
+			d, err := c.encode(lat, created, i)
+			if err != nil {
+				continue
+			}
+			if err := putCacheEntry(t, k, d, lat); err != nil {
+				return err
+			}
+		}
+
+		if err := addCacheStat(t, II_CACHE_STAT_HITS, int64(pending.hits)); err != nil {
+			return err
+		}
+		return addCacheStat(t, II_CACHE_STAT_MISSES, int64(pending.misses))
+	})
+
+	// the accesses are dropped if other processes keep the cache from being
+	// written.
+	if cacheUnavailable(err) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+// the bucket indexing the cache's keys by when they were last accessed, with
+// keys of the time in big-endian nanoseconds followed by the key, so that
+// they're in order of last access.
+var II_CACHE_LAT_BUCKET []byte = []byte("ii_lat")
+
+const (
+	// the percentage of its max size a full cache is evicted down to.
+	cacheLowWaterPct = 90
+
+	// the max number of entries evicted per transaction.
+	cacheEvictBatchSize = 1000
+)
+
+// cacheLATIndexKey returns the key in the last-accessed index of `key`, last
+// accessed at `lat`.
+func cacheLATIndexKey(lat time.Time, key []byte) []byte {
+	// times before 1970, like the zero time, are first.
+	var ns uint64
+	if lat.After(time.Unix(0, 0)) {
+		ns = uint64(lat.UnixNano())
+	}
+
+	k := make([]byte, 8, 8+len(key))
+	binary.BigEndian.PutUint64(k, ns)
+	return append(k, key...)
+}
+
+// cacheEntrySize returns the size that an entry of `key` and `val` adds to
+// the cache, with its key in the last-accessed index.
+func cacheEntrySize(key, val []byte) int64 {
+	return int64(len(key)+len(val)) + int64(8+len(key))
+}
+
+// cacheEntryLAT returns when the encoded value `val` was last accessed.
+func cacheEntryLAT(val []byte) (time.Time, error) {
+	var i CacheItem
+	if len(val) == 0 {
+		return i.LastAccessed, errors.New("empty value")
+	}
+	err := json.Unmarshal(val[:len(val)-1], &i)
+	return i.LastAccessed, err
+}
+
+// putCacheEntry puts the encoded value `val`, last accessed at `lat`, at `key`
+// in the transaction `t`, moving it in the last-accessed index and keeping the
+// size of the cache in step.
+func putCacheEntry(t *bbolt.Tx, key, val []byte, lat time.Time) error {
+	if err := delCacheEntry(t, key); err != nil {
+		return err
+	}
+
+	if err := t.Bucket(II_CACHE_BUCKET).Put(key, val); err != nil {
+		return err
+	}
+	err := t.Bucket(II_CACHE_LAT_BUCKET).Put(cacheLATIndexKey(lat, key), nil)
+	if err != nil {
+		return err
+	}
+	return addCacheStat(t, II_CACHE_STAT_SIZE, cacheEntrySize(key, val))
+}
+
+// delCacheEntry deletes the entry at `key`, if any, in the transaction `t`,
+// with its key in the last-accessed index.
+func delCacheEntry(t *bbolt.Tx, key []byte) error {
+	bucket := t.Bucket(II_CACHE_BUCKET)
+	val := bucket.Get(key)
+	if val == nil {
+		return nil
+	}
+
+	// an unreadable last-accessed time is indexed as the zero time.
+	lat, _ := cacheEntryLAT(val)
+	err := t.Bucket(II_CACHE_LAT_BUCKET).Delete(cacheLATIndexKey(lat, key))
+	if err != nil {
+		return err
+	}
+	if err := addCacheStat(t, II_CACHE_STAT_SIZE, -cacheEntrySize(key, val)); err != nil {
+		return err
+	}
+	return bucket.Delete(key)
+}
+
+// delCacheLATIndexKey deletes the entry whose key in the last-accessed index
+// is `k` in the transaction `t`.
+func delCacheLATIndexKey(t *bbolt.Tx, k []byte) error {
+	if err := delCacheEntry(t, k[8:]); err != nil {
+		return err
+	}
+
+	// in case the index is stale.
+	return t.Bucket(II_CACHE_LAT_BUCKET).Delete(k)
+}
+
+// buildCacheLATIndex creates the last-accessed index of the cache in the
+// transaction `t`, and sets the size of the cache, for caches from before
+// there was an index.
+func buildCacheLATIndex(t *bbolt.Tx) error {
+	index, err := t.CreateBucket(II_CACHE_LAT_BUCKET)
+	if err != nil {
+		return err
+	}
+
+	var size int64
+	err = t.Bucket(II_CACHE_BUCKET).ForEach(func(k, v []byte) error {
+		// entries without a readable last-accessed time are evicted first.
+		lat, _ := cacheEntryLAT(v)
+		size += cacheEntrySize(k, v)
+		return index.Put(cacheLATIndexKey(lat, k), nil)
+	})
+	if err != nil {
+		return err
+	}
+
+	return addCacheStat(t, II_CACHE_STAT_SIZE, size-int64(cacheStat(
+		t.Bucket(II_CACHE_STATS_BUCKET), II_CACHE_STAT_SIZE,
+	)))
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+const (
+	// how long to wait for other processes to release the cache.
+	cacheOpenTimeout = 2 * time.Second
+
+	// how long the cache is kept open after it was last used, before it's
+	// closed so that other processes can open it.
+	cacheIdleTimeout = 100 * time.Millisecond
+)
+
+var (
+	// the cache couldn't be locked within the timeout.
+	errCacheLocked = errors.New("cache is locked by another process")
+
+	// the cache couldn't be locked for writing, so it's only read.
+	errCacheReadOnly = errors.New("cache is read-only")
+
+	// the cache's db doesn't exist or isn't set up yet.
+	errCacheNotSetUp = errors.New("cache is not set up")
+)
+
+// cacheUnavailable returns whether `err` is from the cache being locked by
+// other processes, rather than from the cache itself.
+func cacheUnavailable(err error) bool {
+	return errors.Is(err, errCacheLocked) || errors.Is(err, errCacheReadOnly)
+}
+
+// openCacheDB opens the cache's db at `path`, waiting at most `timeout` for
+// other processes to release it. It's opened for writing, and set up if it
+// isn't yet, unless `readOnly` is set.
+func openCacheDB(path string, readOnly bool, timeout time.Duration) (*bbolt.DB, error) {
+	// bbolt would create it, but can't initialize it when opening it
+	// read-only.
+	if readOnly {
+		if fi, err := os.Stat(path); err != nil || fi.Size() == 0 {
+			return nil, errCacheNotSetUp
+		}
+	}
+
+	db, err := bbolt.Open(path, 0660, &bbolt.Options{
+		Timeout:  timeout,
+		ReadOnly: readOnly,
+	})
+	if errors.Is(err, bbolt.ErrTimeout) {
+		return nil, fmt.Errorf("%w for over %v", errCacheLocked, timeout)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error opening database: %w", err)
+	}
+
+	// the db is only written to set it up if it isn't yet, rather than every
+	// time it's opened; reading it only needs its entries.
+	var setUp bool
+	err = db.View(func(t *bbolt.Tx) error {
+		if readOnly {
+			setUp = t.Bucket(II_CACHE_BUCKET) != nil
+		} else {
+			setUp = cacheDBSetUp(t)
+		}
+		return nil
+	})
+	if err == nil && !setUp {
+		if readOnly {
+			err = errCacheNotSetUp
+		} else {
+			err = db.Update(setupCacheDB)
+		}
+	}
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// cacheDBSetUp returns whether all buckets of the cache exist in the
+// transaction `t`.
+func cacheDBSetUp(t *bbolt.Tx) bool {
+	for _, b := range [][]byte{
+		II_CACHE_BUCKET,
+		II_CACHE_STATS_BUCKET,
+		II_CACHE_LAT_BUCKET,
+	} {
+		if t.Bucket(b) == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// setupCacheDB creates the buckets of the cache in the transaction `t`, if
+// they don't exist yet.
+func setupCacheDB(t *bbolt.Tx) error {
+	_, err := t.CreateBucketIfNotExists(II_CACHE_BUCKET)
+	if err != nil {
+		return fmt.Errorf("error creating root db bucket: %w", err)
+	}
+	_, err = t.CreateBucketIfNotExists(II_CACHE_STATS_BUCKET)
+	if err != nil {
+		return fmt.Errorf("error creating stats db bucket: %w", err)
+	}
+	if t.Bucket(II_CACHE_LAT_BUCKET) == nil {
+		if err := buildCacheLATIndex(t); err != nil {
+			return fmt.Errorf("error creating last-accessed index: %w", err)
+		}
+	}
+	return nil
+}
+
+// view runs `fn` in a read-only transaction of the cache's db, opening it if
+// it isn't open.
+func (c *BoltdbCache) view(fn func(*bbolt.Tx) error) error {
+	db, err := c.acquire(false)
+	if err != nil {
+		return err
+	}
+	defer c.release()
+	return db.View(fn)
+}
+
+// update runs `fn` in a read-write transaction of the cache's db, opening it
+// for writing if it isn't.
+func (c *BoltdbCache) update(fn func(*bbolt.Tx) error) error {
+	db, err := c.acquire(true)
+	if err != nil {
+		return err
+	}
+	defer c.release()
+	return db.Update(fn)
+}
+
+// acquire returns the cache's db with `dbMu` read-locked, so it isn't closed
+// while it's used, opening it for writing if `write` is set and it isn't.
+// `release` must be called once it's no longer used.
+func (c *BoltdbCache) acquire(write bool) (*bbolt.DB, error) {
+	for {
+		c.dbMu.RLock()
+		if c.db != nil && (!write || !c.db.IsReadOnly()) {
+			return c.db, nil
+		}
+		c.dbMu.RUnlock()
+
+		// it may be closed again before it's read-locked, if it's idle for
+		// that long.
+		c.dbMu.Lock()
+		err := c.openDB(write)
+		c.dbMu.Unlock()
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// release marks the db as used, and read-unlocks `dbMu`.
+func (c *BoltdbCache) release() {
+	c.lastUse.Store(time.Now().UnixNano())
+	c.dbMu.RUnlock()
+}
+
+// openDB opens the cache's db unless it's open, for writing if `write` is
+// set. The db is read through a shared lock, so other processes can read it
+// at the same time, and only locked exclusively for writing.
+//
+// If it can't be locked for writing, the cache is only read from then on;
+// and if it can't be locked at all, it isn't used from then on.
+//
+// The caller must hold `dbMu`.
+func (c *BoltdbCache) openDB(write bool) error {
+	if c.locked != nil {
+		return c.locked
+	}
+	if c.db != nil && (!write || !c.db.IsReadOnly()) {
+		return nil
+	}
+	if write && c.readOnly {
+		return errCacheReadOnly
+	}
+	c.closeDB()
+	defer c.closeWhenIdle()
+
+	var err error
+	if !write {
+		c.db, err = openCacheDB(c.path, true, c.openTimeout)
+		if !errors.Is(err, errCacheNotSetUp) {
+			return c.lockFailed(err)
+		}
+	}
+
+	// opened for writing to set it up, if it was to be read.
+	c.db, err = openCacheDB(c.path, false, c.openTimeout)
+	if write && errors.Is(err, errCacheLocked) {
+		c.readOnly = true
+		fmt.Fprintf(os.Stderr, "warn: %v; using it read-only\n", err)
+		return errCacheReadOnly
+	}
+	return c.lockFailed(err)
+}
+
+// lockFailed stops the cache from being used if `err` is from it not being
+// locked, so that it isn't waited for again, and returns `err`.
+//
+// The caller must hold `dbMu`.
+func (c *BoltdbCache) lockFailed(err error) error {
+	if errors.Is(err, errCacheLocked) {
+		c.locked = err
+		fmt.Fprintf(os.Stderr, "warn: %v; it will not be used\n", err)
+	}
+	return err
+}
+
+// closeWhenIdle closes the db once it's been idle for a while, if it's open.
+//
+// The caller must hold `dbMu`.
+func (c *BoltdbCache) closeWhenIdle() {
+	if c.db == nil {
+		return
+	}
+	c.lastUse.Store(time.Now().UnixNano())
+	if c.idleTimer == nil {
+		c.idleTimer = time.AfterFunc(cacheIdleTimeout, c.closeIdle)
+	} else {
+		c.idleTimer.Reset(cacheIdleTimeout)
+	}
+}
+
+// closeIdle closes the db if it's been idle for a while, or checks again once
+// it could have been.
+func (c *BoltdbCache) closeIdle() {
+	c.dbMu.Lock()
+	defer c.dbMu.Unlock()
+
+	idle := time.Since(time.Unix(0, c.lastUse.Load()))
+	if idle < cacheIdleTimeout {
+		c.idleTimer.Reset(cacheIdleTimeout - idle)
+		return
+	}
+	c.closeDB()
+}
+
+// closeDB closes the db if it's open.
+//
+// The caller must hold `dbMu`.
+func (c *BoltdbCache) closeDB() error {
+	if c.db == nil {
+		return nil
+	}
+	err := c.db.Close()
+	c.db = nil
+	return err
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// the defaults of the cache policy.
+const (
+	defaultCacheTTL     = 24 * time.Hour
+	defaultCacheMaxSize = 1024 * 1024 * 1024 // 1 GB
+)
+
+// the value types of the cache by their names in config keys and --cache-ttl.
+var cacheVTypes = []struct {
+	name  string
+	vtype byte
+}{
+	{"core", II_CACHE_VTYPE_CORE},
+	{"asn", II_CACHE_VTYPE_ASN},
+	{"string", II_CACHE_VTYPE_STRING},
+	{"map", II_CACHE_VTYPE_MAP},
+}
+
+// cacheVTypeByName returns the value type named `name`.
+func cacheVTypeByName(name string) (byte, bool) {
+	for _, t := range cacheVTypes {
+		if t.name == name {
+			return t.vtype, true
+		}
+	}
+	return 0, false
+}
+
+// cachePolicy is how long the cache keeps values of each type, and how large
+// it grows before evicting the least recently accessed ones.
+type cachePolicy struct {
+	ttls    map[byte]time.Duration
+	maxSize int64
+}
+
+// ttl returns how long values of type `vtype` are kept.
+func (p cachePolicy) ttl(vtype byte) time.Duration {
+	if ttl, ok := p.ttls[vtype]; ok {
+		return ttl
+	}
+	return defaultCacheTTL
+}
+
+// cacheSetting is a setting of the cache policy and where it comes from:
+// "default", "config" or "--cache-ttl".
+type cacheSetting struct {
+	value  string
+	source string
+}
+
+// cacheTTLSetting returns the TTL of values of type `vtype`, by --cache-ttl,
+// the config or the default, in that order.
+func cacheTTLSetting(vtype byte) (time.Duration, cacheSetting) {
+	if ttl, ok := fCacheTTL[vtype]; ok {
+		return ttl, cacheSetting{formatCacheTTL(ttl), "--cache-ttl"}
+	}
+	if ttl, err := parseCacheTTL(gConfig.cacheTTL(vtype)); err == nil {
+		return ttl, cacheSetting{formatCacheTTL(ttl), "config"}
+	}
+	return defaultCacheTTL, cacheSetting{formatCacheTTL(defaultCacheTTL), "default"}
+}
+
+// cacheMaxSizeSetting returns the max size of the cache, by the config or the
+// default.
+func cacheMaxSizeSetting() (int64, cacheSetting) {
+	if size, err := parseByteSize(gConfig.CacheMaxSize); err == nil {
+		return size, cacheSetting{formatByteSize(size), "config"}
+	}
+	return defaultCacheMaxSize, cacheSetting{formatByteSize(defaultCacheMaxSize), "default"}
+}
+
+// effectiveCachePolicy returns the cache policy of the command.
+func effectiveCachePolicy() cachePolicy {
+	p := cachePolicy{ttls: make(map[byte]time.Duration, len(cacheVTypes))}
+	for _, t := range cacheVTypes {
+		p.ttls[t.vtype], _ = cacheTTLSetting(t.vtype)
+	}
+	p.maxSize, _ = cacheMaxSizeSetting()
+	return p
+}
+
+// cacheTTL returns the configured TTL of values of type `vtype`, if any.
+func (c Config) cacheTTL(vtype byte) string {
+	switch vtype {
+	case II_CACHE_VTYPE_CORE:
+		return c.CacheTTLCore
+	case II_CACHE_VTYPE_ASN:
+		return c.CacheTTLASN
+	case II_CACHE_VTYPE_STRING:
+		return c.CacheTTLString
+	case II_CACHE_VTYPE_MAP:
+		return c.CacheTTLMap
+	}
+	return ""
+}
+
+// parseCacheTTL parses a TTL like '1h30m', or in days like '7d'.
+func parseCacheTTL(s string) (time.Duration, error) {
+	var ttl time.Duration
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.ParseFloat(days, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid ttl %q", s)
+		}
+		ttl = time.Duration(n * float64(24*time.Hour))
+	} else {
+		var err error
+		ttl, err = time.ParseDuration(s)
+		if err != nil {
+			return 0, fmt.Errorf("invalid ttl %q", s)
+		}
+	}
+	if ttl <= 0 {
+		return 0, fmt.Errorf("invalid ttl %q; must be positive", s)
+	}
+	return ttl, nil
+}
+
+// formatCacheTTL formats `ttl` in days if it's whole days.
+func formatCacheTTL(ttl time.Duration) string {
+	if ttl%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%dd", ttl/(24*time.Hour))
+	}
+	return ttl.String()
+}
+
+// the units of byte sizes, from the largest.
+var byteSizeUnits = []struct {
+	suffix string
+	size   int64
+}{
+	{"TB", 1 << 40},
+	{"GB", 1 << 30},
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+	{"B", 1},
+}
+
+// parseByteSize parses a size in bytes like '512MB' or '2GB'; units are of
+// 1024 bytes.
+func parseByteSize(s string) (int64, error) {
+	str := strings.ToUpper(strings.TrimSpace(s))
+	unit := int64(1)
+	for _, u := range byteSizeUnits {
+		if n, ok := strings.CutSuffix(str, u.suffix); ok {
+			str, unit = strings.TrimSpace(n), u.size
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(str, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return int64(n * float64(unit)), nil
+}
+
+// formatByteSize formats `size` in the largest unit it's a whole number of.
+func formatByteSize(size int64) string {
+	for _, u := range byteSizeUnits {
+		if size%u.size == 0 {
+			return fmt.Sprintf("%d%s", size/u.size, u.suffix)
+		}
+	}
+	return fmt.Sprintf("%dB", size)
+}
+
+// cacheTTLFlag is the value of --cache-ttl, the TTLs of value types: either
+// one TTL for all of them, or a comma-separated list of <type>=<ttl>.
+type cacheTTLFlag map[byte]time.Duration
+
+// the TTLs of value types of the command's cache.
+var fCacheTTL = cacheTTLFlag{}
+
+func (f cacheTTLFlag) String() string {
+	var s []string
+	for _, t := range cacheVTypes {
+		if ttl, ok := f[t.vtype]; ok {
+			s = append(s, t.name+"="+formatCacheTTL(ttl))
+		}
+	}
+	return strings.Join(s, ",")
+}
+
+func (f cacheTTLFlag) Set(v string) error {
+	if !strings.Contains(v, "=") {
+		ttl, err := parseCacheTTL(v)
+		if err != nil {
+			return err
+		}
+		for _, t := range cacheVTypes {
+			f[t.vtype] = ttl
+		}
+		return nil
+	}
+
+	for _, kv := range strings.Split(v, ",") {
+		name, val, _ := strings.Cut(kv, "=")
+		vtype, ok := cacheVTypeByName(strings.ToLower(strings.TrimSpace(name)))
+		if !ok {
+			return fmt.Errorf(
+				"invalid type %q; must be 'core', 'asn', 'string' or 'map'",
+				name,
+			)
+		}
+		ttl, err := parseCacheTTL(strings.TrimSpace(val))
+		if err != nil {
+			return err
+		}
+		f[vtype] = ttl
+	}
+	return nil
+}
+
+func (f cacheTTLFlag) Type() string {
+	return "ttl"
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCacheTTL(t *testing.T) {
+	for s, want := range map[string]time.Duration{
+		"6h":    6 * time.Hour,
+		"1h30m": 90 * time.Minute,
+		"7d":    7 * 24 * time.Hour,
+		"0.5d":  12 * time.Hour,
+	} {
+		if got, err := parseCacheTTL(s); err != nil || got != want {
+			t.Errorf("%v: got %v, %v; want %v", s, got, err, want)
+		}
+	}
+	for _, s := range []string{"", "0", "-1h", "xd", "7days"} {
+		if _, err := parseCacheTTL(s); err == nil {
+			t.Errorf("%v: expected an error", s)
+		}
+	}
+}
+
+func TestByteSize(t *testing.T) {
+	for s, want := range map[string]int64{
+		"512":    512,
+		"512B":   512,
+		"64kb":   64 << 10,
+		"256MB":  256 << 20,
+		"1.5GB":  3 << 29,
+		" 2 TB ": 2 << 40,
+	} {
+		got, err := parseByteSize(s)
+		if err != nil || got != want {
+			t.Errorf("%q: got %v, %v; want %v", s, got, err, want)
+		}
+	}
+	for _, s := range []string{"", "0", "-1MB", "MB", "1XB"} {
+		if _, err := parseByteSize(s); err == nil {
+			t.Errorf("%q: expected an error", s)
+		}
+	}
+
+	for size, want := range map[int64]string{
+		512:      "512B",
+		64 << 10: "64KB",
+		3 << 29:  "1536MB",
+		1 << 30:  "1GB",
+	} {
+		if got := formatByteSize(size); got != want {
+			t.Errorf("%v: got %v, want %v", size, got, want)
+		}
+	}
+}
+
+// --cache-ttl sets either one TTL for all types, or those of some types.
+func TestCacheTTLFlag(t *testing.T) {
+	f := cacheTTLFlag{}
+	if err := f.Set("6h"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, vt := range cacheVTypes {
+		if f[vt.vtype] != 6*time.Hour {
+			t.Errorf("%v: got %v, want 6h", vt.name, f[vt.vtype])
+		}
+	}
+
+	f = cacheTTLFlag{}
+	if err := f.Set("asn=7d, core=1h"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(f) != 2 || f[II_CACHE_VTYPE_ASN] != 7*24*time.Hour || f[II_CACHE_VTYPE_CORE] != time.Hour {
+		t.Errorf("got %v", f.String())
+	}
+	if s := f.String(); s != "core=1h0m0s,asn=7d" {
+		t.Errorf("got %q", s)
+	}
+
+	for _, v := range []string{"ip=1h", "asn=", "asn=-1d"} {
+		if err := (cacheTTLFlag{}).Set(v); err == nil {
+			t.Errorf("%v: expected an error", v)
+		}
+	}
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+// the bucket of the cache's counters, like that of hits.
+var II_CACHE_STATS_BUCKET []byte = []byte("ii_stats")
+
+// the counters of the cache.
+var (
+	II_CACHE_STAT_HITS   []byte = []byte("hits")
+	II_CACHE_STAT_MISSES []byte = []byte("misses")
+
+	// the size of the entries and their keys in the last-accessed index.
+	II_CACHE_STAT_SIZE []byte = []byte("size")
+)
+
+// the version the IPinfo Go SDK suffixes its cache keys with.
+const cacheKeyVsn = "2"
+
+// the number of records imported per transaction.
+const cacheImportBatchSize = 1000
+
+// cacheVTypeName returns the name of the value type `vtype`.
+func cacheVTypeName(vtype byte) string {
+	for _, t := range cacheVTypes {
+		if t.vtype == vtype {
+			return t.name
+		}
+	}
+	return fmt.Sprintf("unknown(%d)", vtype)
+}
+
+// cacheKeyOf returns the cache key of the IP or ASN `s`, as the IPinfo Go SDK
+// keys it.
+func cacheKeyOf(s string) string {
+	if strings.HasPrefix(strings.ToUpper(s), "AS") {
+		s = strings.ToUpper(s)
+	}
+	return s + ":" + cacheKeyVsn
+}
+
+// cacheRecord is a value in the cache with its metadata, as it's output by
+// `cache get` and `cache export`.
+type cacheRecord struct {
+	Key          string          `json:"key"`
+	Type         string          `json:"type"`
+	Created      time.Time       `json:"created"`
+	LastAccessed time.Time       `json:"last_accessed"`
+	Data         json.RawMessage `json:"data"`
+}
+
+// cacheItemRaw is a cache item whose data is left encoded.
+type cacheItemRaw struct {
+	CacheItem
+	Data json.RawMessage `json:"d"`
+}
+
+// newCacheRecord returns the record of the value `val` at `key`.
+func (c *BoltdbCache) newCacheRecord(key, val []byte) (*cacheRecord, error) {
+	if len(val) == 0 {
+		return nil, fmt.Errorf("empty value at %q", key)
+	}
+
+	// make sure it decodes as its type does, as `Get` would.
+	if _, _, _, err := c.decode(val); err != nil {
+		return nil, fmt.Errorf("invalid value at %q: %w", key, err)
+	}
+
+	var i cacheItemRaw
+	if err := json.Unmarshal(val[:len(val)-1], &i); err != nil {
+		return nil, fmt.Errorf("invalid value at %q: %w", key, err)
+	}
+	return &cacheRecord{
+		Key:          string(key),
+		Type:         cacheVTypeName(val[len(val)-1]),
+		Created:      i.Created,
+		LastAccessed: i.LastAccessed,
+		Data:         i.Data,
+	}, nil
+}
+
+// encodeCacheRecord encodes `r` as it's stored in the cache.
+func (c *BoltdbCache) encodeCacheRecord(r *cacheRecord) ([]byte, error) {
+	vtype, ok := cacheVTypeByName(r.Type)
+	if !ok {
+		return nil, fmt.Errorf("invalid type %q", r.Type)
+	}
+	if r.Key == "" {
+		return nil, fmt.Errorf("missing key")
+	}
+
+	d, err := json.Marshal(cacheItemRaw{
+		CacheItem: CacheItem{LastAccessed: r.LastAccessed, Created: r.Created},
+		Data:      r.Data,
+	})
+	if err != nil {
+		return nil, err
+	}
+	d = append(d, vtype)
+
+	// make sure it decodes as its type does, as `Get` would.
+	if _, _, _, err := c.decode(d); err != nil {
+		return nil, fmt.Errorf("invalid %v data: %w", r.Type, err)
+	}
+	return d, nil
+}
+
+// GetRecord returns the record at `key`, or nil if there's none.
+func (c *BoltdbCache) GetRecord(key string) (*cacheRecord, error) {
+	var r *cacheRecord
+	err := c.view(func(t *bbolt.Tx) error {
+		val := t.Bucket(II_CACHE_BUCKET).Get([]byte(key))
+		if val == nil {
+			return nil
+		}
+
+		var err error
+		r, err = c.newCacheRecord([]byte(key), val)
+		return err
+	})
+	return r, err
+}
+
+// ForEachRecord calls `fn` with every record in the cache, in order of key.
+func (c *BoltdbCache) ForEachRecord(fn func(r *cacheRecord) error) error {
+	return c.view(func(t *bbolt.Tx) error {
+		return t.Bucket(II_CACHE_BUCKET).ForEach(func(k, v []byte) error {
+			r, err := c.newCacheRecord(k, v)
+			if err != nil {
+				return err
+			}
+			return fn(r)
+		})
+	})
+}
+
+// Export writes all records in the cache to `w` as NDJSON.
+func (c *BoltdbCache) Export(w io.Writer) (int, error) {
+	var n int
+	enc := json.NewEncoder(w)
+	err := c.ForEachRecord(func(r *cacheRecord) error {
+		n++
+		return enc.Encode(r)
+	})
+	return n, err
+}
+
+// Import reads records from the NDJSON in `r`, as output by `Export`, into
+// the cache. Records older than those already in the cache are skipped, and
+// the least recently accessed entries are evicted as by `Set` to keep the
+// cache within its max size.
+//
+// Returns the number of records imported and skipped.
+func (c *BoltdbCache) Import(r io.Reader) (imported int, skipped int, err error) {
+	type kv struct {
+		k       []byte
+		v       []byte
+		created time.Time
+		lat     time.Time
+	}
+	batch := make([]kv, 0, cacheImportBatchSize)
+	flush := func() error {
+		err := c.update(func(t *bbolt.Tx) error {
+			bucket := t.Bucket(II_CACHE_BUCKET)
+			for _, p := range batch {
+				if cur := bucket.Get(p.k); cur != nil {
+					_, created, _, err := c.decode(cur)
+					if err == nil && !created.Before(p.created) {
+						skipped++
+						continue
+					}
+				}
+				if err := putCacheEntry(t, p.k, p.v, p.lat); err != nil {
+					return fmt.Errorf("error in adding data: %w", err)
+				}
+				imported++
+			}
+			return nil
+		})
+		batch = batch[:0]
+		if err != nil {
+			return err
+		}
+		return c.evictIfTooFull()
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+
+		var rec cacheRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return imported, skipped, fmt.Errorf("line %d: %w", line, err)
+		}
+		v, err := c.encodeCacheRecord(&rec)
+		if err != nil {
+			return imported, skipped, fmt.Errorf("line %d: %w", line, err)
+		}
+		batch = append(batch, kv{[]byte(rec.Key), v, rec.Created, rec.LastAccessed})
+
+		if len(batch) == cacheImportBatchSize {
+			if err := flush(); err != nil {
+				return imported, skipped, err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return imported, skipped, err
+	}
+	if len(batch) > 0 {
+		err = flush()
+	}
+	return imported, skipped, err
+}
+
+// cacheStats are the contents of the cache in numbers.
+type cacheStats struct {
+	Entries  map[string]int `json:"entries"`
+	Size     int64          `json:"size"`
+	DataSize int64          `json:"data_size"`
+	Hits     uint64         `json:"hits"`
+	Misses   uint64         `json:"misses"`
+	Oldest   *time.Time     `json:"oldest,omitempty"`
+	Newest   *time.Time     `json:"newest,omitempty"`
+}
+
+// Stats returns the numbers of entries of each type in the cache, its size and
+// that of its data, hits and misses, and when the oldest and newest entries
+// were created.
+func (c *BoltdbCache) Stats() (*cacheStats, error) {
+	if err := c.Flush(); err != nil {
+		return nil, err
+	}
+
+	s := &cacheStats{Entries: make(map[string]int, len(cacheVTypes))}
+	for _, t := range cacheVTypes {
+		s.Entries[t.name] = 0
+	}
+	err := c.view(func(t *bbolt.Tx) error {
+		s.Size = t.Size()
+		if b := t.Bucket(II_CACHE_STATS_BUCKET); b != nil {
+			s.Hits = cacheStat(b, II_CACHE_STAT_HITS)
+			s.Misses = cacheStat(b, II_CACHE_STAT_MISSES)
+			s.DataSize = int64(cacheStat(b, II_CACHE_STAT_SIZE))
+		}
+
+		return t.Bucket(II_CACHE_BUCKET).ForEach(func(k, v []byte) error {
+			if len(v) == 0 {
+				return nil
+			}
+			s.Entries[cacheVTypeName(v[len(v)-1])]++
+
+			_, created, _, err := c.decode(v)
+			if err != nil {
+				return nil
+			}
+			if s.Oldest == nil || created.Before(*s.Oldest) {
+				s.Oldest = &created
+			}
+			if s.Newest == nil || created.After(*s.Newest) {
+				s.Newest = &created
+			}
+			return nil
+		})
+	})
+	return s, err
+}
+
+// cacheStat returns the counter `name` in the stats bucket `b`, if any.
+func cacheStat(b *bbolt.Bucket, name []byte) uint64 {
+	if b == nil {
+		return 0
+	}
+	v := b.Get(name)
+	if len(v) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(v)
+}
+
+// incrCacheStat increments the counter `name` in the transaction `t`.
+func incrCacheStat(t *bbolt.Tx, name []byte) error {
+	return addCacheStat(t, name, 1)
+}
+
+// addCacheStat adds `delta` to the counter `name` in the transaction `t`.
+func addCacheStat(t *bbolt.Tx, name []byte, delta int64) error {
+	b := t.Bucket(II_CACHE_STATS_BUCKET)
+	if b == nil {
+		return nil
+	}
+
+	var v [8]byte
+	binary.BigEndian.PutUint64(v[:], cacheStat(b, name)+uint64(delta))
+	return b.Put(name, v[:])
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ipinfo/go/v2/ipinfo"
+)
+
+// newTestCache returns a cache in a temp config dir.
+func newTestCache(t *testing.T, policy cachePolicy) *BoltdbCache {
+	t.Helper()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	c, err := NewBoltdbCache(policy)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// Exported entries import into another cache as they were.
+func TestCacheExportImport(t *testing.T) {
+	src := newTestCache(t, cachePolicy{maxSize: defaultCacheMaxSize})
+	if err := src.Set(cacheKeyOf("8.8.8.8"), &ipinfo.Core{City: "Mountain View"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := src.Set(cacheKeyOf("as15169"), &ipinfo.ASNDetails{Name: "Google LLC"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var exported bytes.Buffer
+	if n, err := src.Export(&exported); err != nil || n != 2 {
+		t.Fatalf("got %v entries, %v; want 2", n, err)
+	}
+
+	dst := newTestCache(t, cachePolicy{maxSize: defaultCacheMaxSize})
+	imported, skipped, err := dst.Import(bytes.NewReader(exported.Bytes()))
+	if err != nil || imported != 2 || skipped != 0 {
+		t.Fatalf("got %v imported, %v skipped, %v; want 2, 0", imported, skipped, err)
+	}
+	for _, key := range []string{"8.8.8.8:2", "AS15169:2"} {
+		want, _ := src.GetRecord(key)
+		got, err := dst.GetRecord(key)
+		if err != nil || got == nil {
+			t.Fatalf("%v: got %v, %v", key, got, err)
+		}
+		if got.Type != want.Type || !got.Created.Equal(want.Created) || !bytes.Equal(got.Data, want.Data) {
+			t.Errorf("%v: got %+v, want %+v", key, got, want)
+		}
+	}
+	if v, err := dst.Get("8.8.8.8:2"); err != nil || v.(*ipinfo.Core).City != "Mountain View" {
+		t.Errorf("got %v, %v", v, err)
+	}
+
+	// entries aren't replaced by older ones.
+	imported, skipped, err = dst.Import(bytes.NewReader(exported.Bytes()))
+	if err != nil || imported != 0 || skipped != 2 {
+		t.Errorf("got %v imported, %v skipped, %v; want 0, 2", imported, skipped, err)
+	}
+
+	for _, line := range []string{
+		`{"key":"x:2","type":"ip","data":"x"}`,
+		`{"key":"x:2","type":"core","data":"x"}`,
+		`{"type":"string","data":"x"}`,
+		`not json`,
+	} {
+		if _, _, err := dst.Import(strings.NewReader(line)); err == nil {
+			t.Errorf("%v: expected an error", line)
+		}
+	}
+}
+
+// Importing more than fits into the cache evicts the least recently accessed
+// entries, like setting them does.
+func TestCacheImportEvicts(t *testing.T) {
+	src := newTestCache(t, cachePolicy{maxSize: defaultCacheMaxSize})
+	for i := 0; i < 100; i++ {
+		if err := src.Set(fmt.Sprintf("k%03d", i), "x"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	var exported bytes.Buffer
+	if _, err := src.Export(&exported); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	dst := newTestCache(t, cachePolicy{maxSize: src.size() / 2})
+	imported, _, err := dst.Import(&exported)
+	if err != nil || imported != 100 {
+		t.Fatalf("got %v imported, %v; want 100", imported, err)
+	}
+	checkCacheIndex(t, dst)
+	if size := dst.size(); size > dst.policy.maxSize {
+		t.Errorf("got size %v over the max size %v", size, dst.policy.maxSize)
+	}
+	if r, err := dst.GetRecord("k099"); err != nil || r == nil {
+		t.Errorf("expected the most recently accessed entry kept, got %v, %v", r, err)
+	}
+}
+
+// Hits, misses and expired entries are counted.
+func TestCacheStats(t *testing.T) {
+	c := newTestCache(t, cachePolicy{
+		ttls:    map[byte]time.Duration{II_CACHE_VTYPE_STRING: time.Nanosecond},
+		maxSize: defaultCacheMaxSize,
+	})
+	c.Set("core", &ipinfo.Core{})
+	c.Set("string", "x")
+	time.Sleep(time.Millisecond)
+
+	c.Get("core")
+	c.Get("core")
+	c.Get("string") // expired.
+	c.Get("none")
+
+	s, err := c.Stats()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if s.Hits != 2 || s.Misses != 2 {
+		t.Errorf("got %v hits, %v misses; want 2, 2", s.Hits, s.Misses)
+	}
+	if s.Entries["core"] != 1 || s.Entries["string"] != 0 {
+		t.Errorf("got entries %v", s.Entries)
+	}
+	if s.Oldest == nil || s.Newest == nil || s.Size == 0 {
+		t.Errorf("got %+v", s)
+	}
+}
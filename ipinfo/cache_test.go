@@ -0,0 +1,372 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ipinfo/go/v2/ipinfo"
+	"go.etcd.io/bbolt"
+)
+
+// checkCacheIndex checks that the last-accessed index has a key per entry, in
+// order of last access, and that the size of the cache adds up.
+func checkCacheIndex(t *testing.T, c *BoltdbCache) {
+	t.Helper()
+	var size int64
+	c.view(func(tx *bbolt.Tx) error {
+		entries := tx.Bucket(II_CACHE_BUCKET)
+		entries.ForEach(func(k, v []byte) error {
+			size += cacheEntrySize(k, v)
+			lat, _ := cacheEntryLAT(v)
+			if tx.Bucket(II_CACHE_LAT_BUCKET).Get(cacheLATIndexKey(lat, k)) == nil {
+				t.Errorf("%s is not in the index", k)
+			}
+			return nil
+		})
+		if n, want := tx.Bucket(II_CACHE_LAT_BUCKET).Stats().KeyN, entries.Stats().KeyN; n != want {
+			t.Errorf("got %v keys in the index, want %v", n, want)
+		}
+		return nil
+	})
+	if got := c.size(); got != size {
+		t.Errorf("got size %v, want %v", got, size)
+	}
+}
+
+// The least recently accessed entries are evicted, down to the low-water
+// mark.
+func TestCacheEvict(t *testing.T) {
+	c := newTestCache(t, cachePolicy{maxSize: defaultCacheMaxSize})
+
+	// set k000 to k099, then access k000 to k009 so they're the most recent.
+	key := func(i int) string { return fmt.Sprintf("k%03d", i) }
+	for i := 0; i < 100; i++ {
+		if err := c.Set(key(i), "x"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	for i := 0; i < 10; i++ {
+		if _, err := c.Get(key(i)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	checkCacheIndex(t, c)
+
+	// halve the max size, so setting another entry evicts over half of them.
+	c.policy.maxSize = c.size() / 2
+	if err := c.Set("new", "x"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	checkCacheIndex(t, c)
+	if size := c.size(); size > c.policy.maxSize {
+		t.Errorf("got size %v over the max size %v", size, c.policy.maxSize)
+	}
+
+	// in order of last access, the entries up to some point are evicted, and
+	// those after it are kept.
+	order := []string{}
+	for i := 10; i < 100; i++ {
+		order = append(order, key(i))
+	}
+	for i := 0; i < 10; i++ {
+		order = append(order, key(i))
+	}
+	order = append(order, "new")
+
+	var evicted int
+	for i, k := range order {
+		r, err := c.GetRecord(k)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if r == nil {
+			if i != evicted {
+				t.Fatalf("%v evicted after more recently accessed %v", k, order[evicted])
+			}
+			evicted++
+		}
+	}
+	if evicted < 50 || evicted >= 90 {
+		t.Errorf("got %v evicted, want the 50-90 least recently accessed", evicted)
+	}
+}
+
+// The index is built for caches from before there was one, once they're
+// written.
+func TestCacheLATIndexBuilt(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	path, err := BoltdbCachePath()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// an old cache, with only the entries' bucket.
+	c := &BoltdbCache{}
+	db, err := bbolt.Open(filepath.Clean(path), 0660, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err = db.Update(func(tx *bbolt.Tx) error {
+		b, err := tx.CreateBucket(II_CACHE_BUCKET)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		for i := 0; i < 10; i++ {
+			lat := now.Add(time.Duration(i) * time.Second)
+			v, err := c.encode(lat, lat, "x")
+			if err != nil {
+				return err
+			}
+			if err := b.Put([]byte(fmt.Sprintf("k%d", i)), v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	db.Close()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	c, err = NewBoltdbCache(cachePolicy{maxSize: defaultCacheMaxSize})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer c.Close()
+	if err := c.Set("k10", "x"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	checkCacheIndex(t, c)
+	c.view(func(tx *bbolt.Tx) error {
+		k, _ := tx.Bucket(II_CACHE_LAT_BUCKET).Cursor().First()
+		if string(k[8:]) != "k0" {
+			t.Errorf("got %q first in the index, want k0", k[8:])
+		}
+		return nil
+	})
+}
+
+// Hits are written to the cache in batches rather than one by one.
+func TestCacheAccessBatched(t *testing.T) {
+	c := newTestCache(t, cachePolicy{maxSize: defaultCacheMaxSize})
+	c.flushSize = 3
+	for _, k := range []string{"a", "b", "c"} {
+		if err := c.Set(k, "x"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	lat := func(k string) time.Time {
+		r, err := c.GetRecord(k)
+		if err != nil || r == nil {
+			t.Fatalf("%v: got %v, %v", k, r, err)
+		}
+		return r.LastAccessed
+	}
+	before := lat("a")
+
+	// the first hits are kept in memory.
+	c.Get("a")
+	c.Get("b")
+	if !lat("a").Equal(before) {
+		t.Errorf("hit written before the batch is full")
+	}
+
+	// and written with the hit which fills the batch.
+	c.Get("c")
+	if !lat("a").After(before) || !lat("c").After(before) {
+		t.Errorf("hits not written once the batch is full")
+	}
+	checkCacheIndex(t, c)
+
+	// or when the cache is closed.
+	c.Get("b")
+	if err := c.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	c, err := NewBoltdbCache(c.policy)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer c.Close()
+	s, err := c.Stats()
+	if err != nil || s.Hits != 4 {
+		t.Errorf("got %v hits, %v; want 4", s.Hits, err)
+	}
+}
+
+// Cache hits, with a write per hit as before hits were batched, and batched.
+func BenchmarkCacheGet(b *testing.B) {
+	for _, bc := range []struct {
+		name      string
+		flushSize int
+	}{
+		{"PerHit", 1},
+		{"Batched", cacheAccessFlushSize},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			b.Setenv("XDG_CONFIG_HOME", b.TempDir())
+			c, err := NewBoltdbCache(cachePolicy{maxSize: defaultCacheMaxSize})
+			if err != nil {
+				b.Fatalf("unexpected error: %v", err)
+			}
+			defer c.Close()
+			c.flushSize = bc.flushSize
+
+			keys := make([]string, 1000)
+			for i := range keys {
+				keys[i] = cacheKeyOf(fmt.Sprintf("10.0.%d.%d", i/256, i%256))
+				c.Set(keys[i], &ipinfo.Core{City: "Mountain View"})
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := c.Get(keys[i%len(keys)]); err != nil {
+					b.Fatalf("unexpected error: %v", err)
+				}
+			}
+		})
+	}
+}
+
+// newTestCacheAt returns another cache on the db of `c`, as another process
+// would open it, which waits at most `timeout` for it.
+func newTestCacheAt(t *testing.T, timeout time.Duration) *BoltdbCache {
+	t.Helper()
+	c, err := NewBoltdbCache(cachePolicy{maxSize: defaultCacheMaxSize})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	c.Close()
+	c.openTimeout = timeout
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// Caches of concurrent processes both read and write the same db.
+func TestCacheConcurrentProcesses(t *testing.T) {
+	c1 := newTestCache(t, cachePolicy{maxSize: defaultCacheMaxSize})
+	c2 := newTestCacheAt(t, cacheOpenTimeout)
+
+	if err := c1.Set("a", "1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if v, err := c2.Get("a"); err != nil || v != "1" {
+		t.Errorf("got %v, %v; want 1", v, err)
+	}
+	if err := c2.Set("b", "2"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if v, err := c1.Get("b"); err != nil || v != "2" {
+		t.Errorf("got %v, %v; want 2", v, err)
+	}
+	if c1.readOnly || c2.readOnly {
+		t.Errorf("expected both caches to be writable")
+	}
+}
+
+// While another process reads the db, it's read but not written.
+func TestCacheReadOnlyFallback(t *testing.T) {
+	c1 := newTestCache(t, cachePolicy{maxSize: defaultCacheMaxSize})
+	if err := c1.Set("a", "1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	c1.Close()
+
+	reader, err := bbolt.Open(c1.path, 0660, &bbolt.Options{ReadOnly: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer reader.Close()
+
+	c2 := newTestCacheAt(t, 100*time.Millisecond)
+	if err := c2.Set("b", "2"); err != nil {
+		t.Errorf("expected no error when the cache can't be written, got %v", err)
+	}
+	if !c2.readOnly {
+		t.Errorf("expected the cache to be read-only")
+	}
+	if v, err := c2.Get("a"); err != nil || v != "1" {
+		t.Errorf("got %v, %v; want 1", v, err)
+	}
+	if _, err := c2.Get("b"); err == nil {
+		t.Errorf("expected b not to be written")
+	}
+}
+
+// While another process writes the db for too long, it isn't used, and isn't
+// waited for again.
+func TestCacheLocked(t *testing.T) {
+	c1 := newTestCache(t, cachePolicy{maxSize: defaultCacheMaxSize})
+	c1.Close()
+	c2 := newTestCacheAt(t, 100*time.Millisecond)
+
+	writer, err := bbolt.Open(c1.path, 0660, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer writer.Close()
+
+	if _, err := c2.Get("a"); err == nil {
+		t.Errorf("expected a miss")
+	}
+	if !errors.Is(c2.locked, errCacheLocked) {
+		t.Errorf("got %v, want it to be locked", c2.locked)
+	}
+
+	start := time.Now()
+	c2.Get("a")
+	if err := c2.Set("a", "1"); err != nil {
+		t.Errorf("expected no error when the cache is locked, got %v", err)
+	}
+	if d := time.Since(start); d >= c2.openTimeout {
+		t.Errorf("waited %v for the cache again", d)
+	}
+}
+
+// Transactions of the same process run concurrently, rather than one at a
+// time.
+func TestCacheConcurrentViews(t *testing.T) {
+	c := newTestCache(t, cachePolicy{maxSize: defaultCacheMaxSize})
+
+	started := make(chan struct{})
+	errc := make(chan error, 1)
+	go func() {
+		errc <- c.view(func(*bbolt.Tx) error {
+			close(started)
+			return nil
+		})
+	}()
+	err := c.view(func(*bbolt.Tx) error {
+		select {
+		case <-started:
+			return nil
+		case <-time.After(time.Second):
+			return errors.New("the other view didn't run concurrently")
+		}
+	})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := <-errc; err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+// A db which is set up isn't written to when it's opened again.
+func TestCacheReopenDoesntWrite(t *testing.T) {
+	c := newTestCache(t, cachePolicy{maxSize: defaultCacheMaxSize})
+	c.Close()
+
+	db, err := openCacheDB(c.path, false, cacheOpenTimeout)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer db.Close()
+	if n := db.Stats().TxStats.Write; n != 0 {
+		t.Errorf("got %v writes, want 0", n)
+	}
+}
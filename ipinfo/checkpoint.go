@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// bulkCheckpoint records which inputs of a bulk lookup already had their
+// results output, so that rerunning the same lookup can skip them.
+//
+// The checkpoint file has one input per line and is only ever appended to.
+// Inputs are recorded after their results were flushed to the output, so an
+// interrupted run may output the last batch again when resumed, but never
+// skips one.
+type bulkCheckpoint struct {
+	f    *os.File
+	done map[string]struct{}
+
+	// the output file the results are written to, if any, which is synced
+	// before inputs are recorded.
+	out *os.File
+}
+
+// openBulkCheckpoint opens the checkpoint file at `path`, creating it if it
+// doesn't exist yet, and loads all inputs recorded in it. Results are output
+// to the file `out`, or stdout if it's nil.
+func openBulkCheckpoint(path string, out *os.File) (*bulkCheckpoint, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't open checkpoint file: %w", err)
+	}
+
+	done := make(map[string]struct{})
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		k := strings.TrimSpace(scanner.Text())
+		if k != "" {
+			done[k] = struct{}{}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("couldn't read checkpoint file: %w", err)
+	}
+
+	return &bulkCheckpoint{f: f, done: done, out: out}, nil
+}
+
+// Resumed reports whether a previous run already recorded any inputs.
+func (c *bulkCheckpoint) Resumed() bool {
+	return len(c.done) > 0
+}
+
+// Done reports whether results for input `k` were output by a previous run.
+func (c *bulkCheckpoint) Done(k string) bool {
+	_, ok := c.done[k]
+	return ok
+}
+
+// Record durably marks all `keys` as done for future runs, once their results
+// written to the output file are durable too.
+func (c *bulkCheckpoint) Record(keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	if c.out != nil {
+		if err := c.out.Sync(); err != nil {
+			return fmt.Errorf("couldn't write output file: %w", err)
+		}
+	}
+
+	var b strings.Builder
+	for _, k := range keys {
+		b.WriteString(k)
+		b.WriteByte('\n')
+	}
+	if _, err := c.f.WriteString(b.String()); err != nil {
+		return fmt.Errorf("couldn't write checkpoint file: %w", err)
+	}
+	return c.f.Sync()
+}
+
+// Close closes the checkpoint file.
+func (c *bulkCheckpoint) Close() error {
+	return c.f.Close()
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ipinfo/go/v2/ipinfo"
+)
+
+// newTestBatchClient points the global client at a fake batch API which
+// fails every request containing `failIP` while `fail` is set.
+func newTestBatchClient(t *testing.T, failIP string, fail *atomic.Bool) {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var keys []string
+		if err := json.NewDecoder(r.Body).Decode(&keys); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		res := make(map[string]interface{}, len(keys))
+		for _, k := range keys {
+			if k == failIP && fail.Load() {
+				http.Error(w, "unavailable", http.StatusServiceUnavailable)
+				return
+			}
+			res[k] = map[string]string{"ip": k}
+		}
+		json.NewEncoder(w).Encode(res)
+	}))
+	t.Cleanup(srv.Close)
+
+	prevII := ii
+	ii = ipinfo.NewClient(nil, nil, "dummy-token")
+	ii.BaseURL, _ = url.Parse(srv.URL + "/")
+	t.Cleanup(func() { ii = prevII })
+}
+
+func ipSrc(n int) func(func(string) error) error {
+	return func(yield func(string) error) error {
+		for i := 0; i < n; i++ {
+			if err := yield(fmt.Sprintf("1.0.0.%d", i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// An interrupted run resumed from its checkpoint outputs exactly the missing
+// results.
+func TestBulkCheckpointResume(t *testing.T) {
+	var fail atomic.Bool
+	fail.Store(true)
+	newTestBatchClient(t, "1.0.0.15", &fail)
+	path := filepath.Join(t.TempDir(), "job.ckpt")
+	opts := ipinfo.BatchReqOpts{BatchSize: 10, ConcurrentBatchRequestsLimit: 1}
+
+	run := func() (string, error) {
+		cp, err := openBulkCheckpoint(path, nil)
+		if err != nil {
+			t.Fatalf("open checkpoint: %v", err)
+		}
+		defer cp.Close()
+
+		var runErr error
+		stdout, _ := captureStd(t, func() {
+			w, err := newCoreStreamWriter(bulkWriterOpts{fields: []string{"ip"}, header: !cp.Resumed()})
+			if err != nil {
+				t.Fatalf("new writer: %v", err)
+			}
+			runErr = streamBulk(ipSrc(25), ii.GetBatch, opts, w, cp)
+		})
+		return stdout, runErr
+	}
+
+	out1, err := run()
+	if err == nil {
+		t.Fatal("expected the first run to fail")
+	}
+	fail.Store(false)
+	out2, err := run()
+	if err != nil {
+		t.Fatalf("unexpected error on resume: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out1+out2), "\n")
+	if lines[0] != "ip" {
+		t.Fatalf("expected header first, got %q", lines[0])
+	}
+	lines = lines[1:]
+	if len(lines) != 25 {
+		t.Fatalf("expected 25 results across both runs, got %d:\n%s", len(lines), out1+out2)
+	}
+	for i, l := range lines {
+		if want := fmt.Sprintf("1.0.0.%d", i); l != want {
+			t.Errorf("line %d: expected %s, got %s", i, want, l)
+		}
+	}
+}
+
+// A run resumed into the same output file appends the missing results to
+// those of the interrupted run.
+func TestBulkCheckpointResumeOutputFile(t *testing.T) {
+	var fail atomic.Bool
+	fail.Store(true)
+	newTestBatchClient(t, "1.0.0.15", &fail)
+	dir := t.TempDir()
+	ckpt, out := filepath.Join(dir, "job.ckpt"), filepath.Join(dir, "out.csv")
+	opts := ipinfo.BatchReqOpts{BatchSize: 10, ConcurrentBatchRequestsLimit: 1}
+
+	run := func() (err error) {
+		f, done, err := appendOutput(out)
+		if err != nil {
+			t.Fatalf("open output: %v", err)
+		}
+		defer func() { err = done(err) }()
+
+		cp, err := openBulkCheckpoint(ckpt, f)
+		if err != nil {
+			t.Fatalf("open checkpoint: %v", err)
+		}
+		defer cp.Close()
+
+		w, err := newCoreStreamWriter(bulkWriterOpts{fields: []string{"ip"}, header: !cp.Resumed()})
+		if err != nil {
+			t.Fatalf("new writer: %v", err)
+		}
+		return streamBulk(ipSrc(25), ii.GetBatch, opts, w, cp)
+	}
+
+	if err := run(); err == nil {
+		t.Fatal("expected the first run to fail")
+	}
+	fail.Store(false)
+	if err := run(); err != nil {
+		t.Fatalf("unexpected error on resume: %v", err)
+	}
+
+	b, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if len(lines) != 26 || lines[0] != "ip" {
+		t.Fatalf("expected a header and 25 results, got:\n%s", b)
+	}
+	for i, l := range lines[1:] {
+		if want := fmt.Sprintf("1.0.0.%d", i); l != want {
+			t.Errorf("line %d: expected %s, got %s", i, want, l)
+		}
+	}
+
+	if _, _, err := appendOutput(out + ".gz"); err == nil {
+		t.Errorf("expected an error appending to a gzipped output")
+	}
+}
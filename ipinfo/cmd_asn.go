@@ -44,7 +44,6 @@ func cmdASNDefault() error {
 
 // cmdASN is the handler for the "asn" command.
 func cmdASN() error {
-	var err error
 	cmd := ""
 	if len(os.Args) > 2 {
 		cmd = os.Args[2]
@@ -52,14 +51,8 @@ func cmdASN() error {
 
 	switch {
 	case cmd == "bulk":
-		err = cmdASNBulk(false)
+		return cmdASNBulk(false)
 	default:
-		err = cmdASNDefault()
+		return cmdASNDefault()
 	}
-
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "err: %v\n", err)
-	}
-
-	return nil
 }
@@ -2,23 +2,44 @@ package main
 
 import (
 	"fmt"
+	"os"
+	"sort"
+
 	"github.com/ipinfo/cli/lib"
 	"github.com/ipinfo/cli/lib/complete"
 	"github.com/ipinfo/cli/lib/complete/predict"
+	"github.com/ipinfo/cli/lib/iputil"
 	"github.com/spf13/pflag"
 )
 
 var completionsASNBulk = &complete.Command{
 	Flags: map[string]complete.Predictor{
-		"-t":        predict.Nothing,
-		"--token":   predict.Nothing,
-		"--nocache": predict.Nothing,
-		"-h":        predict.Nothing,
-		"--help":    predict.Nothing,
-		"-f":        predict.Set(asnFields),
-		"--field":   predict.Set(asnFields),
-		"-j":        predict.Nothing,
-		"--json":    predict.Nothing,
+		"-t":              predict.Nothing,
+		"--token":         predict.Nothing,
+		"--nocache":       predict.Nothing,
+		"--cache-ttl":     predict.Nothing,
+		"--retries":       predict.Nothing,
+		"--max-backoff":   predict.Nothing,
+		"--concurrency":   predict.Nothing,
+		"--batch-size":    predict.Nothing,
+		"--timeout":       predict.Nothing,
+		"--rate":          predict.Nothing,
+		"-h":              predict.Nothing,
+		"--help":          predict.Nothing,
+		"-f":              predict.Set(asnFields),
+		"--field":         predict.Set(asnFields),
+		"-j":              predict.Nothing,
+		"--json":          predict.Nothing,
+		"--checkpoint":    predict.Nothing,
+		"--ndjson":        predict.Nothing,
+		"--jsonl":         predict.Nothing,
+		"--table":         predict.Nothing,
+		"--template":      predict.Nothing,
+		"--format":        predict.Nothing,
+		"--template-file": predict.Nothing,
+		"--output-format": predict.Set([]string{"parquet"}),
+		"-o":              predict.Nothing,
+		"--output":        predict.Nothing,
 	},
 }
 
@@ -40,12 +61,40 @@ Examples:
   # Lookup ASNs from multiple sources simultaneously.
   $ %[1]s asn bulk AS123 AS456 AS789 asns.txt
 
+  # Lookup ASNs in a file into a Parquet file.
+  $ %[1]s asn bulk --output-format parquet -o asns.parquet asns.txt
+
+  # Lookup ASNs in a large file, resuming where a previous run stopped.
+  $ %[1]s asn bulk --checkpoint job.ckpt asns.txt >> results.ndjson
+
 Options:
   General:
     --token <tok>, -t <tok>
       use <tok> as API token.
     --nocache
       do not use the cache.
+    --cache-ttl <ttl>
+      use cached results for at most <ttl>, e.g. '6h' or '7d', or per type
+      of result, e.g. 'core=6h,asn=7d'.
+      default: the config's cache_ttl_<type>, or 1d.
+    --retries <n>
+      retry requests failing with a network error, 5xx or 429 up to <n> times.
+      default: 3.
+    --max-backoff <duration>
+      wait at most <duration> between retries, e.g. '10s' or '1m'.
+      default: 30s.
+    --concurrency <n>
+      send at most <n> batch requests at once.
+      default: the config's concurrency, or 20.
+    --batch-size <n>
+      look up at most <n> inputs per batch request, up to 1000.
+      default: the config's batch_size, or 1000.
+    --timeout <duration>
+      time out each batch request after <duration>, e.g. '30s' or '5m'.
+      default: the config's timeout, or 30m.
+    --rate <req/s>
+      send at most <req/s> requests per second, e.g. '5' or '0.5'.
+      default: the config's rate, or unlimited.
     --help, -h
       show help.
 
@@ -54,27 +103,147 @@ Options:
       lookup only specific fields in the output.
       field names correspond to JSON keys, e.g. 'name' or 'registry'.
       multiple field names must be separated by commas.
+      lists can be indexed, e.g. 'prefixes[0].netblock' or
+      'prefixes[].netblock' for all elements, and fields renamed, e.g.
+      'asn as asn_id'.
+    --checkpoint <file>
+      record the ASNs whose results were output in <file>, and skip ASNs
+      already recorded there, so that rerunning the same command only
+      outputs the missing results; append them to the previous output.
+      an --output file is appended to rather than replaced, so it can't be
+      gzipped.
+      results are output as each batch completes, with JSON output written
+      as one compact object per line (NDJSON).
+      headers are only output if <file> is new or empty.
 
   Formats:
     --json, -j
       output JSON format. (default)
     --yaml, -y
       output YAML format.
+    --ndjson, --jsonl
+      output NDJSON format, one compact JSON object per line.
+      with --field, only the selected fields and the ASN are output.
+    --table
+      output the --field selection, or the most common fields by default, as
+      a table with aligned columns.
+      on a terminal, long values like 'name' are truncated to fit the table
+      into its width, and tables taller than it are paged through $PAGER.
+      can't be used with --checkpoint.
+    --template <tmpl>, --format <tmpl>
+      output each result rendered through the Go template <tmpl>, e.g.
+      '{{.ASN}} {{.Name}} {{len .Prefixes}}'.
+      besides the builtin functions, 'countryName', 'flag', 'join' and 'json'
+      are available.
+    --template-file <file>
+      like --template, with the template read from <file>.
+    --output-format parquet
+      output a Parquet file with a typed schema: 'num_ips' is an integer and
+      'prefixes' and 'prefixes6' are lists of nested groups.
+      requires --output; can't be used with --checkpoint.
+    --output <file>, -o <file>
+      write the output to <file> rather than stdout, replacing <file> only
+      once all of it is written.
+      unless a format is chosen, it's inferred from a '.json', '.yaml',
+      '.ndjson' or '.parquet' extension; a '.gz' extension after it gzips
+      the output, except for parquet.
 `, progBase)
 }
 
 // cmdASNBulk is the asn bulk command.
-func cmdASNBulk(piped bool) error {
+func cmdASNBulk(piped bool) (err error) {
+	var fCheckpoint string
+	var fNDJSON bool
+	var fTable bool
+	var fTemplate string
+	var fTemplateFile string
+	var fOutputFormat string
+	var fOutput string
+
 	f := lib.CmdASNBulkFlags{}
 	f.Init()
+	pflag.Var(&fCacheTTL, "cache-ttl", "max age of cached results.")
+	pflag.IntVar(&fRetries, "retries", defaultRetries, "max retries of failed requests.")
+	pflag.DurationVar(&fMaxBackoff, "max-backoff", defaultMaxBackoff, "max wait between retries.")
+	pflag.IntVar(&fConcurrency, "concurrency", 0, "max concurrent batch requests.")
+	pflag.IntVar(&fBatchSize, "batch-size", 0, "max inputs per batch request.")
+	pflag.DurationVar(&fTimeout, "timeout", 0, "timeout of each batch request.")
+	pflag.Float64Var(&fRate, "rate", 0, "max requests per second.")
+	pflag.StringVar(&fCheckpoint, "checkpoint", "", "checkpoint file to resume from.")
+	pflag.BoolVar(&fNDJSON, "ndjson", false, "output NDJSON format.")
+	pflag.BoolVar(&fNDJSON, "jsonl", false, "output NDJSON format.")
+	pflag.BoolVar(&fTable, "table", false, "output table format.")
+	pflag.StringVar(&fTemplate, "template", "", "template to output results with.")
+	pflag.StringVar(&fTemplate, "format", "", "template to output results with.")
+	pflag.StringVar(&fTemplateFile, "template-file", "", "template file to output results with.")
+	pflag.StringVar(&fOutputFormat, "output-format", "", "output format of the --output file.")
+	pflag.StringVarP(&fOutput, "output", "o", "", "file to output to.")
 	pflag.Parse()
 
+	if !f.Help {
+		opts, err := batchReqOpts()
+		if err != nil {
+			return err
+		}
+		f.BatchOpts = opts
+	}
+
+	tmpl, err := prepareOutputTemplate(fTemplate, fTemplateFile)
+	if err != nil {
+		return err
+	}
+	if fCheckpoint != "" && fTable {
+		return lib.UsageErrorf("--table can't be used with --checkpoint")
+	}
+	parquet, err := parseOutputFormat(fOutputFormat, fOutput)
+	if err != nil {
+		return err
+	}
+	if fCheckpoint != "" && parquet {
+		return lib.UsageErrorf("--output-format parquet can't be used with --checkpoint")
+	}
+
+	var out string
+	if !parquet && !f.Help {
+		out = fOutput
+		inferOutputFormat(out, map[string]*bool{
+			"yaml":   &f.Yaml,
+			"ndjson": &fNDJSON,
+		})
+	}
+
+	// resumed runs append to the output of previous ones.
+	var outFile *os.File
+	var done func(error) error
+	if fCheckpoint != "" {
+		outFile, done, err = appendOutput(out)
+	} else {
+		done, err = redirectOutput(out)
+	}
+	if err != nil {
+		return err
+	}
+	defer func() { err = done(err) }()
+
+	wOpts := bulkWriterOpts{
+		fields:  f.Field,
+		yamlFmt: f.Yaml,
+		ndjson:  fNDJSON,
+		tmpl:    tmpl,
+		table:   fTable,
+		header:  true,
+	}
+
 	ii = prepareIpinfoClient(f.Token)
 	var args []string
 	if !piped {
 		args = pflag.Args()[2:]
 	}
 
+	if fCheckpoint != "" && !f.Help {
+		return cmdASNBulkCheckpoint(f, args, fCheckpoint, outFile, wOpts)
+	}
+
 	data, err := lib.CmdASNBulk(f, ii, args, printHelpASNBulk)
 	if err != nil {
 		return err
@@ -83,6 +252,35 @@ func cmdASNBulk(piped bool) error {
 		return nil
 	}
 
+	asns := make([]string, 0, len(data))
+	for asn := range data {
+		asns = append(asns, asn)
+	}
+	sort.Strings(asns)
+
+	if parquet {
+		return outputParquetFile(fOutput, parquetASNSchema(), func(w *parquetWriter) error {
+			for _, asn := range asns {
+				if err := w.Write(data[asn]); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+
+	if tmpl != nil || fTable || fNDJSON {
+		w, err := newASNStreamWriter(wOpts)
+		if err != nil {
+			return err
+		}
+		for _, asn := range asns {
+			if err := w.Write(data[asn]); err != nil {
+				return err
+			}
+		}
+		return w.Flush()
+	}
 	if len(f.Field) > 0 {
 		return outputFieldBatchASNDetails(data, f.Field, false, false)
 	}
@@ -93,3 +291,34 @@ func cmdASNBulk(piped bool) error {
 
 	return outputJSON(data)
 }
+
+// cmdASNBulkCheckpoint is the asn bulk command when resuming from a
+// checkpoint, which outputs results as each batch completes to `out`, or
+// stdout if it's nil.
+func cmdASNBulkCheckpoint(
+	f lib.CmdASNBulkFlags,
+	args []string,
+	checkpoint string,
+	out *os.File,
+	o bulkWriterOpts,
+) error {
+	if ii.Token == "" {
+		return lib.AuthErrorf("bulk lookups require a token; login via `ipinfo init`")
+	}
+
+	cp, err := openBulkCheckpoint(checkpoint, out)
+	if err != nil {
+		return err
+	}
+	defer cp.Close()
+
+	o.header = !cp.Resumed()
+	w, err := newASNStreamWriter(o)
+	if err != nil {
+		return err
+	}
+
+	return streamBulk(func(yield func(string) error) error {
+		return iputil.ASNListFuncFromAllSrcs(args, yield)
+	}, ii.GetBatch, f.BatchOpts, w, cp)
+}
@@ -1,11 +1,11 @@
 package main
 
 import (
-	"errors"
 	"fmt"
 	"net/http"
 
 	"github.com/fatih/color"
+	"github.com/ipinfo/cli/lib"
 	"github.com/ipinfo/cli/lib/complete"
 	"github.com/ipinfo/cli/lib/complete/predict"
 	"github.com/ipinfo/go/v2/ipinfo"
@@ -14,18 +14,28 @@ import (
 
 var completionsASNSingle = &complete.Command{
 	Flags: map[string]complete.Predictor{
-		"-t":        predict.Nothing,
-		"--token":   predict.Nothing,
-		"--nocache": predict.Nothing,
-		"-h":        predict.Nothing,
-		"--help":    predict.Nothing,
-		"-f":        predict.Set(asnFields),
-		"--field":   predict.Set(asnFields),
-		"--nocolor": predict.Nothing,
-		"-p":        predict.Nothing,
-		"--pretty":  predict.Nothing,
-		"-j":        predict.Nothing,
-		"--json":    predict.Nothing,
+		"-t":              predict.Nothing,
+		"--token":         predict.Nothing,
+		"--nocache":       predict.Nothing,
+		"--cache-ttl":     predict.Nothing,
+		"--retries":       predict.Nothing,
+		"--max-backoff":   predict.Nothing,
+		"-h":              predict.Nothing,
+		"--help":          predict.Nothing,
+		"-f":              predict.Set(asnFields),
+		"--field":         predict.Set(asnFields),
+		"--nocolor":       predict.Nothing,
+		"-o":              predict.Nothing,
+		"--output":        predict.Nothing,
+		"-p":              predict.Nothing,
+		"--pretty":        predict.Nothing,
+		"-j":              predict.Nothing,
+		"--json":          predict.Nothing,
+		"--ndjson":        predict.Nothing,
+		"--jsonl":         predict.Nothing,
+		"--template":      predict.Nothing,
+		"--format":        predict.Nothing,
+		"--template-file": predict.Nothing,
 	},
 }
 
@@ -39,6 +49,16 @@ Options:
       use <tok> as API token.
     --nocache
       do not use the cache.
+    --cache-ttl <ttl>
+      use cached results for at most <ttl>, e.g. '6h' or '7d', or per type
+      of result, e.g. 'core=6h,asn=7d'.
+      default: the config's cache_ttl_<type>, or 1d.
+    --retries <n>
+      retry requests failing with a network error, 5xx or 429 up to <n> times.
+      default: 3.
+    --max-backoff <duration>
+      wait at most <duration> between retries, e.g. '10s' or '1m'.
+      default: 30s.
     --help, -h
       show help.
 
@@ -47,30 +67,61 @@ Options:
       lookup only specific fields in the output.
       field names correspond to JSON keys, e.g. 'registry' or 'allocated'.
       multiple field names must be separated by commas.
+      lists can be indexed, e.g. 'prefixes[0].netblock' or
+      'prefixes[].netblock' for all elements, and fields renamed, e.g.
+      'asn as asn_id'.
     --nocolor
       disable colored output.
+    --output <file>, -o <file>
+      write the output to <file> rather than stdout, replacing <file> only
+      once all of it is written.
+      unless a format is chosen, it's inferred from a '.json', '.yaml' or
+      '.ndjson' extension; a '.gz' extension after it gzips the output.
 
   Formats:
     --json, -j
       output JSON format. (default)
     --yaml, -y
       output YAML format.
+    --ndjson, --jsonl
+      output NDJSON format, one compact JSON object per line.
+      with --field, only the selected fields and the ASN are output.
+    --template <tmpl>, --format <tmpl>
+      output the result rendered through the Go template <tmpl>, e.g.
+      '{{.ASN}} {{.Name}} {{len .Prefixes}}'.
+      besides the builtin functions, 'countryName', 'flag', 'join' and 'json'
+      are available.
+    --template-file <file>
+      like --template, with the template read from <file>.
 `, progBase, asn)
 }
 
-func cmdASNSingle(asn string) error {
+func cmdASNSingle(asn string) (err error) {
 	var fTok string
 	var fField []string
 	var fJSON bool
 	var fYAML bool
+	var fNDJSON bool
+	var fTemplate string
+	var fTemplateFile string
+	var fOutput string
 
 	pflag.StringVarP(&fTok, "token", "t", "", "the token to use.")
 	pflag.BoolVar(&fNoCache, "nocache", false, "disable the cache.")
+	pflag.Var(&fCacheTTL, "cache-ttl", "max age of cached results.")
+	pflag.IntVar(&fRetries, "retries", defaultRetries, "max retries of failed requests.")
+	pflag.DurationVar(&fMaxBackoff, "max-backoff", defaultMaxBackoff, "max wait between retries.")
 	pflag.BoolVarP(&fHelp, "help", "h", false, "show help.")
 	pflag.StringSliceVarP(&fField, "field", "f", nil, "specific field to lookup.")
 	pflag.BoolVarP(&fJSON, "json", "j", true, "output JSON format. (default)")
 	pflag.BoolVarP(&fYAML, "yaml", "y", false, "output YAML format.")
+	pflag.BoolVar(&fNDJSON, "ndjson", false, "output NDJSON format.")
+	pflag.BoolVar(&fNDJSON, "jsonl", false, "output NDJSON format.")
+	pflag.StringVar(&fTemplate, "template", "", "template to output results with.")
+	pflag.StringVar(&fTemplate, "format", "", "template to output results with.")
+	pflag.StringVar(&fTemplateFile, "template-file", "", "template file to output results with.")
 	pflag.BoolVar(&fNoColor, "nocolor", false, "disable color output.")
+	pflag.StringVarP(&fOutput, "output", "o", "", "file to output to.")
 	pflag.Parse()
 
 	if fNoColor {
@@ -82,22 +133,44 @@ func cmdASNSingle(asn string) error {
 		return nil
 	}
 
+	inferOutputFormat(fOutput, map[string]*bool{
+		"json":   &fJSON,
+		"yaml":   &fYAML,
+		"ndjson": &fNDJSON,
+	})
+	done, err := redirectOutput(fOutput)
+	if err != nil {
+		return err
+	}
+	defer func() { err = done(err) }()
+
+	tmpl, err := prepareOutputTemplate(fTemplate, fTemplateFile)
+	if err != nil {
+		return err
+	}
+
 	ii = prepareIpinfoClient(fTok)
 
 	// require token for ASN API.
 	if ii.Token == "" {
-		return errors.New("ASN lookups require a token; login via `ipinfo init`.")
+		return lib.AuthErrorf("ASN lookups require a token; login via `ipinfo init`.")
 	}
 
 	data, err := ii.GetASNDetails(asn)
 	if err != nil {
 		iiErr, ok := err.(*ipinfo.ErrorResponse)
 		if ok && (iiErr.Response.StatusCode == http.StatusUnauthorized) {
-			return errors.New("Token does not have access to ASN API")
+			return lib.ErrWithInput(lib.AuthErrorf("Token does not have access to ASN API"), asn)
 		}
-		return err
+		return lib.ErrWithInput(err, asn)
 	}
 
+	if tmpl != nil {
+		return outputTemplate(tmpl, data)
+	}
+	if fNDJSON {
+		return outputNDJSONASNDetails(data, fField)
+	}
 	if len(fField) > 0 {
 		d := make(ipinfo.BatchASNDetails, 1)
 		d[data.ASN] = data
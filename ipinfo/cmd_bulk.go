@@ -1,41 +1,76 @@
 package main
 
 import (
-	"errors"
 	"fmt"
 	"net"
+	"os"
 
 	"github.com/fatih/color"
+	"github.com/ipinfo/cli/lib"
 	"github.com/ipinfo/cli/lib/complete"
 	"github.com/ipinfo/cli/lib/complete/predict"
 	"github.com/ipinfo/cli/lib/iputil"
-	"github.com/ipinfo/go/v2/ipinfo"
 	"github.com/spf13/pflag"
 )
 
 var completionsBulk = &complete.Command{
 	Flags: map[string]complete.Predictor{
-		"-t":        predict.Nothing,
-		"--token":   predict.Nothing,
-		"--nocache": predict.Nothing,
-		"-h":        predict.Nothing,
-		"--help":    predict.Nothing,
-		"-f":        predict.Set(coreFields),
-		"--field":   predict.Set(coreFields),
-		"--nocolor": predict.Nothing,
-		"-j":        predict.Nothing,
-		"--json":    predict.Nothing,
-		"-c":        predict.Nothing,
-		"--csv":     predict.Nothing,
+		"-t":                predict.Nothing,
+		"--token":           predict.Nothing,
+		"--nocache":         predict.Nothing,
+		"--cache-ttl":       predict.Nothing,
+		"--retries":         predict.Nothing,
+		"--max-backoff":     predict.Nothing,
+		"--concurrency":     predict.Nothing,
+		"--batch-size":      predict.Nothing,
+		"--timeout":         predict.Nothing,
+		"--rate":            predict.Nothing,
+		"--db":              predict.Nothing,
+		"--resolver":        predict.Nothing,
+		"-h":                predict.Nothing,
+		"--help":            predict.Nothing,
+		"-f":                predict.Set(coreFields),
+		"--field":           predict.Set(coreFields),
+		"--nocolor":         predict.Nothing,
+		"-j":                predict.Nothing,
+		"--json":            predict.Nothing,
+		"-c":                predict.Nothing,
+		"--csv":             predict.Nothing,
+		"--no-header":       predict.Nothing,
+		"--delimiter":       predict.Set([]string{"tab", "|", ";"}),
+		"--columns":         predict.Set(coreCSVColumns()),
+		"--ndjson":          predict.Nothing,
+		"--jsonl":           predict.Nothing,
+		"--table":           predict.Nothing,
+		"--template":        predict.Nothing,
+		"--format":          predict.Nothing,
+		"--template-file":   predict.Nothing,
+		"--output-format":   predict.Set([]string{"parquet"}),
+		"-o":                predict.Nothing,
+		"--output":          predict.Nothing,
+		"--stream":          predict.Nothing,
+		"--checkpoint":      predict.Nothing,
+		"--keep-going":      predict.Nothing,
+		"--errors-to":       predict.Nothing,
+		"--fail-on-error":   predict.Nothing,
+		"--sort":            predict.Set([]string{"ip", "input"}),
+		"--keep-duplicates": predict.Nothing,
 	},
 }
 
 func printHelpBulk() {
 	fmt.Printf(
-		`Usage: %s bulk [<opts>] <ip | ip-range | cidr | filepath>
+		`Usage: %s bulk [<opts>] <ip | ip-range | cidr | hostname | filepath>
 
 Description:
-  Accepts IPs, IP ranges, CIDRs and file paths.
+  Accepts IPs, IP ranges, CIDRs, hostnames and file paths.
+
+  Hostnames are resolved to all of their IPv4 and IPv6 addresses, whose
+  results are tagged with the hostname: in JSON and YAML each hostname maps to
+  the list of its results, and CSV and the field selection get a 'host' column.
+  Hostnames must be fully qualified, e.g. 'example.com' but not 'localhost',
+  and aren't supported with --stream or --checkpoint; any other input is
+  skipped with a warning.
 
 Examples:
   # Lookup all IPs from stdin ('-' can be implied).
@@ -57,12 +92,65 @@ Examples:
   # Lookup all IPs from multiple sources simultaneously.
   $ %[1]s bulk 8.8.8.0-8.8.8.255 1.1.1.0/30 123.123.123.123 ips.txt
 
+  # Lookup all IPs of all domains in a log file.
+  $ %[1]s grepdomain -o access.log | %[1]s bulk
+
+  # Lookup all IPs from a CIDR offline, in a local database.
+  $ %[1]s bulk --db country_asn.mmdb 8.8.8.0/24
+
+  # Lookup all IPs in a large file, outputting results as they arrive.
+  $ %[1]s bulk --stream /path/to/huge-iplist.txt
+
+  # Lookup all IPs in a log, with a CSV row per line in the same order.
+  $ %[1]s grepip -o access.log | %[1]s bulk --keep-duplicates --csv
+
+  # Lookup all IPs in a file, reporting those that failed separately.
+  $ %[1]s bulk --errors-to failed.ndjson /path/to/iplist.txt
+
+  # Lookup all IPs in a file into a gzipped CSV file.
+  $ %[1]s bulk -o results.csv.gz /path/to/iplist.txt
+
+  # Lookup all IPs in a file into a Parquet file.
+  $ %[1]s bulk --output-format parquet -o results.parquet /path/to/iplist.txt
+
+  # Lookup all IPs in a large file, resuming where a previous run stopped.
+  $ %[1]s bulk --checkpoint job.ckpt /path/to/huge-iplist.txt >> results.ndjson
+
 Options:
   General:
     --token <tok>, -t <tok>
       use <tok> as API token.
     --nocache
       do not use the cache.
+    --cache-ttl <ttl>
+      use cached results for at most <ttl>, e.g. '6h' or '7d', or per type
+      of result, e.g. 'core=6h,asn=7d'.
+      default: the config's cache_ttl_<type>, or 1d.
+    --retries <n>
+      retry requests failing with a network error, 5xx or 429 up to <n> times.
+      default: 3.
+    --max-backoff <duration>
+      wait at most <duration> between retries, e.g. '10s' or '1m'.
+      default: 30s.
+    --concurrency <n>
+      send at most <n> batch requests at once.
+      default: the config's concurrency, or 20.
+    --batch-size <n>
+      look up at most <n> inputs per batch request, up to 1000.
+      default: the config's batch_size, or 1000.
+    --timeout <duration>
+      time out each batch request after <duration>, e.g. '30s' or '5m'.
+      default: the config's timeout, or 30m.
+    --rate <req/s>
+      send at most <req/s> requests per second, e.g. '5' or '0.5'.
+      default: the config's rate, or unlimited.
+    --db <path>
+      lookup from the mmdb file at <path> instead of the API.
+      doesn't require a token. defaults to the config's db, if set.
+    --resolver <addr>
+      resolve hostnames with the DNS server at <addr> instead of the system's
+      resolver; either <ip>[:<port>], tls://<host>[:<port>] for DNS-over-TLS
+      or an https:// URL for DNS-over-HTTPS.
     --help, -h
       show help.
 
@@ -71,35 +159,165 @@ Options:
       lookup only specific fields in the output.
       field names correspond to JSON keys, e.g. 'hostname' or 'company.type'.
       multiple field names must be separated by commas.
+      lists can be indexed, e.g. 'domains.domains[0]' or 'domains.domains[]'
+      for all elements, and fields renamed, e.g. 'asn.id as asn_id'.
     --nocolor
       disable colored output.
+    --stream
+      read input lazily and output results as each batch completes, rather
+      than all at once at the end.
+      results are output in input order; IPs repeated within a batch are
+      only output once.
+      JSON output is written as one compact object per line (NDJSON).
+    --checkpoint <file>
+      record the IPs whose results were output in <file>, and skip IPs
+      already recorded there, so that rerunning the same command only
+      outputs the missing results; append them to the previous output.
+      an --output file is appended to rather than replaced, so it can't be
+      gzipped.
+      headers are only output if <file> is new or empty.
+      implies --stream.
+    --keep-going
+      don't stop when a batch fails; instead output its IPs with an 'error'
+      field in JSON and YAML, or an 'error' column in CSV and the field
+      selection, and exit successfully after warning about the failures.
+      IPs which failed are not recorded in a --checkpoint file.
+    --errors-to <file>
+      write the failed IPs with their error to <file>, one JSON object per
+      line, rather than to the output.
+      implies --keep-going.
+    --fail-on-error
+      exit with an error if any IPs failed.
+      implies --keep-going.
+    --sort <ip | input>
+      output results sorted numerically by IP, or in input order.
+      by default, results are in no particular order.
+      only 'input' can be used with --stream, which always keeps input order.
+    --keep-duplicates
+      output a result for every input IP, even repeated ones, so that results
+      line up with the input line by line; in input order unless sorted by IP.
+      JSON and YAML output a list of results rather than a map by IP.
+      can't be used with --stream.
 
   Formats:
     --json, -j
       output JSON format. (default)
     --csv, -c
-      output CSV format.
+      output CSV format, with the columns:
+        ip, hostname, bogon, anycast, city, region, country, country_name,
+        country_flag_emoji, country_flag_unicode, country_flag_url,
+        country_currency_code, country_currency_symbol, continent_code,
+        continent_name, isEU, loc, org, postal, timezone,
+        asn_id, asn_asn, asn_domain, asn_route, asn_type,
+        company_name, company_domain, company_type,
+        carrier_name, carrier_mcc, carrier_mnc,
+        privacy_vpn, privacy_proxy, privacy_tor, privacy_relay,
+        privacy_hosting, privacy_service,
+        abuse_address, abuse_country, abuse_country_name, abuse_email,
+        abuse_name, abuse_network, abuse_phone,
+        domains_total.
+      cells of data not available to the token are empty.
+    --columns <columns>
+      output only the CSV <columns>, in the order given, separated by commas.
+      the AS name can also be selected as 'asn_name'.
+      implies --csv.
+    --delimiter <char>
+      separate CSV columns by <char>, e.g. '|', or 'tab' for TSV.
+      default: ','.
+    --no-header
+      don't output the header of CSV and the field selection.
     --yaml, -y
       output YAML format.
+    --ndjson, --jsonl
+      output NDJSON format, one compact JSON object per line.
+      with --field, only the selected fields and the IP are output.
+    --table
+      output the --field selection, or the most common fields by default, as
+      a table with aligned columns.
+      on a terminal, long values like 'org' are truncated to fit the table
+      into its width, and tables taller than it are paged through $PAGER.
+      can't be used with --stream or --checkpoint.
+    --template <tmpl>, --format <tmpl>
+      output each result rendered through the Go template <tmpl>, e.g.
+      '{{.IP}} {{.Country}}'; results of hosts also have a '.Host'.
+      besides the builtin functions, 'countryName', 'flag', 'join' and 'json'
+      are available.
+    --template-file <file>
+      like --template, with the template read from <file>.
+    --output-format parquet
+      output a Parquet file with a typed schema: 'loc' is split into
+      'latitude' and 'longitude' doubles, booleans are booleans, and objects
+      like 'privacy' and 'company' are nested groups.
+      requires --output; can't be used with --stream or --checkpoint.
+    --output <file>, -o <file>
+      write the output to <file> rather than stdout, replacing <file> only
+      once all of it is written.
+      unless a format is chosen, it's inferred from a '.json', '.csv',
+      '.yaml', '.ndjson' or '.parquet' extension; a '.gz' extension after it
+      gzips the output, except for parquet.
 `, progBase)
 }
 
 func cmdBulk() (err error) {
-	var ips []net.IP
 	var fTok string
+	var fResolver string
 	var fField []string
 	var fJSON bool
 	var fCSV bool
+	var fColumns []string
+	var fDelimiter string
+	var fNoHeader bool
 	var fYAML bool
+	var fNDJSON bool
+	var fTable bool
+	var fTemplate string
+	var fTemplateFile string
+	var fOutputFormat string
+	var fOutput string
+	var fStream bool
+	var fCheckpoint string
+	var fDB string
+	var fKeepGoing bool
+	var fErrorsTo string
+	var fFailOnError bool
+	var fSort string
+	var fKeepDuplicates bool
 
 	pflag.StringVarP(&fTok, "token", "t", "", "the token to use.")
 	pflag.BoolVar(&fNoCache, "nocache", false, "disable the cache.")
+	pflag.Var(&fCacheTTL, "cache-ttl", "max age of cached results.")
+	pflag.IntVar(&fRetries, "retries", defaultRetries, "max retries of failed requests.")
+	pflag.DurationVar(&fMaxBackoff, "max-backoff", defaultMaxBackoff, "max wait between retries.")
+	pflag.IntVar(&fConcurrency, "concurrency", 0, "max concurrent batch requests.")
+	pflag.IntVar(&fBatchSize, "batch-size", 0, "max inputs per batch request.")
+	pflag.DurationVar(&fTimeout, "timeout", 0, "timeout of each batch request.")
+	pflag.Float64Var(&fRate, "rate", 0, "max requests per second.")
+	pflag.StringVar(&fDB, "db", "", "mmdb file to lookup from.")
+	pflag.StringVar(&fResolver, "resolver", "", "DNS server to resolve with.")
 	pflag.BoolVarP(&fHelp, "help", "h", false, "show help.")
 	pflag.StringSliceVarP(&fField, "field", "f", nil, "specific field to lookup.")
 	pflag.BoolVarP(&fJSON, "json", "j", true, "output JSON format. (default)")
 	pflag.BoolVarP(&fCSV, "csv", "c", false, "output CSV format.")
+	pflag.StringSliceVar(&fColumns, "columns", nil, "CSV columns to output.")
+	pflag.StringVar(&fDelimiter, "delimiter", "", "CSV delimiter.")
+	pflag.BoolVar(&fNoHeader, "no-header", false, "don't output a header.")
 	pflag.BoolVarP(&fYAML, "yaml", "y", false, "output YAML format.")
+	pflag.BoolVar(&fNDJSON, "ndjson", false, "output NDJSON format.")
+	pflag.BoolVar(&fNDJSON, "jsonl", false, "output NDJSON format.")
+	pflag.BoolVar(&fTable, "table", false, "output table format.")
+	pflag.StringVar(&fTemplate, "template", "", "template to output results with.")
+	pflag.StringVar(&fTemplate, "format", "", "template to output results with.")
+	pflag.StringVar(&fTemplateFile, "template-file", "", "template file to output results with.")
+	pflag.StringVar(&fOutputFormat, "output-format", "", "output format of the --output file.")
+	pflag.StringVarP(&fOutput, "output", "o", "", "file to output to.")
 	pflag.BoolVar(&fNoColor, "nocolor", false, "disable color output.")
+	pflag.BoolVar(&fStream, "stream", false, "output results as each batch completes.")
+	pflag.StringVar(&fCheckpoint, "checkpoint", "", "checkpoint file to resume from.")
+	pflag.BoolVar(&fKeepGoing, "keep-going", false, "report failed batches and keep going.")
+	pflag.StringVar(&fErrorsTo, "errors-to", "", "file to report failures to.")
+	pflag.BoolVar(&fFailOnError, "fail-on-error", false, "exit with an error on any failure.")
+	pflag.StringVar(&fSort, "sort", "", "sort results by 'ip' or 'input'.")
+	pflag.BoolVar(&fKeepDuplicates, "keep-duplicates", false, "output a result per input IP.")
 	pflag.Parse()
 
 	if fNoColor {
@@ -111,40 +329,209 @@ func cmdBulk() (err error) {
 		return nil
 	}
 
-	ips, err = iputil.IPListFromAllSrcs(pflag.Args()[1:])
+	opts, err := batchReqOpts()
+	if err != nil {
+		return err
+	}
+	tmpl, err := prepareOutputTemplate(fTemplate, fTemplateFile)
+	if err != nil {
+		return err
+	}
+	delimiter, err := parseCSVDelimiter(fDelimiter)
 	if err != nil {
 		return err
 	}
-	if len(ips) == 0 {
+	if len(fColumns) > 0 {
+		fCSV = true
+	}
+	parquet, err := parseOutputFormat(fOutputFormat, fOutput)
+	if err != nil {
+		return err
+	}
+
+	if fSort != "" && fSort != "ip" && fSort != "input" {
+		return lib.UsageErrorf("invalid sort %v; must be 'ip' or 'input'", fSort)
+	}
+	if (fStream || fCheckpoint != "") && (fSort == "ip" || fKeepDuplicates) {
+		return lib.UsageErrorf("--sort ip and --keep-duplicates can't be used with --stream or --checkpoint")
+	}
+	if (fStream || fCheckpoint != "") && fTable {
+		return lib.UsageErrorf("--table can't be used with --stream or --checkpoint")
+	}
+	if (fStream || fCheckpoint != "") && parquet {
+		return lib.UsageErrorf("--output-format parquet can't be used with --stream or --checkpoint")
+	}
+
+	// Parquet is written to the output file directly, and other formats to
+	// stdout redirected to it.
+	var out, parquetOut string
+	if parquet {
+		parquetOut = fOutput
+	} else {
+		out = fOutput
+		inferOutputFormat(out, map[string]*bool{
+			"json":   &fJSON,
+			"csv":    &fCSV,
+			"yaml":   &fYAML,
+			"ndjson": &fNDJSON,
+		})
+	}
+
+	// resumed runs append to the output of previous ones.
+	var outFile *os.File
+	var done func(error) error
+	if fCheckpoint != "" {
+		outFile, done, err = appendOutput(out)
+	} else {
+		done, err = redirectOutput(out)
+	}
+	if err != nil {
+		return err
+	}
+	defer func() { err = done(err) }()
+
+	keepGoing := fKeepGoing || fErrorsTo != "" || fFailOnError
+	var errs *bulkErrorsWriter
+	if fErrorsTo != "" {
+		errs, err = createBulkErrorsWriter(fErrorsTo)
+		if err != nil {
+			return err
+		}
+		defer errs.Close()
+	}
+
+	if fStream || fCheckpoint != "" {
+		var cp *bulkCheckpoint
+		if fCheckpoint != "" {
+			cp, err = openBulkCheckpoint(fCheckpoint, outFile)
+			if err != nil {
+				return err
+			}
+			defer cp.Close()
+		}
+
+		w, err := newCoreStreamWriter(bulkWriterOpts{
+			fields:    fField,
+			csvFmt:    fCSV,
+			yamlFmt:   fYAML,
+			ndjson:    fNDJSON,
+			tmpl:      tmpl,
+			columns:   fColumns,
+			delimiter: delimiter,
+			header:    !fNoHeader && (cp == nil || !cp.Resumed()),
+			errCol:    keepGoing && errs == nil,
+		})
+		if err != nil {
+			return err
+		}
+		w.errs = errs
+
+		l, err := prepareIPLookuper(fTok, fDB, true)
+		if err != nil {
+			return err
+		}
+		lookup := l.GetBatch
+		if keepGoing {
+			lookup = keepGoingLookup(lookup)
+		}
+
+		err = streamBulkCore(func(yield func(net.IP) error) error {
+			return iputil.IPListFuncFromAllSrcs(pflag.Args()[1:], yield, func(input string) {
+				fmt.Fprintf(os.Stderr, "warn: skipping %v, which isn't an IP, IP range or CIDR\n", input)
+			})
+		}, lookup, opts, w, cp)
+		if err != nil {
+			return err
+		}
+		return reportBulkFailures(w.Failed(), fFailOnError)
+	}
+
+	ips, hosts, err := bulkInputsFromAllSrcs(pflag.Args()[1:])
+	if err != nil {
+		return err
+	}
+	if len(ips) == 0 && len(hosts) == 0 {
 		fmt.Println("no input ips")
 		return nil
 	}
 
-	ii = prepareIpinfoClient(fTok)
+	// require token for bulk, unless answering from an mmdb file.
+	l, err := prepareIPLookuper(fTok, fDB, true)
+	if err != nil {
+		return err
+	}
+
+	if len(hosts) == 0 && !keepGoing && fSort == "" && !fKeepDuplicates && !fNDJSON && tmpl == nil && !fTable && !fCSV && !fNoHeader && !parquet {
+		data, err := l.GetIPInfoBatch(ips, opts)
+		if err != nil {
+			return err
+		}
+
+		if len(fField) > 0 {
+			return outputFieldBatchCore(data, fField, true, true)
+		}
 
-	// require token for bulk.
-	if ii.Token == "" {
-		return errors.New("bulk lookups require a token; login via `ipinfo init`.")
+		if fYAML {
+			return outputYAML(data)
+		}
+
+		return outputJSON(data)
 	}
 
-	data, err := ii.GetIPInfoBatch(ips, ipinfo.BatchReqOpts{
-		TimeoutPerBatch:              60 * 30, // 30min
-		ConcurrentBatchRequestsLimit: 20,
-	})
+	// resolve all hosts, looking up each of their IPs along with the rest
+	// only once.
+	resolver, err := newResolver(fResolver, nil)
 	if err != nil {
 		return err
 	}
-
-	if len(fField) > 0 {
-		return outputFieldBatchCore(data, fField, true, true)
+	var resolved []hostIPs
+	var lookupIPs []net.IP
+	seen := make(map[string]struct{})
+	addLookupIPs := func(ips []net.IP) {
+		for _, ip := range ips {
+			if _, ok := seen[ip.String()]; !ok {
+				seen[ip.String()] = struct{}{}
+				lookupIPs = append(lookupIPs, ip)
+			}
+		}
+	}
+	addLookupIPs(ips)
+	for _, r := range resolveHosts(resolver, hosts, defaultResolveConcurrency) {
+		if r.err != nil && !keepGoing {
+			fmt.Fprintf(os.Stderr, "warn: couldn't resolve %v\n", r.host)
+			continue
+		}
+		resolved = append(resolved, r)
+		addLookupIPs(r.ips)
 	}
 
-	if fCSV {
-		return outputCSVBatchCore(data)
+	lookup := l.GetBatch
+	if keepGoing {
+		lookup = keepGoingLookup(lookup)
 	}
-	if fYAML {
-		return outputYAML(data)
+	data, err := lookupBulk(lookupIPs, lookup, opts)
+	if err != nil {
+		return err
 	}
 
-	return outputJSON(data)
+	failed, err := outputBulkResults(ips, resolved, data, bulkOutputOpts{
+		fields:         fField,
+		csvFmt:         fCSV,
+		columns:        fColumns,
+		delimiter:      delimiter,
+		noHeader:       fNoHeader,
+		yamlFmt:        fYAML,
+		ndjson:         fNDJSON,
+		tmpl:           tmpl,
+		table:          fTable,
+		parquet:        parquetOut,
+		errCol:         keepGoing && errs == nil,
+		errs:           errs,
+		sortBy:         fSort,
+		keepDuplicates: fKeepDuplicates,
+	})
+	if err != nil {
+		return err
+	}
+	return reportBulkFailures(failed, fFailOnError)
 }
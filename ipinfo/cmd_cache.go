@@ -1,10 +1,15 @@
 package main
 
 import (
+	"compress/gzip"
 	"fmt"
+	"io"
 	"os"
 	"strings"
+	"time"
 
+	"github.com/fatih/color"
+	"github.com/ipinfo/cli/lib"
 	"github.com/ipinfo/cli/lib/complete"
 	"github.com/ipinfo/cli/lib/complete/predict"
 	"github.com/spf13/pflag"
@@ -12,42 +17,138 @@ import (
 
 var completionsCache = &complete.Command{
 	Flags: map[string]complete.Predictor{
-		"-h":     predict.Nothing,
-		"--help": predict.Nothing,
+		"--cache-ttl":  predict.Nothing,
+		"--older-than": predict.Nothing,
+		"-j":           predict.Nothing,
+		"--json":       predict.Nothing,
+		"-o":           predict.Nothing,
+		"--output":     predict.Nothing,
+		"--nocolor":    predict.Nothing,
+		"-h":           predict.Nothing,
+		"--help":       predict.Nothing,
 	},
 	Args: predict.Set([]string{
 		"clear",
+		"policy",
+		"stats",
+		"get",
+		"list",
+		"export",
+		"import",
 	}),
 }
 
 func printHelpCache() {
 	fmt.Printf(
-		`Usage: %s cache [<opts>] [clear]
+		`Usage: %s cache [<opts>] <subcommand>
 
 Description:
   Manage the local cache that stores results previously seen.
 
+Subcommands:
+  clear
+    delete all data in the cache.
+  policy
+    show how long results of each type are cached, how large the cache may
+    grow, and where each of these is set.
+  stats
+    show the number of entries of each type, the size of the cache, its hits
+    and misses, and when its oldest and newest entries were cached.
+  get <ip | asn>
+    output the cached result of an IP or ASN with when it was cached and last
+    accessed, as JSON.
+  list
+    list the entries in the cache with when they were cached and last
+    accessed.
+  export
+    output all entries in the cache as NDJSON, for importing elsewhere.
+  import [<file>]
+    import entries exported as NDJSON from <file>, or stdin if none or '-';
+    entries older than those already cached are skipped.
+
 Examples:
   # Clear all data currently in the cache.
   $ %[1]s cache clear
 
+  # Show how long results of each type are cached, and where that's set.
+  $ %[1]s cache policy
+
+  # Show the policy of commands run with a TTL override.
+  $ %[1]s cache policy --cache-ttl asn=7d
+
+  # Show the cached result of an IP.
+  $ %[1]s cache get 8.8.8.8
+
+  # List the entries cached more than 12 hours ago.
+  $ %[1]s cache list --older-than 12h
+
+  # Ship a warmed cache to another machine.
+  $ %[1]s cache export -o cache.ndjson.gz
+  $ %[1]s cache import cache.ndjson.gz
+
 Options:
+  --cache-ttl <ttl>
+    with policy, show the policy with <ttl> as the TTL, as with the lookup
+    commands, e.g. '6h' or '7d', or per type of result, e.g. 'core=6h,asn=7d'.
+  --older-than <age>
+    with list, list only entries cached more than <age> ago, e.g. '12h' or
+    '7d'.
+  --json, -j
+    with stats, output JSON format.
+  --output <file>, -o <file>
+    with export, write the entries to <file> rather than stdout, replacing
+    <file> only once all of them are written; a '.gz' extension gzips them.
+  --nocolor
+    disable colored output.
   --help, -h
     show help.
 `, progBase)
 }
 
 func cmdCache() error {
+	var fOlderThan string
+	var fJSON bool
+	var fOutput string
+
+	pflag.Var(&fCacheTTL, "cache-ttl", "max age of cached results.")
+	pflag.StringVar(&fOlderThan, "older-than", "", "min age of listed entries.")
+	pflag.BoolVarP(&fJSON, "json", "j", false, "output JSON format.")
+	pflag.StringVarP(&fOutput, "output", "o", "", "file to output to.")
+	pflag.BoolVar(&fNoColor, "nocolor", false, "disable color output.")
 	pflag.BoolVarP(&fHelp, "help", "h", false, "show help.")
 	pflag.Parse()
 
+	if fNoColor {
+		color.NoColor = true
+	}
+
 	args := pflag.Args()[1:]
-	if fHelp || len(args) != 1 {
+	if fHelp || len(args) == 0 {
 		printHelpCache()
 		return nil
 	}
 
-	switch strings.ToLower(args[0]) {
+	subcmd, args := strings.ToLower(args[0]), args[1:]
+	switch subcmd {
+	case "clear", "policy", "stats", "list", "export":
+		if len(args) > 0 {
+			return lib.UsageErrorf("%s takes no arguments", subcmd)
+		}
+	case "get":
+		if len(args) != 1 {
+			return lib.UsageErrorf("get takes one IP or ASN")
+		}
+	case "import":
+		if len(args) > 1 {
+			return lib.UsageErrorf("import takes at most one file")
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "err: %s is not a valid subcommand\n\n", subcmd)
+		printHelpCache()
+		return nil
+	}
+
+	switch subcmd {
 	case "clear":
 		path, err := BoltdbCachePath()
 		if err != nil {
@@ -58,13 +159,208 @@ func cmdCache() error {
 		if err := os.Remove(path); err != nil {
 			return fmt.Errorf("error clearing cache: %w", err)
 		}
-	default:
-		fmt.Fprintf(os.Stderr, "err: %s is not a valid subcommand\n\n", args[0])
-		printHelpCache()
+
+		fmt.Println("cache cleared")
 		return nil
+	case "policy":
+		return printCachePolicy()
 	}
 
-	fmt.Println("cache cleared")
+	var olderThan time.Duration
+	if fOlderThan != "" {
+		var err error
+		olderThan, err = parseCacheTTL(fOlderThan)
+		if err != nil {
+			return lib.UsageErrorf("invalid --older-than: %v", err)
+		}
+	}
 
+	cache, err := NewBoltdbCache(effectiveCachePolicy())
+	if err != nil {
+		return err
+	}
+	defer cache.Close()
+
+	switch subcmd {
+	case "stats":
+		stats, err := cache.Stats()
+		if err != nil {
+			return err
+		}
+		if fJSON {
+			return outputJSON(stats)
+		}
+		printCacheStats(stats)
+	case "get":
+		r, err := cache.GetRecord(cacheKeyOf(args[0]))
+		if err == nil && r == nil {
+			// the key of e.g. `myip` isn't an IP or ASN.
+			r, err = cache.GetRecord(args[0])
+		}
+		if err != nil {
+			return lib.ErrWithInput(err, args[0])
+		}
+		if r == nil {
+			return lib.ErrWithInput(fmt.Errorf("%s is not in the cache", args[0]), args[0])
+		}
+		return outputJSON(r)
+	case "list":
+		table := &resultsTable{
+			hdrs:   []string{"key", "type", "created", "last_accessed"},
+			header: true,
+		}
+		err := cache.ForEachRecord(func(r *cacheRecord) error {
+			if olderThan > 0 && time.Since(r.Created) <= olderThan {
+				return nil
+			}
+			table.Append([]string{
+				r.Key,
+				r.Type,
+				r.Created.Local().Format(time.RFC3339),
+				r.LastAccessed.Local().Format(time.RFC3339),
+			})
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		return table.Output(os.Stdout)
+	case "export":
+		return exportCache(cache, fOutput)
+	case "import":
+		r, closeInput, err := openCacheImport(args)
+		if err != nil {
+			return err
+		}
+		defer closeInput()
+
+		imported, skipped, err := cache.Import(r)
+		if err != nil {
+			return fmt.Errorf("error importing cache: %w", err)
+		}
+		fmt.Printf("imported %d entries, skipped %d older than cached ones\n", imported, skipped)
+	}
+	return nil
+}
+
+// exportCache outputs all entries in `cache` as NDJSON to the output file
+// `output`, or stdout if it's empty.
+func exportCache(cache *BoltdbCache, output string) (err error) {
+	done, err := redirectOutput(output)
+	if err != nil {
+		return err
+	}
+	defer func() { err = done(err) }()
+
+	n, err := cache.Export(os.Stdout)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "exported %d entries\n", n)
+	return nil
+}
+
+// openCacheImport opens the file to import entries from, the only one in
+// `args`, or stdin if there's none or it's '-'. Files ending in '.gz' are
+// gunzipped.
+func openCacheImport(args []string) (io.Reader, func(), error) {
+	if len(args) == 0 || args[0] == "-" {
+		return os.Stdin, func() {}, nil
+	}
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	if !strings.HasSuffix(args[0], ".gz") {
+		return f, func() { f.Close() }, nil
+	}
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, nil, fmt.Errorf("error reading %s: %w", args[0], err)
+	}
+	return gz, func() {
+		gz.Close()
+		f.Close()
+	}, nil
+}
+
+// printCachePolicy prints the effective cache policy, with where each setting
+// comes from.
+func printCachePolicy() error {
+	path, err := BoltdbCachePath()
+	if err != nil {
+		return fmt.Errorf("issue getting cache db path: %w", err)
+	}
+
+	fmtHdr := color.New(color.Bold, color.FgWhite)
+	fmtEntry := color.New(color.FgCyan)
+	fmtVal := color.New(color.FgGreen)
+	pprint := func(name string, s cacheSetting) {
+		fmt.Printf(
+			"- %s %s (%s)\n",
+			fmtEntry.Sprintf("%-10s", name),
+			fmtVal.Sprint(s.value),
+			s.source,
+		)
+	}
+
+	fmtHdr.Println("Cache")
+	fmt.Printf("- %s %s\n", fmtEntry.Sprintf("%-10s", "Enabled"), fmtVal.Sprint(gConfig.CacheEnabled))
+	fmt.Printf("- %s %s\n", fmtEntry.Sprintf("%-10s", "Path"), fmtVal.Sprint(path))
+	_, maxSize := cacheMaxSizeSetting()
+	pprint("Max Size", maxSize)
+
+	fmtHdr.Println("\nTTLs")
+	for _, t := range cacheVTypes {
+		_, ttl := cacheTTLSetting(t.vtype)
+		pprint(t.name, ttl)
+	}
 	return nil
 }
+
+// printCacheStats prints `stats` in the pretty format.
+func printCacheStats(stats *cacheStats) {
+	fmtHdr := color.New(color.Bold, color.FgWhite)
+	fmtEntry := color.New(color.FgCyan)
+	fmtVal := color.New(color.FgGreen)
+	pprint := func(name string, val interface{}) {
+		fmt.Printf(
+			"- %s %s\n",
+			fmtEntry.Sprintf("%-10s", name),
+			fmtVal.Sprint(val),
+		)
+	}
+	pprintTime := func(name string, t *time.Time) {
+		if t == nil {
+			pprint(name, "-")
+			return
+		}
+		pprint(name, fmt.Sprintf(
+			"%s (%s ago)",
+			t.Local().Format(time.RFC3339),
+			time.Since(*t).Round(time.Second),
+		))
+	}
+
+	fmtHdr.Println("Entries")
+	var total int
+	for _, t := range cacheVTypes {
+		pprint(t.name, stats.Entries[t.name])
+		total += stats.Entries[t.name]
+	}
+	pprint("total", total)
+
+	fmtHdr.Println("\nCache")
+	pprint("Size", formatByteSize(stats.Size))
+	pprint("Data Size", formatByteSize(stats.DataSize))
+	pprint("Hits", stats.Hits)
+	pprint("Misses", stats.Misses)
+	if lookups := stats.Hits + stats.Misses; lookups > 0 {
+		pprint("Hit Rate", fmt.Sprintf("%.1f%%", float64(stats.Hits)/float64(lookups)*100))
+	}
+	pprintTime("Oldest", stats.Oldest)
+	pprintTime("Newest", stats.Newest)
+}
@@ -2,8 +2,13 @@ package main
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/ipinfo/cli/lib"
 	"github.com/ipinfo/cli/lib/complete"
 	"github.com/ipinfo/cli/lib/complete/predict"
 	"github.com/spf13/pflag"
@@ -26,16 +31,44 @@ Description:
 Examples:
   $ %[1]s config cache=disable
   $ %[1]s config token=testtoken cache=enable
+  $ %[1]s config db=/path/to/country_asn.mmdb
+  $ %[1]s config concurrency=4 rate=2
+  $ %[1]s config lang=de
+  $ %[1]s config cache_ttl_asn=7d cache_ttl_core=6h cache_max_size=256MB
 
 Options:
   --help, -h
     show help.
 
 Configurations:
+  batch_size=<n>
+    Default --batch-size of bulk lookups; up to 1000.
   cache=<enable | disable>
     Control whether the cache is enabled or disabled.
+  cache_max_size=<size>
+    Size of the cached results, e.g. '256MB', beyond which the least
+    recently accessed ones are evicted until it's 90%% of that. Default: 1GB.
+  cache_ttl_asn=<ttl>
+    How long cached ASN details are used, e.g. '12h' or '7d'. Default: 1d.
+  cache_ttl_core=<ttl>
+    How long cached IP details are used. Default: 1d.
+  cache_ttl_map=<ttl>
+    How long other cached objects are used. Default: 1d.
+  cache_ttl_string=<ttl>
+    How long cached single fields are used. Default: 1d.
+  concurrency=<n>
+    Default --concurrency of bulk lookups.
+  db=<path>
+    Answer IP lookups from the mmdb file at <path> instead of the API.
+    An empty <path> goes back to using the API.
+  lang=<code>
+    Default --lang of the pretty output: one of en, de, es or ja.
   open_browser=<enable | disable>
     Control whether the links should open the browser or not.
+  rate=<req/s>
+    Default --rate of API requests.
+  timeout=<duration>
+    Default --timeout of batch requests, e.g. '5m'.
   token=<tok>
     Save a token for use when querying the API.
     (Token will not be validated).
@@ -58,10 +91,14 @@ func cmdConfig() error {
 		configStr := strings.Split(arg, "=")
 		key := strings.ToLower(configStr[0])
 		if len(configStr) != 2 {
-			if key == "cache" || key == "token" || key == "open_browser" {
-				return fmt.Errorf("err: no value provided for key %s", key)
+			switch key {
+			case "cache", "token", "open_browser", "db",
+				"concurrency", "batch_size", "timeout", "rate", "lang",
+				"cache_ttl_core", "cache_ttl_asn", "cache_ttl_string",
+				"cache_ttl_map", "cache_max_size":
+				return lib.UsageErrorf("no value provided for key %s", key)
 			}
-			return fmt.Errorf("err: invalid key argument %s", key)
+			return lib.UsageErrorf("invalid key argument %s", key)
 		}
 		switch key {
 		case "cache":
@@ -72,7 +109,7 @@ func cmdConfig() error {
 			case "disable":
 				gConfig.CacheEnabled = false
 			default:
-				return fmt.Errorf("err: invalid value %s; cache must be 'enabled' or disabled", val)
+				return lib.UsageErrorf("invalid value %s; cache must be 'enabled' or disabled", val)
 			}
 		case "open_browser":
 			val := strings.ToLower(configStr[1])
@@ -82,12 +119,85 @@ func cmdConfig() error {
 			case "disable":
 				gConfig.OpenBrowser = false
 			default:
-				return fmt.Errorf("err: invalid value %s; open_browser must be 'enable' or disable", val)
+				return lib.UsageErrorf("invalid value %s; open_browser must be 'enable' or disable", val)
 			}
 		case "token":
 			gConfig.Token = configStr[1]
+		case "db":
+			path := configStr[1]
+			if path != "" {
+				if _, err := os.Stat(path); err != nil {
+					return lib.UsageErrorf("invalid value %s; %w", path, err)
+				}
+				absPath, err := filepath.Abs(path)
+				if err != nil {
+					return err
+				}
+				path = absPath
+			}
+			gConfig.DB = path
+		case "concurrency":
+			n, err := parseConfigInt(configStr[1], 1<<16)
+			if err != nil {
+				return lib.UsageErrorf("invalid value %s; concurrency %w", configStr[1], err)
+			}
+			gConfig.Concurrency = n
+		case "batch_size":
+			n, err := parseConfigInt(configStr[1], batchMaxSize)
+			if err != nil {
+				return lib.UsageErrorf("invalid value %s; batch_size %w", configStr[1], err)
+			}
+			gConfig.BatchSize = n
+		case "timeout":
+			if val := configStr[1]; val != "" {
+				if d, err := time.ParseDuration(val); err != nil || d <= 0 {
+					return lib.UsageErrorf("invalid value %s; timeout must be a positive duration like '5m'", val)
+				}
+			}
+			gConfig.Timeout = configStr[1]
+		case "rate":
+			var rate float64
+			if val := configStr[1]; val != "" {
+				var err error
+				rate, err = strconv.ParseFloat(val, 64)
+				if err != nil || rate <= 0 {
+					return lib.UsageErrorf("invalid value %s; rate must be a positive number", val)
+				}
+			}
+			gConfig.Rate = rate
+		case "lang":
+			val := strings.ToLower(configStr[1])
+			if val != "" && !isLocaleLang(val) {
+				return lib.UsageErrorf("invalid value %s; lang must be one of: %s", val, strings.Join(localeLangs, ", "))
+			}
+			gConfig.Lang = val
+		case "cache_ttl_core", "cache_ttl_asn", "cache_ttl_string", "cache_ttl_map":
+			val := configStr[1]
+			if val != "" {
+				if _, err := parseCacheTTL(val); err != nil {
+					return lib.UsageErrorf("invalid value %s; %s must be a positive duration like '12h' or '7d'", val, key)
+				}
+			}
+			switch key {
+			case "cache_ttl_core":
+				gConfig.CacheTTLCore = val
+			case "cache_ttl_asn":
+				gConfig.CacheTTLASN = val
+			case "cache_ttl_string":
+				gConfig.CacheTTLString = val
+			case "cache_ttl_map":
+				gConfig.CacheTTLMap = val
+			}
+		case "cache_max_size":
+			val := configStr[1]
+			if val != "" {
+				if _, err := parseByteSize(val); err != nil {
+					return lib.UsageErrorf("invalid value %s; cache_max_size must be a size like '256MB' or '2GB'", val)
+				}
+			}
+			gConfig.CacheMaxSize = val
 		default:
-			return fmt.Errorf("err: invalid key argument %s", configStr[0])
+			return lib.UsageErrorf("invalid key argument %s", configStr[0])
 		}
 	}
 
@@ -98,3 +208,16 @@ func cmdConfig() error {
 
 	return nil
 }
+
+// parses a config value that's an integer between 1 and `max`, or empty to
+// unset it.
+func parseConfigInt(val string, max int) (int, error) {
+	if val == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(val)
+	if err != nil || n < 1 || n > max {
+		return 0, fmt.Errorf("must be between 1 and %d", max)
+	}
+	return n, nil
+}
@@ -1,7 +1,6 @@
 package main
 
 import (
-	"errors"
 	"fmt"
 	"net"
 	"os"
@@ -9,7 +8,6 @@ import (
 	"github.com/fatih/color"
 	"github.com/ipinfo/cli/lib"
 	"github.com/ipinfo/cli/lib/iputil"
-	"github.com/ipinfo/go/v2/ipinfo"
 	"github.com/spf13/pflag"
 )
 
@@ -20,6 +18,7 @@ func printHelpDefault() {
 Commands:
   <ip>        look up details for an IP address, e.g. 8.8.8.8.
   <asn>       look up details for an ASN, e.g. AS123 or as123.
+  <domain>    look up details for all addresses of a domain, e.g. ipinfo.io.
   myip        get details for your IP.
   bulk        get details for multiple IPs in bulk.
   asn         tools related to ASNs.
@@ -53,6 +52,38 @@ Options:
       use <tok> as API token.
     --nocache
       do not use the cache.
+    --cache-ttl <ttl>
+      use cached results for at most <ttl>, e.g. '6h' or '7d', or per type
+      of result, e.g. 'core=6h,asn=7d'.
+      default: the config's cache_ttl_<type>, or 1d.
+    --retries <n>
+      retry requests failing with a network error, 5xx or 429 up to <n> times.
+      default: 3.
+    --max-backoff <duration>
+      wait at most <duration> between retries, e.g. '10s' or '1m'.
+      default: 30s.
+    --concurrency <n>
+      send at most <n> batch requests at once.
+      default: the config's concurrency, or 20.
+    --batch-size <n>
+      look up at most <n> inputs per batch request, up to 1000.
+      default: the config's batch_size, or 1000.
+    --timeout <duration>
+      time out each batch request after <duration>, e.g. '30s' or '5m'.
+      default: the config's timeout, or 30m.
+    --rate <req/s>
+      send at most <req/s> requests per second, e.g. '5' or '0.5'.
+      default: the config's rate, or unlimited.
+    --db <path>
+      lookup from the mmdb file at <path> instead of the API.
+      doesn't require a token. defaults to the config's db, if set.
+    --error-format <fmt>
+      write errors to stderr as 'text' or 'json', the latter as e.g.
+      {"error":"...","code":3,"input":"8.8.8.8"}; any command accepts it.
+      the exit code is 2 for invalid usage or input, 3 for a missing or
+      invalid token, 4 for an exceeded quota or rate limit, 5 for a network
+      error and 1 for any other error.
+      default: text.
     --version, -v
       show binary release number.
     --help, -h
@@ -63,8 +94,19 @@ Options:
       lookup only specific fields in the output.
       field names correspond to JSON keys, e.g. 'hostname' or 'company.type'.
       multiple field names must be separated by commas.
+      lists can be indexed, e.g. 'domains.domains[0]' or 'domains.domains[]'
+      for all elements, and fields renamed, e.g. 'asn.id as asn_id'.
     --nocolor
       disable colored output.
+    --output <file>, -o <file>
+      write the output to <file> rather than stdout, replacing <file> only
+      once all of it is written.
+      unless a format is chosen, it's inferred from a '.json', '.csv', '.yaml'
+      or '.ndjson' extension; a '.gz' extension after it gzips the output.
+    --stream
+      when looking up IPs from stdin, read input lazily and output results
+      as each batch completes, in input order.
+      JSON output is written as one compact object per line (NDJSON).
 
   Formats:
     --pretty, -p
@@ -75,6 +117,9 @@ Options:
       output CSV format.
     --yaml, -y
       output YAML format.
+    --ndjson, --jsonl
+      output NDJSON format, one compact JSON object per line.
+      with --field, only the selected fields and the IP are output.
 `, progBase)
 }
 
@@ -83,6 +128,7 @@ var detailedHelp = fmt.Sprintf(`Usage: %s <cmd> [<opts>] [<args>]
 Commands:
   <ip>        look up details for an IP address, e.g. 8.8.8.8.
   <asn>       look up details for an ASN, e.g. AS123 or as123.
+  <domain>    look up details for all addresses of a domain, e.g. ipinfo.io.
   myip        get details for your IP.
   bulk        get details for multiple IPs in bulk.
   asn         tools related to ASNs.
@@ -116,6 +162,38 @@ Options:
       use <tok> as API token.
     --nocache
       do not use the cache.
+    --cache-ttl <ttl>
+      use cached results for at most <ttl>, e.g. '6h' or '7d', or per type
+      of result, e.g. 'core=6h,asn=7d'.
+      default: the config's cache_ttl_<type>, or 1d.
+    --retries <n>
+      retry requests failing with a network error, 5xx or 429 up to <n> times.
+      default: 3.
+    --max-backoff <duration>
+      wait at most <duration> between retries, e.g. '10s' or '1m'.
+      default: 30s.
+    --concurrency <n>
+      send at most <n> batch requests at once.
+      default: the config's concurrency, or 20.
+    --batch-size <n>
+      look up at most <n> inputs per batch request, up to 1000.
+      default: the config's batch_size, or 1000.
+    --timeout <duration>
+      time out each batch request after <duration>, e.g. '30s' or '5m'.
+      default: the config's timeout, or 30m.
+    --rate <req/s>
+      send at most <req/s> requests per second, e.g. '5' or '0.5'.
+      default: the config's rate, or unlimited.
+    --db <path>
+      lookup from the mmdb file at <path> instead of the API.
+      doesn't require a token. defaults to the config's db, if set.
+    --error-format <fmt>
+      write errors to stderr as 'text' or 'json', the latter as e.g.
+      {"error":"...","code":3,"input":"8.8.8.8"}; any command accepts it.
+      the exit code is 2 for invalid usage or input, 3 for a missing or
+      invalid token, 4 for an exceeded quota or rate limit, 5 for a network
+      error and 1 for any other error.
+      default: text.
     --version, -v
       show binary release number.
     --help, -h
@@ -126,8 +204,19 @@ Options:
       lookup only specific fields in the output.
       field names correspond to JSON keys, e.g. 'hostname' or 'company.type'.
       multiple field names must be separated by commas.
+      lists can be indexed, e.g. 'domains.domains[0]' or 'domains.domains[]'
+      for all elements, and fields renamed, e.g. 'asn.id as asn_id'.
     --nocolor
       disable colored output.
+    --output <file>, -o <file>
+      write the output to <file> rather than stdout, replacing <file> only
+      once all of it is written.
+      unless a format is chosen, it's inferred from a '.json', '.csv', '.yaml'
+      or '.ndjson' extension; a '.gz' extension after it gzips the output.
+    --stream
+      when looking up IPs from stdin, read input lazily and output results
+      as each batch completes, in input order.
+      JSON output is written as one compact object per line (NDJSON).
 
   Formats:
     --pretty, -p
@@ -138,6 +227,9 @@ Options:
       output CSV format.
     --yaml, -y
       output YAML format.
+    --ndjson, --jsonl
+      output NDJSON format, one compact JSON object per line.
+      with --field, only the selected fields and the IP are output.
 `, progBase)
 
 func cmdDefault() (err error) {
@@ -149,9 +241,21 @@ func cmdDefault() (err error) {
 	var fJSON bool
 	var fCSV bool
 	var fYAML bool
+	var fNDJSON bool
+	var fStream bool
+	var fDB string
+	var fOutput string
 
 	pflag.StringVarP(&fTok, "token", "t", "", "the token to use.")
 	pflag.BoolVar(&fNoCache, "nocache", false, "disable the cache.")
+	pflag.Var(&fCacheTTL, "cache-ttl", "max age of cached results.")
+	pflag.IntVar(&fRetries, "retries", defaultRetries, "max retries of failed requests.")
+	pflag.DurationVar(&fMaxBackoff, "max-backoff", defaultMaxBackoff, "max wait between retries.")
+	pflag.IntVar(&fConcurrency, "concurrency", 0, "max concurrent batch requests.")
+	pflag.IntVar(&fBatchSize, "batch-size", 0, "max inputs per batch request.")
+	pflag.DurationVar(&fTimeout, "timeout", 0, "timeout of each batch request.")
+	pflag.Float64Var(&fRate, "rate", 0, "max requests per second.")
+	pflag.StringVar(&fDB, "db", "", "mmdb file to lookup from.")
 	pflag.BoolVarP(&fVsn, "version", "v", false, "print binary release number.")
 	pflag.BoolVarP(&fHelp, "", "h", false, "show help.")
 	pflag.BoolVar(&fHelpDetailed, "help", false, "show detailed help")
@@ -160,7 +264,11 @@ func cmdDefault() (err error) {
 	pflag.BoolVarP(&fJSON, "json", "j", true, "output JSON format. (default)")
 	pflag.BoolVarP(&fCSV, "csv", "c", false, "output CSV format.")
 	pflag.BoolVarP(&fYAML, "yaml", "y", false, "output YAML format.")
+	pflag.BoolVar(&fNDJSON, "ndjson", false, "output NDJSON format.")
+	pflag.BoolVar(&fNDJSON, "jsonl", false, "output NDJSON format.")
 	pflag.BoolVar(&fNoColor, "nocolor", false, "disable colored output.")
+	pflag.BoolVar(&fStream, "stream", false, "output results as each batch completes.")
+	pflag.StringVarP(&fOutput, "output", "o", "", "file to output to.")
 	pflag.Parse()
 
 	if fNoColor {
@@ -196,23 +304,57 @@ func cmdDefault() (err error) {
 		return nil
 	}
 
+	inferOutputFormat(fOutput, map[string]*bool{
+		"json":   &fJSON,
+		"csv":    &fCSV,
+		"yaml":   &fYAML,
+		"ndjson": &fNDJSON,
+	})
+	done, err := redirectOutput(fOutput)
+	if err != nil {
+		return err
+	}
+	defer func() { err = done(err) }()
+
+	opts, err := batchReqOpts()
+	if err != nil {
+		return err
+	}
+
+	// NDJSON is always streamed, as it's output one result at a time anyway.
+	if fStream || fNDJSON {
+		w, err := newCoreStreamWriter(bulkWriterOpts{
+			fields:  fField,
+			csvFmt:  fCSV,
+			yamlFmt: fYAML,
+			ndjson:  fNDJSON,
+			header:  true,
+		})
+		if err != nil {
+			return err
+		}
+
+		l, err := prepareIPLookuper(fTok, fDB, true)
+		if err != nil {
+			return err
+		}
+
+		return streamBulkCore(iputil.IPListFuncFromStdin, l.GetBatch, opts, w, nil)
+	}
+
 	ips = iputil.IPListFromStdin()
 	if len(ips) == 0 {
 		fmt.Println("no input ips")
 		return nil
 	}
 
-	ii = prepareIpinfoClient(fTok)
-
-	// require token for bulk.
-	if ii.Token == "" {
-		return errors.New("bulk lookups require a token; login via `ipinfo init`.")
+	// require token for bulk, unless answering from an mmdb file.
+	l, err := prepareIPLookuper(fTok, fDB, true)
+	if err != nil {
+		return err
 	}
 
-	data, err := ii.GetIPInfoBatch(ips, ipinfo.BatchReqOpts{
-		TimeoutPerBatch:              60 * 30, // 30min
-		ConcurrentBatchRequestsLimit: 20,
-	})
+	data, err := l.GetIPInfoBatch(ips, opts)
 	if err != nil {
 		return err
 	}
@@ -1,17 +1,38 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"net"
+	"os"
+	"strings"
 
 	"github.com/fatih/color"
+	"github.com/ipinfo/cli/lib"
+	"github.com/ipinfo/cli/lib/iputil"
 	"github.com/ipinfo/go/v2/ipinfo"
 	"github.com/spf13/pflag"
 )
 
 func printHelpDomain(domainStr string) {
 	fmt.Printf(
-		`Usage: %s %s [<opts>]
+		`Usage: %s %s [<opts>] [<domain | filepath>...]
+
+Description:
+  Looks up details for every IPv4 and IPv6 address the domains resolve to.
+
+  Accepts domains and file paths with one domain per line; '-' reads domains
+  from stdin.
+
+Examples:
+  # Lookup all addresses of a domain.
+  $ %[1]s %[2]s
+
+  # Lookup only the IPv6 addresses of multiple domains.
+  $ %[1]s %[2]s example.org -6
+
+  # Lookup all addresses of the domains in a file.
+  $ %[1]s domains.txt
 
 Options:
   General:
@@ -19,6 +40,20 @@ Options:
       use <tok> as API token.
     --nocache
       do not use the cache.
+    --cache-ttl <ttl>
+      use cached results for at most <ttl>, e.g. '6h' or '7d', or per type
+      of result, e.g. 'core=6h,asn=7d'.
+      default: the config's cache_ttl_<type>, or 1d.
+    --retries <n>
+      retry requests failing with a network error, 5xx or 429 up to <n> times.
+      default: 3.
+    --max-backoff <duration>
+      wait at most <duration> between retries, e.g. '10s' or '1m'.
+      default: 30s.
+    --resolver <addr>
+      resolve domains with the DNS server at <addr> instead of the system's
+      resolver; either <ip>[:<port>], tls://<host>[:<port>] for DNS-over-TLS
+      or an https:// URL for DNS-over-HTTPS.
     --help, -h
       show help.
 
@@ -27,31 +62,74 @@ Options:
       lookup only specific fields in the output.
       field names correspond to JSON keys, e.g. 'hostname' or 'company.type'.
       multiple field names must be separated by commas.
+      lists can be indexed, e.g. 'domains.domains[0]' or 'domains.domains[]'
+      for all elements, and fields renamed, e.g. 'asn.id as asn_id'.
+    --lang <code>
+      localize the country and continent names and the labels of the pretty
+      output into <code>: one of en, de, es or ja.
+      defaults to the config's lang, if set, or en.
     --nocolor
       disable colored output.
+    --output <file>, -o <file>
+      write the output to <file> rather than stdout, replacing <file> only
+      once all of it is written.
+      unless a format is chosen, it's inferred from a '.json', '.csv' or
+      '.yaml' extension; a '.gz' extension after it gzips the output.
+
+  Filters:
+    --ipv4, -4
+      lookup only IPv4 addresses.
+    --ipv6, -6
+      lookup only IPv6 addresses.
 
   Formats:
     --pretty, -p
-      output pretty format. (default)
+      output pretty format, grouped by domain. (default)
     --json, -j
-      output JSON format.
+      output JSON format, as a list of each domain, in the order given, with
+      the details of all its addresses under 'results', or the error
+      resolving it under 'error'.
+      previously, a domain's output was the details of its first address
+      only, as a single object.
     --csv, -c
-      output CSV format.
+      output CSV format, with an extra 'domain' column.
     --yaml, -y
-      output YAML format.
+      output YAML format, as a list like that of --json.
 `, progBase, domainStr)
 }
 
-func cmdDomain(domainStr string) error {
+// a domain with the details of its addresses, or the error resolving it, as
+// output in JSON and YAML.
+type domainResults struct {
+	Domain  string         `json:"domain" yaml:"domain"`
+	Results []*ipinfo.Core `json:"results" yaml:"results"`
+	Error   string         `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// a CSV row of a domain's address's details.
+type domainCSVRow struct {
+	Domain string `csv:"domain"`
+	*ipinfo.Core
+}
+
+func cmdDomain(domainStr string) (err error) {
 	var fTok string
+	var fResolver string
 	var fField []string
 	var fPretty bool
 	var fJSON bool
 	var fCSV bool
 	var fYAML bool
+	var fV4 bool
+	var fV6 bool
+	var fOutput string
 
 	pflag.StringVarP(&fTok, "token", "t", "", "the token to use.")
 	pflag.BoolVar(&fNoCache, "nocache", false, "disable the cache.")
+	pflag.Var(&fCacheTTL, "cache-ttl", "max age of cached results.")
+	pflag.IntVar(&fRetries, "retries", defaultRetries, "max retries of failed requests.")
+	pflag.DurationVar(&fMaxBackoff, "max-backoff", defaultMaxBackoff, "max wait between retries.")
+	pflag.StringVar(&fResolver, "resolver", "", "DNS server to resolve with.")
 	pflag.BoolVarP(&fHelp, "help", "h", false, "show help.")
 	pflag.StringSliceVarP(&fField, "field", "f", nil, "specific field to lookup.")
 	pflag.BoolVarP(&fPretty, "pretty", "p", true, "output pretty format.")
@@ -59,6 +137,10 @@ func cmdDomain(domainStr string) error {
 	pflag.BoolVarP(&fCSV, "csv", "c", false, "output CSV format.")
 	pflag.BoolVarP(&fYAML, "yaml", "y", false, "output YAML format.")
 	pflag.BoolVar(&fNoColor, "nocolor", false, "disable color output.")
+	pflag.StringVar(&fLang, "lang", "", "language of the pretty output.")
+	pflag.BoolVarP(&fV4, "ipv4", "4", false, "lookup only IPv4 addresses.")
+	pflag.BoolVarP(&fV6, "ipv6", "6", false, "lookup only IPv6 addresses.")
+	pflag.StringVarP(&fOutput, "output", "o", "", "file to output to.")
 	pflag.Parse()
 
 	if fNoColor {
@@ -66,36 +148,229 @@ func cmdDomain(domainStr string) error {
 	}
 
 	if fHelp {
-		printHelpIP(domainStr)
+		printHelpDomain(domainStr)
 		return nil
 	}
 
-	ips, err := net.LookupIP(domainStr)
+	if err := setLocale(fLang); err != nil {
+		return err
+	}
+
+	inferOutputFormat(fOutput, map[string]*bool{
+		"json": &fJSON,
+		"csv":  &fCSV,
+		"yaml": &fYAML,
+	})
+	done, err := redirectOutput(fOutput)
+	if err != nil {
+		return err
+	}
+	defer func() { err = done(err) }()
+
+	// neither or both filters means all addresses.
+	if !fV4 && !fV6 {
+		fV4, fV6 = true, true
+	}
+
+	domains, err := domainListFromAllSrcs(pflag.Args())
+	if err != nil {
+		return err
+	}
+
+	resolver, err := newResolver(fResolver, nil)
+	if err != nil {
+		return err
+	}
+
+	resolved, ips, err := resolveDomains(resolver, domains, fV4, fV6)
 	if err != nil {
 		return err
 	}
-	ip := ips[0]
+
 	ii = prepareIpinfoClient(fTok)
-	data, err := ii.GetIPInfo(ip)
+	data, err := lookupDomainIPs(ips)
 	if err != nil {
 		return err
 	}
 
 	if len(fField) > 0 {
-		d := make(ipinfo.BatchCore, 1)
-		d[ip.String()] = data
-		return outputFieldBatchCore(d, fField, false, false)
-	}
-	if fJSON {
-		return outputJSON(data)
+		hdrs, rowFuncs, err := prepareFieldsCore(fField, true, true)
+		if err != nil {
+			return err
+		}
+		fmt.Println("domain," + strings.Join(hdrs, ","))
+		for _, r := range resolved {
+			for _, ip := range r.ips {
+				row := []string{r.host}
+				for _, rowFunc := range rowFuncs {
+					row = append(row, rowFunc(data[ip.String()]))
+				}
+				fmt.Println(strings.Join(row, ","))
+			}
+		}
+		return nil
 	}
 	if fCSV {
-		return outputCSV(data)
+		rows := make([]domainCSVRow, 0, len(ips))
+		for _, r := range resolved {
+			for _, ip := range r.ips {
+				rows = append(rows, domainCSVRow{r.host, data[ip.String()]})
+			}
+		}
+		return outputCSV(rows)
 	}
-	if fYAML {
-		return outputYAML(data)
+	if fJSON || fYAML {
+		out := make([]domainResults, 0, len(resolved))
+		for _, r := range resolved {
+			res := domainResults{
+				Domain:  r.host,
+				Results: make([]*ipinfo.Core, 0, len(r.ips)),
+			}
+			if r.err != nil {
+				res.Error = r.err.Error()
+			}
+			for _, ip := range r.ips {
+				res.Results = append(res.Results, data[ip.String()])
+			}
+			out = append(out, res)
+		}
+		if fYAML {
+			return outputYAML(out)
+		}
+		return outputJSON(out)
 	}
 
-	outputFriendlyCore(data)
+	fmtDomain := color.New(color.Bold, color.FgYellow)
+	first := true
+	for _, r := range resolved {
+		if r.err != nil {
+			continue
+		}
+		if !first {
+			fmt.Println()
+		}
+		first = false
+		if len(r.ips) == 1 {
+			fmtDomain.Printf("%s (1 address)\n", r.host)
+		} else {
+			fmtDomain.Printf("%s (%d addresses)\n", r.host, len(r.ips))
+		}
+		for _, ip := range r.ips {
+			fmt.Println()
+			outputFriendlyCore(data[ip.String()])
+		}
+	}
 	return nil
 }
+
+// resolveDomains resolves `domains` with `resolver` to their IPv4 addresses if `v4`
+// and their IPv6 addresses if `v6`, returning those of each domain in order
+// and all of them without duplicates, so that each is looked up only once.
+//
+// A domain that couldn't be resolved is warned about and has its `err` set,
+// rather than failing the others; only if none could be is its error
+// returned.
+func resolveDomains(
+	resolver *net.Resolver,
+	domains []string,
+	v4 bool,
+	v6 bool,
+) ([]hostIPs, []net.IP, error) {
+	var ips []net.IP
+	var failed []hostIPs
+	resolved := resolveHosts(resolver, domains, defaultResolveConcurrency)
+	seen := make(map[string]struct{})
+	for i, r := range resolved {
+		if r.err != nil {
+			failed = append(failed, r)
+			continue
+		}
+		var domainIPs []net.IP
+		for _, ip := range r.ips {
+			if isV4 := ip.To4() != nil; (isV4 && !v4) || (!isV4 && !v6) {
+				continue
+			}
+			domainIPs = append(domainIPs, ip)
+			if _, ok := seen[ip.String()]; !ok {
+				seen[ip.String()] = struct{}{}
+				ips = append(ips, ip)
+			}
+		}
+		resolved[i].ips = domainIPs
+	}
+
+	// if none could be resolved, the first is the error.
+	var err error
+	if len(failed) == len(resolved) {
+		err = lib.ErrWithInput(failed[0].err, failed[0].host)
+		failed = failed[1:]
+	} else if len(ips) == 0 {
+		err = errors.New("no addresses found")
+	}
+	for _, f := range failed {
+		fmt.Fprintf(os.Stderr, "warn: couldn't resolve %v: %v\n", f.host, f.err)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return resolved, ips, nil
+}
+
+// lookupDomainIPs looks up all `ips` through the batch API, or one by one
+// without a token, which the batch API requires.
+func lookupDomainIPs(ips []net.IP) (ipinfo.BatchCore, error) {
+	if ii.Token != "" && len(ips) > 1 {
+		opts, err := batchReqOpts()
+		if err != nil {
+			return nil, err
+		}
+		return ii.GetIPInfoBatch(ips, opts)
+	}
+
+	data := make(ipinfo.BatchCore, len(ips))
+	for _, ip := range ips {
+		d, err := ii.GetIPInfo(ip)
+		if err != nil {
+			return nil, err
+		}
+		data[ip.String()] = d
+	}
+	return data, nil
+}
+
+// domainListFromAllSrcs returns the domains in `inputs` and in the files in
+// `inputs`, in order and without duplicates; '-' reads them from stdin.
+func domainListFromAllSrcs(inputs []string) ([]string, error) {
+	var domains []string
+	seen := make(map[string]struct{})
+	op := func(input string, inputType iputil.INPUT_TYPE) error {
+		if inputType != iputil.INPUT_TYPE_UNKNOWN {
+			return lib.ErrWithInput(lib.UsageErrorf("%v is not a domain", input), input)
+		}
+		if _, ok := seen[input]; !ok {
+			seen[input] = struct{}{}
+			domains = append(domains, input)
+		}
+		return nil
+	}
+
+	for _, input := range inputs {
+		var err error
+		switch {
+		case input == "-":
+			err = iputil.ProcessStringsFromStdin(op)
+		case iputil.FileExists(input):
+			err = iputil.ProcessStringsFromFile(input, op)
+		default:
+			err = iputil.InputHelper(input, op)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(domains) == 0 {
+		return nil, lib.UsageErrorf("no input domains")
+	}
+	return domains, nil
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Each domain has its addresses of the chosen families, and one that can't
+// be resolved is warned about without failing the others.
+func TestResolveDomains(t *testing.T) {
+	r, err := newResolver(listenStubDNS(t), nil)
+	if err != nil {
+		t.Fatalf("resolver: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		domains []string
+		v4, v6  bool
+		want    map[string][]string
+		failed  []string
+		wantErr bool
+	}{
+		{
+			name:    "multiple domains",
+			domains: []string{"test.example", "v4.example"},
+			v4:      true, v6: true,
+			want: map[string][]string{
+				"test.example": {"192.0.2.1", "2001:db8::1"},
+				"v4.example":   {"192.0.2.2"},
+			},
+		},
+		{
+			name:    "ipv4",
+			domains: []string{"test.example", "v4.example"},
+			v4:      true,
+			want: map[string][]string{
+				"test.example": {"192.0.2.1"},
+				"v4.example":   {"192.0.2.2"},
+			},
+		},
+		{
+			name:    "ipv6",
+			domains: []string{"test.example", "v4.example"},
+			v6:      true,
+			want: map[string][]string{
+				"test.example": {"2001:db8::1"},
+				"v4.example":   nil,
+			},
+		},
+		{
+			name:    "unresolvable domain",
+			domains: []string{"test.example", "missing.example", "v4.example"},
+			v4:      true,
+			want: map[string][]string{
+				"test.example": {"192.0.2.1"},
+				"v4.example":   {"192.0.2.2"},
+			},
+			failed: []string{"missing.example"},
+		},
+		{
+			name:    "no domain resolvable",
+			domains: []string{"missing.example", "nope.example"},
+			v4:      true, v6: true,
+			failed:  []string{"nope.example"},
+			wantErr: true,
+		},
+		{
+			name:    "no addresses",
+			domains: []string{"v4.example"},
+			v6:      true,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		var resolved []hostIPs
+		var ips []net.IP
+		var err error
+		_, stderr := captureStd(t, func() {
+			resolved, ips, err = resolveDomains(r, tt.domains, tt.v4, tt.v6)
+		})
+		for _, host := range tt.failed {
+			if !strings.Contains(stderr, "couldn't resolve "+host) {
+				t.Errorf("%v: expected a warning for %v, got %q", tt.name, host, stderr)
+			}
+		}
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%v: expected an error", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %v", tt.name, err)
+		}
+
+		got := make(map[string][]string)
+		var n int
+		for i, res := range resolved {
+			if res.host != tt.domains[i] {
+				t.Errorf("%v: expected %v at %d, got %v", tt.name, tt.domains[i], i, res.host)
+			}
+			if res.err != nil {
+				continue
+			}
+			var hostIPs []string
+			for _, ip := range res.ips {
+				hostIPs = append(hostIPs, ip.String())
+			}
+			got[res.host] = hostIPs
+			n += len(res.ips)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%v: expected %v, got %v", tt.name, tt.want, got)
+		}
+		if len(ips) != n {
+			t.Errorf("%v: expected %d addresses to look up, got %v", tt.name, n, ips)
+		}
+	}
+}
+
+// Domains are read from arguments, files and stdin, in order and without
+// duplicates.
+func TestDomainListFromAllSrcs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "domains.txt")
+	if err := os.WriteFile(path, []byte("b.example\nc.example\na.example\n"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	stdinR, stdinW, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("pipe: %v", err)
+	}
+	stdinW.WriteString("d.example\nb.example\n")
+	stdinW.Close()
+	origStdin := os.Stdin
+	os.Stdin = stdinR
+	defer func() { os.Stdin = origStdin }()
+
+	tests := []struct {
+		inputs  []string
+		want    []string
+		wantErr bool
+	}{
+		{[]string{"a.example", "b.example"}, []string{"a.example", "b.example"}, false},
+		{[]string{"a.example", path}, []string{"a.example", "b.example", "c.example"}, false},
+		{[]string{path, "-"}, []string{"b.example", "c.example", "a.example", "d.example"}, false},
+		{[]string{"a.example", "8.8.8.8"}, nil, true},
+		{nil, nil, true},
+	}
+	for _, tt := range tests {
+		got, err := domainListFromAllSrcs(tt.inputs)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%v: expected an error", tt.inputs)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %v", tt.inputs, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%v: expected %v, got %v", tt.inputs, tt.want, got)
+		}
+	}
+}
@@ -7,11 +7,11 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"os"
 	"strings"
 
+	"github.com/ipinfo/cli/lib"
 	"github.com/ipinfo/cli/lib/complete"
 	"github.com/ipinfo/cli/lib/complete/predict"
 	"github.com/spf13/pflag"
@@ -33,6 +33,8 @@ var completionsDownload = &complete.Command{
 		"--compress": predict.Nothing,
 		"-f":         predict.Nothing,
 		"--format":   predict.Nothing,
+		"-o":         predict.Nothing,
+		"--output":   predict.Nothing,
 		"-t":         predict.Nothing,
 		"--token":    predict.Nothing,
 		"-h":         predict.Nothing,
@@ -71,6 +73,10 @@ Outputs:
     --format, -f <mmdb | json | csv>
       output format of the database file.
       default: mmdb.
+    --output <file>, -o <file>
+      save the database to <file>, like <output>, even if stdout isn't a
+      terminal.
+      the file is only replaced once the download is complete.
 `, progBase)
 }
 
@@ -78,11 +84,13 @@ func cmdDownload() error {
 	var fTok string
 	var fFmt string
 	var fZip bool
+	var fOutput string
 	var fHelp bool
 
 	pflag.StringVarP(&fTok, "token", "t", "", "the token to use.")
 	pflag.StringVarP(&fFmt, "format", "f", "mmdb", "the output format to use.")
 	pflag.BoolVarP(&fZip, "compress", "c", false, "compressed output.")
+	pflag.StringVarP(&fOutput, "output", "o", "", "file to save the database to.")
 	pflag.BoolVarP(&fHelp, "help", "h", false, "show help.")
 	pflag.Parse()
 
@@ -99,7 +107,7 @@ func cmdDownload() error {
 
 	// require token for download.
 	if token == "" {
-		return errors.New("downloading requires a token; login via `ipinfo init` or pass the `--token` argument")
+		return lib.AuthErrorf("downloading requires a token; login via `ipinfo init` or pass the `--token` argument")
 	}
 
 	// get download format and extension.
@@ -116,7 +124,7 @@ func cmdDownload() error {
 		format = "json.gz"
 		fileExtension = "json"
 	default:
-		return errors.New("unknown download format")
+		return lib.UsageErrorf("unknown download format")
 	}
 
 	if fZip {
@@ -133,19 +141,26 @@ func cmdDownload() error {
 	case "country-asn":
 		dbName = "country_asn"
 	default:
-		return fmt.Errorf("database '%v' is invalid", args[0])
+		return lib.UsageErrorf("database '%v' is invalid", args[0])
 	}
 
 	// get file name.
 	var fileName string
-	if len(pflag.Args()) > 2 {
+	if fOutput != "" {
+		fileName = fOutput
+	} else if len(pflag.Args()) > 2 {
 		fileName = pflag.Args()[2]
 	} else {
 		fileName = fmt.Sprintf("%s.%s", dbName, fileExtension)
 	}
 
+	// if output not terminal write to stdout, unless a file is given by
+	// --output.
+	fileInfo, _ := os.Stdout.Stat()
+	toStdout := fOutput == "" && (fileInfo.Mode()&os.ModeCharDevice) == 0
+
 	url := fmt.Sprintf("%s%s.%s?token=%s", dbDownloadURL, dbName, format, token)
-	err := downloadDb(url, fileName, format, fZip)
+	err := downloadDb(url, fileName, format, fZip, toStdout)
 	if err != nil {
 		return err
 	}
@@ -171,7 +186,7 @@ func cmdDownload() error {
 	return nil
 }
 
-func downloadDb(url string, fileName string, format string, zip bool) error {
+func downloadDb(url string, fileName string, format string, zip bool, toStdout bool) error {
 
 	// make API req to download the file.
 	res, err := http.Get(url)
@@ -180,8 +195,7 @@ func downloadDb(url string, fileName string, format string, zip bool) error {
 	}
 	defer res.Body.Close()
 
-	// if output not terminal write to stdout.
-	if fileInfo, _ := os.Stdout.Stat(); (fileInfo.Mode() & os.ModeCharDevice) == 0 {
+	if toStdout {
 		if zip {
 			err := zipWriter(os.Stdout, res.Body)
 			if err != nil {
@@ -195,38 +209,17 @@ func downloadDb(url string, fileName string, format string, zip bool) error {
 		}
 
 	} else {
-		// create file.
-		file, err := os.Create(fileName)
+		// create file, which is only replaced once it's completely written.
+		file, err := createOutputFile(fileName, false)
 		if err != nil {
-			log.Fatal(err)
+			return err
 		}
-		defer file.Close()
-
-		// save compressed file.
-		if zip {
-			if format == "mmdb" {
-				err := zipWriter(file, res.Body)
-				if err != nil {
-					return err
-				}
-			} else {
-				_, err = io.Copy(file, res.Body)
-				if err != nil {
-					return err
-				}
-			}
-		} else {
-			if format == "mmdb" {
-				_, err = io.Copy(file, res.Body)
-				if err != nil {
-					return err
-				}
-			} else {
-				err := unzipWrite(file, res.Body)
-				if err != nil {
-					return err
-				}
-			}
+		if err := writeDb(file.tmp, res.Body, format, zip); err != nil {
+			file.Abort()
+			return err
+		}
+		if err := file.Commit(); err != nil {
+			return err
 		}
 
 		fmt.Printf("Database %s saved successfully.\n", fileName)
@@ -235,6 +228,25 @@ func downloadDb(url string, fileName string, format string, zip bool) error {
 	return nil
 }
 
+// writeDb writes the downloaded database `data` in `format` to `file`,
+// compressed if `zip` is set.
+func writeDb(file *os.File, data io.Reader, format string, zip bool) error {
+	// save compressed file.
+	if zip {
+		if format == "mmdb" {
+			return zipWriter(file, data)
+		}
+		_, err := io.Copy(file, data)
+		return err
+	}
+
+	if format == "mmdb" {
+		_, err := io.Copy(file, data)
+		return err
+	}
+	return unzipWrite(file, data)
+}
+
 func zipWriter(file *os.File, data io.Reader) error {
 	writer := gzip.NewWriter(file)
 	defer writer.Close()
@@ -2,7 +2,6 @@ package main
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -10,6 +9,7 @@ import (
 	"os"
 	"time"
 
+	"github.com/ipinfo/cli/lib"
 	"github.com/ipinfo/cli/lib/complete"
 	"github.com/ipinfo/cli/lib/complete/predict"
 	"github.com/pkg/browser"
@@ -87,7 +87,7 @@ func cmdInit() error {
 
 	// allow only flag or arg for token but not both.
 	if fTok != "" && len(args) > 0 {
-		return errors.New("ambiguous token input source")
+		return lib.UsageErrorf("ambiguous token input source")
 	}
 
 	// get token, from flag or command line.
@@ -234,7 +234,7 @@ func checkValidity(tok string) error {
 		return fmt.Errorf("could not confirm if token is valid: %w", err)
 	}
 	if !tokenOk {
-		return fmt.Errorf("invalid token")
+		return lib.AuthErrorf("invalid token")
 	}
 
 	return nil
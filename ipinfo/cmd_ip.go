@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"github.com/fatih/color"
+	"github.com/ipinfo/cli/lib"
 	"github.com/ipinfo/cli/lib/complete"
 	"github.com/ipinfo/cli/lib/complete/predict"
 	"github.com/ipinfo/go/v2/ipinfo"
@@ -12,20 +13,32 @@ import (
 
 var completionsIP = &complete.Command{
 	Flags: map[string]complete.Predictor{
-		"-t":        predict.Nothing,
-		"--token":   predict.Nothing,
-		"--nocache": predict.Nothing,
-		"-h":        predict.Nothing,
-		"--help":    predict.Nothing,
-		"-f":        predict.Set(coreFields),
-		"--field":   predict.Set(coreFields),
-		"--nocolor": predict.Nothing,
-		"-p":        predict.Nothing,
-		"--pretty":  predict.Nothing,
-		"-j":        predict.Nothing,
-		"--json":    predict.Nothing,
-		"-c":        predict.Nothing,
-		"--csv":     predict.Nothing,
+		"-t":              predict.Nothing,
+		"--token":         predict.Nothing,
+		"--nocache":       predict.Nothing,
+		"--cache-ttl":     predict.Nothing,
+		"--retries":       predict.Nothing,
+		"--max-backoff":   predict.Nothing,
+		"--db":            predict.Nothing,
+		"-h":              predict.Nothing,
+		"--help":          predict.Nothing,
+		"-f":              predict.Set(coreFields),
+		"--field":         predict.Set(coreFields),
+		"--lang":          predict.Set(localeLangs),
+		"--nocolor":       predict.Nothing,
+		"-o":              predict.Nothing,
+		"--output":        predict.Nothing,
+		"-p":              predict.Nothing,
+		"--pretty":        predict.Nothing,
+		"-j":              predict.Nothing,
+		"--json":          predict.Nothing,
+		"-c":              predict.Nothing,
+		"--csv":           predict.Nothing,
+		"--ndjson":        predict.Nothing,
+		"--jsonl":         predict.Nothing,
+		"--template":      predict.Nothing,
+		"--format":        predict.Nothing,
+		"--template-file": predict.Nothing,
 	},
 }
 
@@ -39,6 +52,19 @@ Options:
       use <tok> as API token.
     --nocache
       do not use the cache.
+    --cache-ttl <ttl>
+      use cached results for at most <ttl>, e.g. '6h' or '7d', or per type
+      of result, e.g. 'core=6h,asn=7d'.
+      default: the config's cache_ttl_<type>, or 1d.
+    --retries <n>
+      retry requests failing with a network error, 5xx or 429 up to <n> times.
+      default: 3.
+    --max-backoff <duration>
+      wait at most <duration> between retries, e.g. '10s' or '1m'.
+      default: 30s.
+    --db <path>
+      lookup from the mmdb file at <path> instead of the API.
+      defaults to the config's db, if set.
     --help, -h
       show help.
 
@@ -47,8 +73,19 @@ Options:
       lookup only specific fields in the output.
       field names correspond to JSON keys, e.g. 'hostname' or 'company.type'.
       multiple field names must be separated by commas.
+      lists can be indexed, e.g. 'domains.domains[0]' or 'domains.domains[]'
+      for all elements, and fields renamed, e.g. 'asn.id as asn_id'.
+    --lang <code>
+      localize the country and continent names and the labels of the pretty
+      output into <code>: one of en, de, es or ja.
+      defaults to the config's lang, if set, or en.
     --nocolor
       disable colored output.
+    --output <file>, -o <file>
+      write the output to <file> rather than stdout, replacing <file> only
+      once all of it is written.
+      unless a format is chosen, it's inferred from a '.json', '.csv', '.yaml'
+      or '.ndjson' extension; a '.gz' extension after it gzips the output.
 
   Formats:
     --pretty, -p
@@ -59,10 +96,20 @@ Options:
       output CSV format.
     --yaml, -y
       output YAML format.
+    --ndjson, --jsonl
+      output NDJSON format, one compact JSON object per line.
+      with --field, only the selected fields and the IP are output.
+    --template <tmpl>, --format <tmpl>
+      output the result rendered through the Go template <tmpl>, e.g.
+      '{{.IP}} {{.Country}}'.
+      besides the builtin functions, 'countryName', 'flag', 'join' and 'json'
+      are available.
+    --template-file <file>
+      like --template, with the template read from <file>.
 `, progBase, ipStr)
 }
 
-func cmdIP(ipStr string) error {
+func cmdIP(ipStr string) (err error) {
 	var fTok string
 	var fNoCache bool
 	var fField []string
@@ -70,16 +117,32 @@ func cmdIP(ipStr string) error {
 	var fJSON bool
 	var fCSV bool
 	var fYAML bool
+	var fDB string
+	var fNDJSON bool
+	var fTemplate string
+	var fTemplateFile string
+	var fOutput string
 
 	pflag.StringVarP(&fTok, "token", "t", "", "the token to use.")
 	pflag.BoolVar(&fNoCache, "nocache", false, "disable the cache.")
+	pflag.Var(&fCacheTTL, "cache-ttl", "max age of cached results.")
+	pflag.IntVar(&fRetries, "retries", defaultRetries, "max retries of failed requests.")
+	pflag.DurationVar(&fMaxBackoff, "max-backoff", defaultMaxBackoff, "max wait between retries.")
+	pflag.StringVar(&fDB, "db", "", "mmdb file to lookup from.")
 	pflag.BoolVarP(&fHelp, "help", "h", false, "show help.")
 	pflag.StringSliceVarP(&fField, "field", "f", nil, "specific field to lookup.")
 	pflag.BoolVarP(&fPretty, "pretty", "p", true, "output pretty format.")
 	pflag.BoolVarP(&fJSON, "json", "j", false, "output JSON format.")
 	pflag.BoolVarP(&fCSV, "csv", "c", false, "output CSV format.")
 	pflag.BoolVarP(&fYAML, "yaml", "y", false, "output YAML format.")
+	pflag.BoolVar(&fNDJSON, "ndjson", false, "output NDJSON format.")
+	pflag.BoolVar(&fNDJSON, "jsonl", false, "output NDJSON format.")
+	pflag.StringVar(&fTemplate, "template", "", "template to output results with.")
+	pflag.StringVar(&fTemplate, "format", "", "template to output results with.")
+	pflag.StringVar(&fTemplateFile, "template-file", "", "template file to output results with.")
 	pflag.BoolVar(&fNoColor, "nocolor", false, "disable color output.")
+	pflag.StringVar(&fLang, "lang", "", "language of the pretty output.")
+	pflag.StringVarP(&fOutput, "output", "o", "", "file to output to.")
 	pflag.Parse()
 
 	if fNoColor {
@@ -91,13 +154,43 @@ func cmdIP(ipStr string) error {
 		return nil
 	}
 
+	if err := setLocale(fLang); err != nil {
+		return err
+	}
+
+	inferOutputFormat(fOutput, map[string]*bool{
+		"json":   &fJSON,
+		"csv":    &fCSV,
+		"yaml":   &fYAML,
+		"ndjson": &fNDJSON,
+	})
+	done, err := redirectOutput(fOutput)
+	if err != nil {
+		return err
+	}
+	defer func() { err = done(err) }()
+
+	tmpl, err := prepareOutputTemplate(fTemplate, fTemplateFile)
+	if err != nil {
+		return err
+	}
+
 	ip := net.ParseIP(ipStr)
-	ii = prepareIpinfoClient(fTok)
-	data, err := ii.GetIPInfo(ip)
+	l, err := prepareIPLookuper(fTok, fDB, false)
 	if err != nil {
 		return err
 	}
+	data, err := l.GetIPInfo(ip)
+	if err != nil {
+		return lib.ErrWithInput(err, ipStr)
+	}
 
+	if tmpl != nil {
+		return outputTemplate(tmpl, data)
+	}
+	if fNDJSON {
+		return outputNDJSONCore(data, fField)
+	}
 	if len(fField) > 0 {
 		d := make(ipinfo.BatchCore, 1)
 		d[ipStr] = data
@@ -107,7 +200,7 @@ func cmdIP(ipStr string) error {
 		return outputJSON(data)
 	}
 	if fCSV {
-		return outputCSV(data)
+		return outputCSVBatchCore(ipinfo.BatchCore{ipStr: data})
 	}
 	if fYAML {
 		return outputYAML(data)
@@ -13,9 +13,11 @@ import (
 
 var completionsMap = &complete.Command{
 	Flags: map[string]complete.Predictor{
-		"-h":           predict.Nothing,
-		"--help":       predict.Nothing,
-		"--no-browser": predict.Nothing,
+		"-h":            predict.Nothing,
+		"--help":        predict.Nothing,
+		"--retries":     predict.Nothing,
+		"--max-backoff": predict.Nothing,
+		"--no-browser":  predict.Nothing,
 	},
 }
 
@@ -48,6 +50,12 @@ Examples:
 
 Options:
   General:
+    --retries <n>
+      retry requests failing with a network error, 5xx or 429 up to <n> times.
+      default: 3.
+    --max-backoff <duration>
+      wait at most <duration> between retries, e.g. '10s' or '1m'.
+      default: 30s.
     --help, -h
       show help.
     --no-browser
@@ -60,6 +68,8 @@ func cmdMap() (err error) {
 	var ips []net.IP
 	var fNoBrowser bool
 
+	pflag.IntVar(&fRetries, "retries", defaultRetries, "max retries of failed requests.")
+	pflag.DurationVar(&fMaxBackoff, "max-backoff", defaultMaxBackoff, "max wait between retries.")
 	pflag.BoolVarP(&fHelp, "help", "h", false, "show help.")
 	pflag.BoolVar(&fNoBrowser, "no-browser", false, "disable browser opening.")
 	pflag.Parse()
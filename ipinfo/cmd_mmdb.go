@@ -91,9 +91,5 @@ func cmdMmdb() error {
 		err = mmdbHelp()
 	}
 
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "err: %v\n", err)
-	}
-
-	return nil
+	return err
 }
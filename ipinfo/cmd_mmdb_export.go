@@ -1,16 +1,20 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"strings"
 
 	"github.com/ipinfo/mmdbctl/lib"
+	"github.com/oschwald/maxminddb-golang"
 
 	"github.com/ipinfo/cli/lib/complete"
 	"github.com/ipinfo/cli/lib/complete/predict"
 	"github.com/spf13/pflag"
 )
 
-var predictFormats = []string{"csv", "tsv", "json"}
+var predictFormats = []string{"csv", "tsv", "json", "parquet"}
 
 var completionsMmdbExport = &complete.Command{
 	Flags: map[string]complete.Predictor{
@@ -36,14 +40,19 @@ Options:
   Input/Output:
     -o <fname>, --out <fname>
       output file name. (e.g. out.csv)
+      the file is only replaced once the export is complete, and is gzipped
+      if its name ends in ".gz", except for parquet.
       default: <out_file> if specified, otherwise stdout.
 
   Format:
     -f <format>, --format <format>
       the output file format.
-      can be "csv", "tsv" or "json".
+      can be "csv", "tsv", "json" or "parquet".
       default: csv if output file ends in ".csv", tsv if ".tsv",
-      json if ".json", otherwise csv.
+      json if ".json" or ".ndjson", parquet if ".parquet", otherwise csv;
+      ignoring a ".gz" after it.
+      parquet files have a column for each field of all records, typed like
+      their values, with nested maps as groups.
     --no-header
       don't output the header for file formats that include one, like
       CSV/TSV/JSON.
@@ -59,5 +68,97 @@ func cmdMmdbExport() error {
 		f.Help = true
 	}
 
-	return lib.CmdExport(f, pflag.Args()[2:], printHelpMmdbExport)
+	args := pflag.Args()[2:]
+	if f.Help {
+		return lib.CmdExport(f, args, printHelpMmdbExport)
+	}
+
+	if f.Out == "" && len(args) >= 2 {
+		f.Out = args[1]
+	}
+	if f.Format == "" {
+		switch outputFileFormat(f.Out) {
+		case "csv", "json", "parquet":
+			f.Format = outputFileFormat(f.Out)
+		case "ndjson":
+			f.Format = "json"
+		}
+		if strings.HasSuffix(strings.TrimSuffix(f.Out, ".gz"), ".tsv") {
+			f.Format = "tsv"
+		}
+	}
+	if f.Format == "parquet" {
+		if err := checkParquetOutput(f.Out); err != nil {
+			return err
+		}
+		return mmdbExportParquet(args, f.Out)
+	}
+
+	// the export is written to stdout, redirected to the output file.
+	done, err := redirectOutput(f.Out)
+	if err != nil {
+		return err
+	}
+	f.Out = ""
+	if len(args) > 1 {
+		args = args[:1]
+	}
+	return done(lib.CmdExport(f, args, printHelpMmdbExport))
+}
+
+// mmdbExportParquet exports the mmdb file that is the first of the `args` as
+// Parquet to the file `out`, or stdout if it's empty.
+func mmdbExportParquet(args []string, out string) error {
+	if len(args) == 0 {
+		return errors.New("input mmdb file required as first argument")
+	}
+
+	db, err := maxminddb.Open(args[0])
+	if err != nil {
+		return fmt.Errorf("couldn't open mmdb file: %w", err)
+	}
+	defer db.Close()
+
+	// the schema has all fields of all records, so they're read twice.
+	sample := make(map[string]interface{})
+	networks := db.Networks(maxminddb.SkipAliasedNetworks)
+	for networks.Next() {
+		record := make(map[string]interface{})
+		if _, err := networks.Network(&record); err != nil {
+			return fmt.Errorf("failed to get record for next subnet: %w", err)
+		}
+		mergeParquetSample(sample, record)
+	}
+	if err := networks.Err(); err != nil {
+		return fmt.Errorf("failed networks traversal: %w", err)
+	}
+	delete(sample, "range")
+	schema := append(
+		[]*parquetNode{parquetColumn("range", parquetByteArray, parquetUTF8)},
+		parquetSchemaFromValue(sample)...,
+	)
+
+	write := func(w *parquetWriter) error {
+		networks := db.Networks(maxminddb.SkipAliasedNetworks)
+		for networks.Next() {
+			record := make(map[string]interface{})
+			subnet, err := networks.Network(&record)
+			if err != nil {
+				return fmt.Errorf("failed to get record for next subnet: %w", err)
+			}
+			record["range"] = subnet.String()
+			if err := w.Write(record); err != nil {
+				return err
+			}
+		}
+		if err := networks.Err(); err != nil {
+			return fmt.Errorf("failed networks traversal: %w", err)
+		}
+		return nil
+	}
+
+	if out != "" {
+		return outputParquetFile(out, schema, write)
+	}
+	return writeParquet(os.Stdout, schema, write)
 }
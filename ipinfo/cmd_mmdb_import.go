@@ -1,7 +1,11 @@
 package main
 
 import (
+	"bufio"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/ipinfo/cli/lib/complete"
 	"github.com/ipinfo/cli/lib/complete/predict"
@@ -66,6 +70,7 @@ Options:
       default: stdin.
     -o <fname>, --out <fname>
       output file name. (e.g. sample.mmdb)
+      the file is only replaced once the import is complete.
       default: stdout.
     -c, --csv
       interpret input file as CSV.
@@ -149,5 +154,62 @@ func cmdMmdbImport() error {
 		f.Help = true
 	}
 
-	return lib.CmdImport(f, pflag.Args()[2:], printHelpMmdbImport)
+	args := pflag.Args()[2:]
+	if !f.Help && len(args) >= 2 {
+		f.In, f.Out = args[0], args[1]
+		args = nil
+	}
+	if f.Help || f.Out == "" {
+		return lib.CmdImport(f, args, printHelpMmdbImport)
+	}
+
+	// the mmdb is written to a file of the same name in a temp dir, as its
+	// name is in its metadata, and moved into place once it's complete.
+	out := f.Out
+	dir, err := os.MkdirTemp(filepath.Dir(out), "."+filepath.Base(out)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("could not create %v: %w", out, err)
+	}
+	defer os.RemoveAll(dir)
+
+	// the user is told of the file the mmdb is moved to, not the temp one.
+	f.Out = filepath.Join(dir, filepath.Base(out))
+	err = replaceStderr(f.Out, out, func() error {
+		return lib.CmdImport(f, args, printHelpMmdbImport)
+	})
+	if err != nil {
+		return err
+	}
+	return os.Rename(f.Out, out)
+}
+
+// replaceStderr runs `fn` with every `old` in what it writes to stderr
+// replaced with `new`.
+func replaceStderr(old string, new string, fn func() error) error {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	origStderr := os.Stderr
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		br := bufio.NewReader(r)
+		for {
+			line, err := br.ReadString('\n')
+			fmt.Fprint(origStderr, strings.ReplaceAll(line, old, new))
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	os.Stderr = w
+	err = fn()
+	os.Stderr = origStderr
+	w.Close()
+	<-done
+	return err
 }
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+// What's written to stderr has the temp file replaced by the final one.
+func TestReplaceStderr(t *testing.T) {
+	var err error
+	_, stderr := captureStd(t, func() {
+		err = replaceStderr("/tmp/.out.mmdb.tmp-1/out.mmdb", "out.mmdb", func() error {
+			fmt.Fprintln(os.Stderr, "warn: couldn't insert '1.1.1.1'")
+			fmt.Fprintf(os.Stderr, "writing to %s (%v entries)\n", "/tmp/.out.mmdb.tmp-1/out.mmdb", 2)
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "warn: couldn't insert '1.1.1.1'\nwriting to out.mmdb (2 entries)\n"
+	if stderr != want {
+		t.Errorf("expected %q, got %q", want, stderr)
+	}
+}
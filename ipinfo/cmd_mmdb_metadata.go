@@ -18,6 +18,8 @@ var completionsMmdbMetadata = &complete.Command{
 		"--help":    predict.Nothing,
 		"-f":        predict.Set(predictMetadataFmts),
 		"--format":  predict.Set(predictMetadataFmts),
+		"-o":        predict.Nothing,
+		"--output":  predict.Nothing,
 	},
 }
 
@@ -32,6 +34,13 @@ Options:
     --help, -h
       show help.
 
+  Output:
+    --output <file>, -o <file>
+      write the output to <file> rather than stdout, replacing <file> only
+      once all of it is written.
+      unless a format is chosen, it's inferred from a '.json' extension;
+      a '.gz' extension after it gzips the output.
+
   Format:
     -f <format>, --format <format>
       the metadata output format.
@@ -40,13 +49,27 @@ Options:
 `, progBase)
 }
 
-func cmdMmdbMetadata() error {
+func cmdMmdbMetadata() (err error) {
+	var fOutput string
+
 	f := lib.CmdMetadataFlags{}
 	f.Init()
+	pflag.StringVarP(&fOutput, "output", "o", "", "file to output to.")
 	pflag.Parse()
 	if pflag.NArg() <= 2 && pflag.NFlag() == 0 {
 		f.Help = true
 	}
 
+	if f.Help {
+		fOutput = ""
+	} else if !pflag.CommandLine.Changed("format") && outputFileFormat(fOutput) == "json" {
+		f.Format = "json"
+	}
+	done, err := redirectOutput(fOutput)
+	if err != nil {
+		return err
+	}
+	defer func() { err = done(err) }()
+
 	return lib.CmdMetadata(f, pflag.Args()[2:], printHelpMmdbMetadata)
 }
@@ -1,11 +1,20 @@
 package main
 
 import (
+	"bufio"
 	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
 
+	"github.com/fatih/color"
+	"github.com/ipinfo/cli/lib"
 	"github.com/ipinfo/cli/lib/complete"
 	"github.com/ipinfo/cli/lib/complete/predict"
+	"github.com/ipinfo/cli/lib/iputil"
 	mmdbLib "github.com/ipinfo/mmdbctl/lib"
+	"github.com/oschwald/maxminddb-golang"
 	"github.com/spf13/pflag"
 )
 
@@ -19,11 +28,17 @@ var predictReadFmts = []string{
 
 var completionsMmdbRead = &complete.Command{
 	Flags: map[string]complete.Predictor{
-		"--nocolor": predict.Nothing,
-		"-h":        predict.Nothing,
-		"--help":    predict.Nothing,
-		"-f":        predict.Set(predictReadFmts),
-		"--format":  predict.Set(predictReadFmts),
+		"--nocolor":       predict.Nothing,
+		"-h":              predict.Nothing,
+		"--help":          predict.Nothing,
+		"-f":              predict.Set(predictReadFmts),
+		"--format":        predict.Set(predictReadFmts),
+		"--table":         predict.Nothing,
+		"--field":         predict.Nothing,
+		"--template":      predict.Nothing,
+		"--template-file": predict.Nothing,
+		"-o":              predict.Nothing,
+		"--output":        predict.Nothing,
 	},
 }
 
@@ -38,22 +53,240 @@ Options:
     --help, -h
       show help.
 
+  Output:
+    --output <file>, -o <file>
+      write the output to <file> rather than stdout, replacing <file> only
+      once all of it is written.
+      unless a format is chosen, it's inferred from a '.json', '.ndjson' or
+      '.csv' extension;
+      a '.gz' extension after it gzips the output.
+
   Format:
     -f <format>, --format <format>
       the output format.
       can be "json", "json-compact", "json-pretty", "tsv" or "csv".
       note that "json" is short for "json-compact".
       default: json.
+    --table
+      output the records as a table with aligned columns instead, with a
+      column for each key and the IP first.
+      on a terminal, long values like 'org' are truncated to fit the table
+      into its width, and tables taller than it are paged through $PAGER.
+    --field <field>
+      with --table, output only columns of specific fields, in order.
+      field names are the record's keys, e.g. 'country', or paths into its
+      nested maps, e.g. 'asn.name', and can be renamed, e.g.
+      'asn.name as as_name'. multiple field names must be separated by
+      commas. (-f is the format.)
+    --template <tmpl>
+      output each record rendered through the Go template <tmpl> instead,
+      e.g. '{{.ip}} {{.country}}'. unlike in the other commands, where
+      templates have the result's fields like '{{.IP}}', the record is a map
+      of its keys in the mmdb file, as in its JSON, with its IP as 'ip'.
+      besides the builtin functions, 'countryName', 'flag', 'join' and 'json'
+      are available.
+    --template-file <file>
+      like --template, with the template read from <file>.
 `, progBase)
 }
 
-func cmdMmdbRead() error {
+func cmdMmdbRead() (err error) {
+	var fTable bool
+	var fField []string
+	var fTemplate string
+	var fTemplateFile string
+	var fOutput string
+
 	f := mmdbLib.CmdReadFlags{}
 	f.Init()
+	pflag.BoolVar(&fTable, "table", false, "output table format.")
+	pflag.StringSliceVar(&fField, "field", nil, "specific fields to output in the table.")
+	pflag.StringVar(&fTemplate, "template", "", "template to output records with.")
+	pflag.StringVar(&fTemplateFile, "template-file", "", "template file to output records with.")
+	pflag.StringVarP(&fOutput, "output", "o", "", "file to output to.")
 	pflag.Parse()
 	if pflag.NArg() <= 2 && pflag.NFlag() == 0 {
 		f.Help = true
 	}
 
+	if f.Help {
+		fOutput = ""
+	} else if !pflag.CommandLine.Changed("format") {
+		switch outputFileFormat(fOutput) {
+		case "json", "ndjson":
+			f.Format = "json"
+		case "csv":
+			f.Format = "csv"
+		}
+	}
+	done, err := redirectOutput(fOutput)
+	if err != nil {
+		return err
+	}
+	defer func() { err = done(err) }()
+
+	tmpl, err := prepareOutputTemplate(fTemplate, fTemplateFile)
+	if err != nil {
+		return err
+	}
+	if len(fField) > 0 && !fTable {
+		return lib.UsageErrorf("--field can only be used with --table")
+	}
+	cols, err := parseMmdbTableFields(fField)
+	if err != nil {
+		return err
+	}
+	if (tmpl != nil || fTable) && !f.Help && pflag.NArg() > 2 {
+		if f.NoColor {
+			color.NoColor = true
+		}
+		if tmpl != nil {
+			return mmdbReadRecords(pflag.Args()[2:], func(record map[string]interface{}) error {
+				return outputTemplate(tmpl, record)
+			})
+		}
+		return mmdbReadTable(pflag.Args()[2:], cols)
+	}
+
 	return mmdbLib.CmdRead(f, pflag.Args()[2:], printHelpMmdbRead)
 }
+
+// mmdbReadRecords passes the record of each IP in `args` from the mmdb file
+// that is the last of the `args` to `emit`, with the IP as "ip" unless the
+// record already has one.
+func mmdbReadRecords(
+	args []string,
+	emit func(record map[string]interface{}) error,
+) error {
+	mmdbFileArg := args[len(args)-1]
+	db, err := maxminddb.Open(mmdbFileArg)
+	if err != nil {
+		return fmt.Errorf("couldn't open mmdb file %v: %w", mmdbFileArg, err)
+	}
+	defer db.Close()
+
+	ips, err := iputil.IPListFromAllSrcs(args[:len(args)-1])
+	if err != nil {
+		return fmt.Errorf("couldn't get IP list: %w", err)
+	}
+
+	for _, ip := range ips {
+		record := make(map[string]interface{})
+		if err := db.Lookup(ip, &record); err != nil || len(record) == 0 {
+			fmt.Fprintf(os.Stderr, "err: couldn't get data for %s\n", ip.String())
+			continue
+		}
+		if _, ok := record["ip"]; !ok {
+			record["ip"] = ip.String()
+		}
+
+		if err := emit(record); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// mmdbTableColumn is a column of a table of mmdb records, of the field at
+// `path` in them.
+type mmdbTableColumn struct {
+	hdr  string
+	path []string
+}
+
+// parseMmdbTableFields parses the --field selection of columns, which are keys
+// of records or paths into their nested maps, optionally followed by
+// 'as <name>' to output them under another name.
+func parseMmdbTableFields(fields []string) ([]mmdbTableColumn, error) {
+	cols := make([]mmdbTableColumn, 0, len(fields))
+	for _, f := range fields {
+		parts := strings.Fields(f)
+		var col mmdbTableColumn
+		switch {
+		case len(parts) == 1:
+			col.hdr = fieldHeaderReplacer.Replace(parts[0])
+		case len(parts) == 3 && strings.EqualFold(parts[1], "as"):
+			col.hdr = parts[2]
+		default:
+			return nil, lib.UsageErrorf("field '%v' is invalid", f)
+		}
+		col.path = strings.Split(parts[0], ".")
+		for _, p := range col.path {
+			if p == "" {
+				return nil, lib.UsageErrorf("field '%v' is invalid", f)
+			}
+		}
+		cols = append(cols, col)
+	}
+	return cols, nil
+}
+
+// value returns the value of the column's field in `record`, or nil if it
+// has none.
+func (col mmdbTableColumn) value(record map[string]interface{}) interface{} {
+	var v interface{} = record
+	for _, p := range col.path {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = m[p]
+	}
+	return v
+}
+
+// mmdbReadTable outputs the records of the IPs in `args` from the mmdb file
+// that is the last of the `args` as a table, with a column for each of the
+// `cols`, or each of their keys if there are none.
+func mmdbReadTable(args []string, cols []mmdbTableColumn) error {
+	var records []map[string]interface{}
+	keys := make(map[string]struct{})
+	err := mmdbReadRecords(args, func(record map[string]interface{}) error {
+		records = append(records, record)
+		for k := range record {
+			keys[k] = struct{}{}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		return nil
+	}
+
+	// the IP first, then the rest sorted.
+	if len(cols) == 0 {
+		delete(keys, "ip")
+		sorted := make([]string, 0, len(keys))
+		for k := range keys {
+			sorted = append(sorted, k)
+		}
+		sort.Strings(sorted)
+		for _, k := range append([]string{"ip"}, sorted...) {
+			cols = append(cols, mmdbTableColumn{hdr: k, path: []string{k}})
+		}
+	}
+	hdrs := make([]string, len(cols))
+	for i, col := range cols {
+		hdrs[i] = col.hdr
+	}
+
+	t := &resultsTable{hdrs: hdrs, header: true}
+	for _, record := range records {
+		row := make([]string, len(cols))
+		for i, col := range cols {
+			if v := col.value(record); v != nil {
+				row[i] = fieldValueString(reflect.ValueOf(v))
+			}
+		}
+		t.Append(row)
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	if err := t.Output(w); err != nil {
+		return err
+	}
+	return w.Flush()
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseMmdbTableFields(t *testing.T) {
+	cols, err := parseMmdbTableFields([]string{
+		"country_name",
+		"asn.name as as_name",
+	})
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	want := []mmdbTableColumn{
+		{hdr: fieldHeaderReplacer.Replace("country_name"), path: []string{"country_name"}},
+		{hdr: "as_name", path: []string{"asn", "name"}},
+	}
+	if !reflect.DeepEqual(cols, want) {
+		t.Fatalf("got %+v, want %+v", cols, want)
+	}
+
+	record := map[string]interface{}{
+		"country_name": "United States",
+		"asn":          map[string]interface{}{"name": "Google LLC"},
+	}
+	if v := cols[0].value(record); v != "United States" {
+		t.Errorf("country_name: got %v", v)
+	}
+	if v := cols[1].value(record); v != "Google LLC" {
+		t.Errorf("asn.name: got %v", v)
+	}
+	missing := mmdbTableColumn{path: []string{"country_name", "code"}}
+	if v := missing.value(record); v != nil {
+		t.Errorf("country_name.code: got %v, want nil", v)
+	}
+
+	for _, f := range []string{"asn.", "a as", "a b c"} {
+		if _, err := parseMmdbTableFields([]string{f}); err == nil {
+			t.Errorf("%q: expected an error", f)
+		}
+	}
+}
@@ -12,22 +12,33 @@ import (
 
 var completionsMyIP = &complete.Command{
 	Flags: map[string]complete.Predictor{
-		"-t":        predict.Nothing,
-		"--token":   predict.Nothing,
-		"--nocache": predict.Nothing,
-		"-h":        predict.Nothing,
-		"--help":    predict.Nothing,
-		"-f":        predict.Set(coreFields),
-		"--field":   predict.Set(coreFields),
-		"--nocolor": predict.Nothing,
-		"-p":        predict.Nothing,
-		"--pretty":  predict.Nothing,
-		"-j":        predict.Nothing,
-		"--json":    predict.Nothing,
-		"-c":        predict.Nothing,
-		"--csv":     predict.Nothing,
-		"-6":        predict.Nothing,
-		"--ipv6":    predict.Nothing,
+		"-t":              predict.Nothing,
+		"--token":         predict.Nothing,
+		"--nocache":       predict.Nothing,
+		"--cache-ttl":     predict.Nothing,
+		"--retries":       predict.Nothing,
+		"--max-backoff":   predict.Nothing,
+		"-h":              predict.Nothing,
+		"--help":          predict.Nothing,
+		"-f":              predict.Set(coreFields),
+		"--field":         predict.Set(coreFields),
+		"--lang":          predict.Set(localeLangs),
+		"--nocolor":       predict.Nothing,
+		"-o":              predict.Nothing,
+		"--output":        predict.Nothing,
+		"-p":              predict.Nothing,
+		"--pretty":        predict.Nothing,
+		"-j":              predict.Nothing,
+		"--json":          predict.Nothing,
+		"-c":              predict.Nothing,
+		"--csv":           predict.Nothing,
+		"-6":              predict.Nothing,
+		"--ipv6":          predict.Nothing,
+		"--ndjson":        predict.Nothing,
+		"--jsonl":         predict.Nothing,
+		"--template":      predict.Nothing,
+		"--format":        predict.Nothing,
+		"--template-file": predict.Nothing,
 	},
 }
 
@@ -43,6 +54,16 @@ Options:
       get IPv6 address.
     --nocache
       do not use the cache.
+    --cache-ttl <ttl>
+      use cached results for at most <ttl>, e.g. '6h' or '7d', or per type
+      of result, e.g. 'core=6h,asn=7d'.
+      default: the config's cache_ttl_<type>, or 1d.
+    --retries <n>
+      retry requests failing with a network error, 5xx or 429 up to <n> times.
+      default: 3.
+    --max-backoff <duration>
+      wait at most <duration> between retries, e.g. '10s' or '1m'.
+      default: 30s.
     --help, -h
       show help.
 
@@ -51,8 +72,19 @@ Options:
       lookup only specific fields in the output.
       field names correspond to JSON keys, e.g. 'hostname' or 'company.type'.
       multiple field names must be separated by commas.
+      lists can be indexed, e.g. 'domains.domains[0]' or 'domains.domains[]'
+      for all elements, and fields renamed, e.g. 'asn.id as asn_id'.
+    --lang <code>
+      localize the country and continent names and the labels of the pretty
+      output into <code>: one of en, de, es or ja.
+      defaults to the config's lang, if set, or en.
     --nocolor
       disable colored output.
+    --output <file>, -o <file>
+      write the output to <file> rather than stdout, replacing <file> only
+      once all of it is written.
+      unless a format is chosen, it's inferred from a '.json', '.csv', '.yaml'
+      or '.ndjson' extension; a '.gz' extension after it gzips the output.
 
   Formats:
     --pretty, -p
@@ -63,10 +95,20 @@ Options:
       output CSV format.
     --yaml, -y
       output YAML format.
+    --ndjson, --jsonl
+      output NDJSON format, one compact JSON object per line.
+      with --field, only the selected fields and the IP are output.
+    --template <tmpl>, --format <tmpl>
+      output the result rendered through the Go template <tmpl>, e.g.
+      '{{.IP}} {{.Country}}'.
+      besides the builtin functions, 'countryName', 'flag', 'join' and 'json'
+      are available.
+    --template-file <file>
+      like --template, with the template read from <file>.
 `, progBase)
 }
 
-func cmdMyIP() error {
+func cmdMyIP() (err error) {
 	var fTok string
 	var fField []string
 	var fPretty bool
@@ -74,17 +116,31 @@ func cmdMyIP() error {
 	var fCSV bool
 	var fYAML bool
 	var fV6 bool
+	var fNDJSON bool
+	var fTemplate string
+	var fTemplateFile string
+	var fOutput string
 
 	pflag.StringVarP(&fTok, "token", "t", "", "the token to use.")
 	pflag.BoolVar(&fNoCache, "nocache", true, "disable the cache.")
+	pflag.Var(&fCacheTTL, "cache-ttl", "max age of cached results.")
+	pflag.IntVar(&fRetries, "retries", defaultRetries, "max retries of failed requests.")
+	pflag.DurationVar(&fMaxBackoff, "max-backoff", defaultMaxBackoff, "max wait between retries.")
 	pflag.BoolVarP(&fHelp, "help", "h", false, "show help.")
 	pflag.StringSliceVarP(&fField, "field", "f", nil, "specific field to lookup.")
 	pflag.BoolVarP(&fPretty, "pretty", "p", true, "output pretty format.")
 	pflag.BoolVarP(&fJSON, "json", "j", false, "output JSON format.")
 	pflag.BoolVarP(&fCSV, "csv", "c", false, "output CSV format.")
 	pflag.BoolVarP(&fYAML, "yaml", "y", false, "output YAML format.")
+	pflag.BoolVar(&fNDJSON, "ndjson", false, "output NDJSON format.")
+	pflag.BoolVar(&fNDJSON, "jsonl", false, "output NDJSON format.")
+	pflag.StringVar(&fTemplate, "template", "", "template to output results with.")
+	pflag.StringVar(&fTemplate, "format", "", "template to output results with.")
+	pflag.StringVar(&fTemplateFile, "template-file", "", "template file to output results with.")
 	pflag.BoolVar(&fNoColor, "nocolor", false, "disable color output.")
+	pflag.StringVar(&fLang, "lang", "", "language of the pretty output.")
 	pflag.BoolVarP(&fV6, "ipv6", "6", false, "use IPv6 address.")
+	pflag.StringVarP(&fOutput, "output", "o", "", "file to output to.")
 	pflag.Parse()
 
 	if fNoColor {
@@ -96,10 +152,30 @@ func cmdMyIP() error {
 		return nil
 	}
 
+	if err := setLocale(fLang); err != nil {
+		return err
+	}
+
+	inferOutputFormat(fOutput, map[string]*bool{
+		"json":   &fJSON,
+		"csv":    &fCSV,
+		"yaml":   &fYAML,
+		"ndjson": &fNDJSON,
+	})
+	done, err := redirectOutput(fOutput)
+	if err != nil {
+		return err
+	}
+	defer func() { err = done(err) }()
+
+	tmpl, err := prepareOutputTemplate(fTemplate, fTemplateFile)
+	if err != nil {
+		return err
+	}
+
 	ii = prepareIpinfoClient(fTok)
 
 	var data *ipinfo.Core
-	var err error
 	if fV6 {
 		data, err = ii.GetIPInfoV6(nil)
 	} else {
@@ -109,6 +185,12 @@ func cmdMyIP() error {
 		return err
 	}
 
+	if tmpl != nil {
+		return outputTemplate(tmpl, data)
+	}
+	if fNDJSON {
+		return outputNDJSONCore(data, fField)
+	}
 	if len(fField) > 0 {
 		d := make(ipinfo.BatchCore, 1)
 		d[data.IP.String()] = data
@@ -118,7 +200,7 @@ func cmdMyIP() error {
 		return outputJSON(data)
 	}
 	if fCSV {
-		return outputCSV(data)
+		return outputCSVBatchCore(ipinfo.BatchCore{data.IP.String(): data})
 	}
 	if fYAML {
 		return outputYAML(data)
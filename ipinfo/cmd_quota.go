@@ -2,13 +2,13 @@ package main
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
 	"math"
 	"net/http"
 	"strings"
 
 	"github.com/fatih/color"
+	"github.com/ipinfo/cli/lib"
 	"github.com/ipinfo/cli/lib/complete"
 	"github.com/ipinfo/cli/lib/complete/predict"
 	"github.com/spf13/pflag"
@@ -81,7 +81,7 @@ func cmdQuota() error {
 
 	token := gConfig.Token
 	if token == "" {
-		return errors.New("please login first to check quota")
+		return lib.AuthErrorf("please login first to check quota")
 	}
 
 	res, err := http.Get("https://ipinfo.io/me?token=" + token)
@@ -5,7 +5,6 @@ import (
 	"net"
 	"sort"
 	"strconv"
-	"strings"
 
 	"github.com/fatih/color"
 	"github.com/ipinfo/cli/lib/complete"
@@ -17,15 +16,26 @@ import (
 
 var completionsSummarize = &complete.Command{
 	Flags: map[string]complete.Predictor{
-		"-t":        predict.Nothing,
-		"--token":   predict.Nothing,
-		"-h":        predict.Nothing,
-		"--help":    predict.Nothing,
-		"--nocolor": predict.Nothing,
-		"-p":        predict.Nothing,
-		"--pretty":  predict.Nothing,
-		"-j":        predict.Nothing,
-		"--json":    predict.Nothing,
+		"-t":            predict.Nothing,
+		"--token":       predict.Nothing,
+		"--retries":     predict.Nothing,
+		"--max-backoff": predict.Nothing,
+		"--db":          predict.Nothing,
+		"-h":            predict.Nothing,
+		"--help":        predict.Nothing,
+		"--lang":        predict.Set(localeLangs),
+		"--nocolor":     predict.Nothing,
+		"-o":            predict.Nothing,
+		"--output":      predict.Nothing,
+		"-p":            predict.Nothing,
+		"--pretty":      predict.Nothing,
+		"-j":            predict.Nothing,
+		"--json":        predict.Nothing,
+		"--ndjson":      predict.Nothing,
+		"--jsonl":       predict.Nothing,
+		"--html":        predict.Nothing,
+		"--markdown":    predict.Nothing,
+		"--chart":       predict.Nothing,
 	},
 }
 
@@ -56,16 +66,38 @@ Examples:
   # Summarize all IPs from multiple sources simultaneously.
   $ %[1]s summarize 8.8.8.0-8.8.8.255 1.1.1.0/30 123.123.123.123 ips.txt
 
+  # Write a markdown report with bar charts for a ticket.
+  $ %[1]s summarize ips.txt --markdown --chart -o report.md
+
 Options:
   General:
     --token <tok>, -t <tok>
       use <tok> as API token.
+    --retries <n>
+      retry requests failing with a network error, 5xx or 429 up to <n> times.
+      default: 3.
+    --max-backoff <duration>
+      wait at most <duration> between retries, e.g. '10s' or '1m'.
+      default: 30s.
+    --db <path>
+      summarize from the mmdb file at <path> instead of the API.
+      defaults to the config's db, if set.
     --help, -h
       show help.
 
   Outputs:
+    --lang <code>
+      localize the country and continent names and the labels of the pretty,
+      HTML and markdown output into <code>: one of en, de, es or ja.
+      defaults to the config's lang, if set, or en.
     --nocolor
       disable colored output.
+    --output <file>, -o <file>
+      write the output to <file> rather than stdout, replacing <file> only
+      once all of it is written.
+      unless a format is chosen, it's inferred from a '.json', '.yaml',
+      '.ndjson', '.html' or '.md' extension; a '.gz' extension after it gzips
+      the output.
 
   Formats:
     --pretty, -p
@@ -74,6 +106,14 @@ Options:
       output JSON format.
     --yaml, -y
       output YAML format.
+    --ndjson, --jsonl
+      output NDJSON format, the summary as one compact JSON object.
+    --html
+      output an HTML report, a standalone page with a table per section.
+    --markdown
+      output a markdown report, with a table per section.
+    --chart
+      with --html or --markdown, add a bar chart of the shares to each table.
 `, progBase)
 }
 
@@ -83,13 +123,29 @@ func cmdSum() (err error) {
 	var fPretty bool
 	var fJSON bool
 	var fYAML bool
+	var fDB string
+	var fNDJSON bool
+	var fOutput string
+	var fHTML bool
+	var fMarkdown bool
+	var fChart bool
 
 	pflag.StringVarP(&fTok, "token", "t", "", "the token to use.")
+	pflag.IntVar(&fRetries, "retries", defaultRetries, "max retries of failed requests.")
+	pflag.DurationVar(&fMaxBackoff, "max-backoff", defaultMaxBackoff, "max wait between retries.")
+	pflag.StringVar(&fDB, "db", "", "mmdb file to summarize from.")
 	pflag.BoolVarP(&fHelp, "help", "h", false, "show help.")
 	pflag.BoolVarP(&fPretty, "pretty", "p", true, "output pretty format. (default)")
 	pflag.BoolVarP(&fJSON, "json", "j", false, "output JSON format.")
-	pflag.BoolVarP(&fJSON, "yaml", "y", false, "output YAML format.")
+	pflag.BoolVarP(&fYAML, "yaml", "y", false, "output YAML format.")
+	pflag.BoolVar(&fNDJSON, "ndjson", false, "output NDJSON format.")
+	pflag.BoolVar(&fNDJSON, "jsonl", false, "output NDJSON format.")
+	pflag.BoolVar(&fHTML, "html", false, "output HTML report.")
+	pflag.BoolVar(&fMarkdown, "markdown", false, "output markdown report.")
+	pflag.BoolVar(&fChart, "chart", false, "add bar charts to the report.")
 	pflag.BoolVar(&fNoColor, "nocolor", false, "disable color output.")
+	pflag.StringVar(&fLang, "lang", "", "language of the pretty output.")
+	pflag.StringVarP(&fOutput, "output", "o", "", "file to output to.")
 	pflag.Parse()
 
 	if fNoColor {
@@ -101,6 +157,23 @@ func cmdSum() (err error) {
 		return nil
 	}
 
+	if err := setLocale(fLang); err != nil {
+		return err
+	}
+
+	inferOutputFormat(fOutput, map[string]*bool{
+		"json":     &fJSON,
+		"yaml":     &fYAML,
+		"ndjson":   &fNDJSON,
+		"html":     &fHTML,
+		"markdown": &fMarkdown,
+	})
+	done, err := redirectOutput(fOutput)
+	if err != nil {
+		return err
+	}
+	defer func() { err = done(err) }()
+
 	ips, err = iputil.IPListFromAllSrcs(pflag.Args()[1:])
 	if err != nil {
 		return err
@@ -110,18 +183,30 @@ func cmdSum() (err error) {
 		return nil
 	}
 
-	ii = prepareIpinfoClient(fTok)
-	d, err := ii.GetIPSummary(ips)
+	l, err := prepareIPLookuper(fTok, fDB, false)
+	if err != nil {
+		return err
+	}
+	d, err := l.GetIPSummary(ips)
 	if err != nil {
 		return err
 	}
 
+	if fNDJSON {
+		return outputNDJSON(d)
+	}
 	if fJSON {
 		return outputJSON(d)
 	}
 	if fYAML {
 		return outputYAML(d)
 	}
+	if fHTML {
+		return outputSummaryHTML(d, fChart)
+	}
+	if fMarkdown {
+		return outputSummaryMarkdown(d, fChart)
+	}
 
 	// print pretty.
 	var entryLen string
@@ -130,11 +215,15 @@ func cmdSum() (err error) {
 	entry := color.New(color.FgCyan)
 	num := color.New(color.FgGreen)
 
-	header.Println("Summary")
+	header.Println(tr("Summary"))
+	headerLen := labelWidth(
+		"Total", "Unique", "Anycast", "Bogon", "Mobile",
+		"VPN", "Proxy", "Hosting", "Tor", "Relay",
+	)
 	headerPrint := func(name string, val uint64) {
 		fmt.Printf(
 			"- %s %s\n",
-			entry.Sprintf("%-7s", name),
+			entry.Sprint(padLabel(tr(name), headerLen)),
 			num.Sprintf("%v", val),
 		)
 	}
@@ -150,7 +239,7 @@ func cmdSum() (err error) {
 	headerPrint("Relay", d.Privacy.Relay)
 	fmt.Println()
 
-	header.Println("Top ASNs")
+	header.Println(tr("Top ASNs"))
 	topASNs := orderSummaryMapping(d.ASNs)
 	entryLen = strconv.Itoa(longestKeyLen(topASNs))
 	for _, asnSum := range topASNs {
@@ -165,16 +254,11 @@ func cmdSum() (err error) {
 	}
 	fmt.Println()
 
-	header.Println("Top Usage Types")
+	header.Println(tr("Top Usage Types"))
 	topUsageTypes := orderSummaryMapping(d.IPTypes)
 	entryLen = strconv.Itoa(longestKeyLen(topUsageTypes))
 	for _, usageTypeSum := range topUsageTypes {
-		k := usageTypeSum.k
-		if k == "isp" {
-			k = "ISP"
-		} else {
-			k = strings.Title(k)
-		}
+		k := sumUsageTypeName(usageTypeSum.k)
 		v := usageTypeSum.v
 		pct := (float64(v) / float64(d.Total)) * 100
 		fmt.Printf(
@@ -185,49 +269,45 @@ func cmdSum() (err error) {
 	}
 	fmt.Println()
 
-	header.Println("Top Routes")
+	header.Println(tr("Top Routes"))
 	topRoutes := orderSummaryMapping(d.Routes)
 	entryLen = strconv.Itoa(longestKeyLen(topRoutes) + 2)
 	for _, routesSum := range topRoutes {
 		k := routesSum.k
 		v := routesSum.v
-		routeParts := strings.SplitN(k, " ", 2)
-		asn := routeParts[0]
-		route := routeParts[1]
 		pct := (float64(v) / float64(d.Total)) * 100
 		fmt.Printf(
 			"- %v %v\n",
-			entry.Sprintf(
-				"%-"+entryLen+"s",
-				fmt.Sprintf("%s (%s)", route, asn),
-			),
+			entry.Sprintf("%-"+entryLen+"s", sumRouteName(k)),
 			num.Sprintf("%v (%.1f%%)", v, pct),
 		)
 	}
 	fmt.Println()
 
-	header.Println("Top Countries")
+	header.Println(tr("Top Countries"))
 	topCountries := orderSummaryMapping(d.Countries)
 	for _, p := range topCountries {
-		l := ipinfo.GetCountryName(p.k)
-		if len(l) > tmpEntryLen {
-			tmpEntryLen = len(l)
+		l := localeCountryName(p.k, ipinfo.GetCountryName(p.k))
+		if n := displayWidth(l); n > tmpEntryLen {
+			tmpEntryLen = n
 		}
 	}
-	entryLen = strconv.Itoa(tmpEntryLen)
 	for _, countriesSum := range topCountries {
 		k := countriesSum.k
 		v := countriesSum.v
 		pct := (float64(v) / float64(d.Total)) * 100
 		fmt.Printf(
 			"- %v %v\n",
-			entry.Sprintf("%-"+entryLen+"s", ipinfo.GetCountryName(k)),
+			entry.Sprint(padLabel(
+				localeCountryName(k, ipinfo.GetCountryName(k)),
+				tmpEntryLen,
+			)),
 			num.Sprintf("%v (%.1f%%)", v, pct),
 		)
 	}
 	fmt.Println()
 
-	header.Println("Top Cities")
+	header.Println(tr("Top Cities"))
 	topCities := orderSummaryMapping(d.Cities)
 	entryLen = strconv.Itoa(longestKeyLen(topCities))
 	for _, citiesSum := range topCities {
@@ -242,7 +322,7 @@ func cmdSum() (err error) {
 	}
 	fmt.Println()
 
-	header.Println("Top Regions")
+	header.Println(tr("Top Regions"))
 	topRegions := orderSummaryMapping(d.Regions)
 	entryLen = strconv.Itoa(longestKeyLen(topRegions))
 	for _, regionsSum := range topRegions {
@@ -258,7 +338,7 @@ func cmdSum() (err error) {
 
 	if len(d.Carriers) > 0 {
 		fmt.Println()
-		header.Println("Top Carriers")
+		header.Println(tr("Top Carriers"))
 		topCarriers := orderSummaryMapping(d.Carriers)
 		entryLen = strconv.Itoa(longestKeyLen(topCarriers))
 		for _, carriersSum := range topCarriers {
@@ -275,7 +355,7 @@ func cmdSum() (err error) {
 
 	if len(d.PrivacyServices) > 0 {
 		fmt.Println()
-		header.Println("Top Privacy Services")
+		header.Println(tr("Top Privacy Services"))
 		topPrivacyServices := orderSummaryMapping(d.PrivacyServices)
 		entryLen = strconv.Itoa(longestKeyLen(topPrivacyServices))
 		for _, privacyServicesSum := range topPrivacyServices {
@@ -292,7 +372,7 @@ func cmdSum() (err error) {
 
 	if len(d.Domains) > 0 && d.Domains["total"] > 0 {
 		fmt.Println()
-		header.Println("Top Domains")
+		header.Println(tr("Top Domains"))
 
 		// don't let the 'total' key interfere with topDomains/entryLen
 		// calculations.
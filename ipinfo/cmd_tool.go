@@ -144,9 +144,5 @@ func cmdTool() error {
 		err = toolHelp()
 	}
 
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "err: %v\n", err)
-	}
-
-	return nil
+	return err
 }
@@ -71,9 +71,5 @@ func cmdToolPrefix() error {
 		err = toolPrefixHelp()
 	}
 
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "err: %v\n", err)
-	}
-
-	return nil
+	return err
 }
@@ -38,10 +38,11 @@ var completions = &complete.Command{
 		"version":    completionsVersion,
 	},
 	Flags: map[string]complete.Predictor{
-		"-v":        predict.Nothing,
-		"--version": predict.Nothing,
-		"-h":        predict.Nothing,
-		"--help":    predict.Nothing,
+		"--error-format": predict.Set([]string{"text", "json"}),
+		"-v":             predict.Nothing,
+		"--version":      predict.Nothing,
+		"-h":             predict.Nothing,
+		"--help":         predict.Nothing,
 	},
 }
 
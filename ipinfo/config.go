@@ -13,9 +13,22 @@ import (
 var gConfig Config
 
 type Config struct {
-	CacheEnabled bool   `json:"cache_enabled"`
-	Token        string `json:"token"`
-	OpenBrowser  bool   `json:"open_browser"`
+	CacheEnabled bool    `json:"cache_enabled"`
+	Token        string  `json:"token"`
+	OpenBrowser  bool    `json:"open_browser"`
+	DB           string  `json:"db,omitempty"`
+	Concurrency  int     `json:"concurrency,omitempty"`
+	BatchSize    int     `json:"batch_size,omitempty"`
+	Timeout      string  `json:"timeout,omitempty"`
+	Rate         float64 `json:"rate,omitempty"`
+	Lang         string  `json:"lang,omitempty"`
+
+	// the cache policy, which is the default if unset.
+	CacheTTLCore   string `json:"cache_ttl_core,omitempty"`
+	CacheTTLASN    string `json:"cache_ttl_asn,omitempty"`
+	CacheTTLString string `json:"cache_ttl_string,omitempty"`
+	CacheTTLMap    string `json:"cache_ttl_map,omitempty"`
+	CacheMaxSize   string `json:"cache_max_size,omitempty"`
 }
 
 // gets the global config directory, creating it if necessary.
@@ -136,7 +149,25 @@ func SaveConfig(config Config) error {
 		return err
 	}
 
-	if err := ioutil.WriteFile(configPath, jsonData, 0644); err != nil {
+	// write through a temp file, so that concurrent processes never read it
+	// partially written.
+	f, err := ioutil.TempFile(filepath.Dir(configPath), ".config.json.*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(jsonData); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Chmod(0644); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(f.Name(), configPath); err != nil {
 		return err
 	}
 
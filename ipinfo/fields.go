@@ -0,0 +1,462 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ipinfo/cli/lib"
+	"github.com/ipinfo/go/v2/ipinfo"
+)
+
+// fieldSchema describes the fields that can be selected with --field from
+// results of a struct type, which are paths into its JSON like
+// 'asn.name', 'prefixes[0].netblock' or 'prefixes[].netblock'.
+type fieldSchema struct {
+	typ reflect.Type
+
+	// alternative names of paths.
+	aliases map[string]string
+
+	// paths which are kept selecting what they did before fields were paths
+	// into the JSON, like 'asn.asn' selecting the AS name rather than its ID.
+	legacy map[string]string
+
+	// top-level fields which aren't part of the JSON, by their Go field name.
+	extra map[string]string
+
+	// all valid paths, for completions and errors.
+	paths []string
+}
+
+// the fields of `ipinfo.Core` results.
+var coreSchema = newFieldSchema(
+	ipinfo.Core{},
+	map[string]string{"asn.id": "asn.asn"},
+	nil,
+	map[string]string{"asn.asn": "asn.name"},
+)
+
+// the fields of `ipinfo.ASNDetails` results.
+var asnSchema = newFieldSchema(
+	ipinfo.ASNDetails{},
+	map[string]string{"id": "asn"},
+	map[string]string{"country_name": "CountryName"},
+	nil,
+)
+
+var coreFields = coreSchema.paths
+var asnFields = asnSchema.paths
+
+func newFieldSchema(
+	v interface{},
+	aliases map[string]string,
+	extra map[string]string,
+	legacy map[string]string,
+) *fieldSchema {
+	s := &fieldSchema{
+		typ:     reflect.TypeOf(v),
+		aliases: aliases,
+		extra:   extra,
+		legacy:  legacy,
+	}
+	s.paths = appendFieldPaths(nil, "", s.typ)
+	s.paths = append(s.paths, sortedKeys(extra)...)
+	s.paths = append(s.paths, sortedKeys(aliases)...)
+	return s
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// appends all paths into the JSON of type `t` to `paths`, with `prefix`.
+func appendFieldPaths(paths []string, prefix string, t reflect.Type) []string {
+	t = derefType(t)
+	switch {
+	case isLeafType(t):
+	case t.Kind() == reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			name, ok := jsonFieldName(t.Field(i))
+			if !ok {
+				continue
+			}
+			if prefix != "" {
+				name = prefix + "." + name
+			}
+			paths = append(paths, name)
+			paths = appendFieldPaths(paths, name, t.Field(i).Type)
+		}
+	case t.Kind() == reflect.Slice:
+		if elem := derefType(t.Elem()); !isLeafType(elem) {
+			paths = appendFieldPaths(paths, prefix+"[]", elem)
+		}
+	}
+	return paths
+}
+
+// returns the JSON name of `f`, and whether it's part of the JSON at all.
+func jsonFieldName(f reflect.StructField) (string, bool) {
+	if f.PkgPath != "" {
+		return "", false
+	}
+	name := strings.Split(f.Tag.Get("json"), ",")[0]
+	if name == "-" {
+		return "", false
+	}
+	if name == "" {
+		name = f.Name
+	}
+	return name, true
+}
+
+func derefType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+var ipType = reflect.TypeOf(net.IP{})
+
+// whether values of `t` are output as a single value, rather than as the
+// fields or elements they consist of.
+func isLeafType(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Struct, reflect.Slice, reflect.Array, reflect.Map:
+		return t == ipType
+	}
+	return true
+}
+
+// fieldStep is a single step along a field's path: either into a struct
+// field or into the element at an index of a slice, or all its elements.
+type fieldStep struct {
+	field    []int
+	index    int
+	wildcard bool
+}
+
+// selectedField is a field selected with --field.
+type selectedField struct {
+	// what the field is output as: the alias given, or its path.
+	name  string
+	alias bool
+
+	// the field's canonical path and its steps.
+	path  string
+	steps []fieldStep
+
+	// the type of the field's values.
+	typ reflect.Type
+
+	// whether the field has multiple values, due to a wildcard.
+	multi bool
+}
+
+// matches a single part of a path, like 'prefixes', 'prefixes[0]' or
+// 'prefixes[]'.
+var fieldPartRegex = regexp.MustCompile(`^([A-Za-z0-9_]+)((?:\[(?:\d+|\*)?\])*)$`)
+var fieldIndexRegex = regexp.MustCompile(`\[(\d+|\*)?\]`)
+
+// selectFields parses `fields`, which are paths optionally followed by
+// 'as <name>' to output them under another name.
+func (s *fieldSchema) selectFields(fields []string) ([]selectedField, error) {
+	sel := make([]selectedField, 0, len(fields))
+	for _, f := range fields {
+		sf, err := s.selectField(f)
+		if err != nil {
+			errStr := "field '%v' is invalid; the following are allowed:"
+			errStr += "  " + strings.Join(s.paths, "\n  ")
+			return nil, lib.UsageErrorf(errStr, f)
+		}
+		sel = append(sel, sf)
+	}
+	return sel, nil
+}
+
+func (s *fieldSchema) selectField(f string) (selectedField, error) {
+	sf := selectedField{}
+
+	parts := strings.Fields(f)
+	switch {
+	case len(parts) == 1:
+		sf.name = parts[0]
+	case len(parts) == 3 && strings.EqualFold(parts[1], "as"):
+		sf.name = parts[2]
+		sf.alias = true
+	default:
+		return sf, fmt.Errorf("invalid field %q", f)
+	}
+
+	sf.path = parts[0]
+	if p, ok := s.legacy[sf.path]; ok {
+		sf.path = p
+	} else if p, ok := s.aliases[sf.path]; ok {
+		sf.path = p
+	}
+
+	t := s.typ
+	for i, part := range strings.Split(sf.path, ".") {
+		m := fieldPartRegex.FindStringSubmatch(part)
+		if m == nil {
+			return sf, fmt.Errorf("invalid path %q", sf.path)
+		}
+
+		// into the struct field.
+		t = derefType(t)
+		if t.Kind() != reflect.Struct || t == ipType {
+			return sf, fmt.Errorf("no field %q", m[1])
+		}
+		var field reflect.StructField
+		found := false
+		if goName, ok := s.extra[m[1]]; ok && i == 0 {
+			field, found = t.FieldByName(goName)
+		} else {
+			for j := 0; j < t.NumField(); j++ {
+				if name, ok := jsonFieldName(t.Field(j)); ok && name == m[1] {
+					field, found = t.Field(j), true
+					break
+				}
+			}
+		}
+		if !found {
+			return sf, fmt.Errorf("no field %q", m[1])
+		}
+		sf.steps = append(sf.steps, fieldStep{field: field.Index})
+		t = field.Type
+
+		// into the slice's elements.
+		for _, idx := range fieldIndexRegex.FindAllStringSubmatch(m[2], -1) {
+			t = derefType(t)
+			if t.Kind() != reflect.Slice || t == ipType {
+				return sf, fmt.Errorf("%q is not a list", m[1])
+			}
+			step := fieldStep{wildcard: idx[1] == "" || idx[1] == "*"}
+			if !step.wildcard {
+				step.index, _ = strconv.Atoi(idx[1])
+			} else {
+				sf.multi = true
+			}
+			sf.steps = append(sf.steps, step)
+			t = t.Elem()
+		}
+	}
+	sf.typ = derefType(t)
+
+	return sf, nil
+}
+
+// values returns the values of the field in `v`, which are none if it's
+// missing.
+func (sf *selectedField) values(v reflect.Value) []reflect.Value {
+	vals := []reflect.Value{v}
+	for _, step := range sf.steps {
+		next := make([]reflect.Value, 0, len(vals))
+		for _, v := range vals {
+			for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+				if v.IsNil() {
+					break
+				}
+				v = v.Elem()
+			}
+			switch {
+			case v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface:
+				// nil.
+			case step.field != nil:
+				next = append(next, v.FieldByIndex(step.field))
+			case step.wildcard:
+				for i := 0; i < v.Len(); i++ {
+					next = append(next, v.Index(i))
+				}
+			case step.index < v.Len():
+				next = append(next, v.Index(step.index))
+			}
+		}
+		vals = next
+	}
+	return vals
+}
+
+// Value returns the field's value in `v` to output as JSON; a list if the
+// field has multiple values, and nil if it's missing.
+func (sf *selectedField) Value(v reflect.Value) interface{} {
+	vals := sf.values(v)
+	if sf.multi {
+		list := make([]interface{}, 0, len(vals))
+		for _, v := range vals {
+			list = append(list, v.Interface())
+		}
+		return list
+	}
+	if len(vals) == 0 {
+		return nil
+	}
+	if vals[0].Kind() == reflect.Pointer && vals[0].IsNil() {
+		return nil
+	}
+	return vals[0].Interface()
+}
+
+// Cell returns the field's value in `v` as a string to output in a cell,
+// with multiple values joined by commas.
+func (sf *selectedField) Cell(v reflect.Value) string {
+	vals := sf.values(v)
+	strs := make([]string, 0, len(vals))
+	for _, v := range vals {
+		strs = append(strs, fieldValueString(v))
+	}
+	return strings.Join(strs, ",")
+}
+
+// returns the string of a single value to output in a cell; lists of single
+// values are joined by commas, and anything else is compact JSON.
+func fieldValueString(v reflect.Value) string {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+
+	switch {
+	case v.Type() == ipType:
+		if v.Len() == 0 {
+			return ""
+		}
+		return v.Interface().(net.IP).String()
+	case v.Kind() == reflect.String:
+		return v.String()
+	case v.Kind() == reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case v.Kind() == reflect.Slice && isLeafType(derefType(v.Type().Elem())):
+		strs := make([]string, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			strs = append(strs, fieldValueString(v.Index(i)))
+		}
+		return strings.Join(strs, ",")
+	case isLeafType(v.Type()):
+		return fmt.Sprintf("%v", v.Interface())
+	}
+
+	b, err := json.Marshal(v.Interface())
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+// columns returns the header and cell value function of each column to output
+// the selected fields with, where fields of a struct type are expanded into a
+// column for each of its own fields.
+func (s *fieldSchema) columns(
+	sel []selectedField,
+) ([]string, []func(reflect.Value) string) {
+	// names of paths which have a preferred alias, like 'asn.id'.
+	preferred := make(map[string]string, len(s.aliases))
+	for alias, path := range s.aliases {
+		if strings.Contains(alias, ".") {
+			preferred[path] = alias
+		}
+	}
+
+	var hdrs []string
+	var cells []func(reflect.Value) string
+	var add func(sf selectedField)
+	add = func(sf selectedField) {
+		if sf.multi || sf.typ.Kind() != reflect.Struct || sf.typ == ipType {
+			hdr := sf.name
+			if !sf.alias {
+				hdr = fieldHeaderReplacer.Replace(hdr)
+			}
+			hdrs = append(hdrs, hdr)
+			cells = append(cells, sf.Cell)
+			return
+		}
+
+		for i := 0; i < sf.typ.NumField(); i++ {
+			f := sf.typ.Field(i)
+			name, ok := jsonFieldName(f)
+			if !ok || f.Tag.Get("csv") == "-" {
+				continue
+			}
+			child := selectedField{
+				path:  sf.path + "." + name,
+				steps: append(append([]fieldStep{}, sf.steps...), fieldStep{field: f.Index}),
+				typ:   derefType(f.Type),
+			}
+			switch p, ok := preferred[child.path]; {
+			case sf.alias:
+				child.name = sf.name + "_" + name
+				child.alias = true
+			case ok && sf.name == sf.path:
+				child.name = p
+			default:
+				child.name = sf.name + "." + name
+			}
+			add(child)
+		}
+	}
+	for _, sf := range sel {
+		add(sf)
+	}
+
+	return hdrs, cells
+}
+
+// turns a path into a header name, like 'prefixes[0].netblock' into
+// 'prefixes_0_netblock'.
+var fieldHeaderReplacer = strings.NewReplacer(
+	"[]", "", "[*]", "", "[", "_", "]", "", ".", "_",
+)
+
+// object returns the selected fields of `v` as a JSON object, with fields
+// nested like in `v` unless they have an alias or an index, which are output
+// as is.
+func (s *fieldSchema) object(v reflect.Value, sel []selectedField) map[string]interface{} {
+	out := make(map[string]interface{}, len(sel))
+	for _, sf := range sel {
+		if sf.alias || strings.Contains(sf.path, "[") {
+			out[sf.name] = sf.Value(v)
+			continue
+		}
+
+		keys := strings.Split(sf.path, ".")
+		dst := out
+		for _, k := range keys[:len(keys)-1] {
+			next, ok := dst[k].(map[string]interface{})
+			if !ok {
+				next = make(map[string]interface{})
+				dst[k] = next
+			}
+			dst = next
+		}
+		dst[keys[len(keys)-1]] = sf.Value(v)
+	}
+	return out
+}
+
+// prependField returns `sel` with the field at `path` first, unless it's
+// already selected.
+func (s *fieldSchema) prependField(sel []selectedField, path string) []selectedField {
+	for _, sf := range sel {
+		if sf.path == path {
+			return sel
+		}
+	}
+	sf, err := s.selectField(path)
+	if err != nil {
+		panic(err)
+	}
+	return append([]selectedField{sf}, sel...)
+}
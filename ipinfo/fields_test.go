@@ -0,0 +1,120 @@
+package main
+
+import (
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ipinfo/go/v2/ipinfo"
+)
+
+// Fields are paths into the JSON, optionally with an alias, and fields of a
+// struct type expand into a column for each of its own fields.
+func TestPrepareFieldsCore(t *testing.T) {
+	d := &ipinfo.Core{
+		IP:      net.ParseIP("8.8.8.8"),
+		Country: "US",
+		ASN:     &ipinfo.CoreASN{ASN: "AS15169", Name: "Google, LLC"},
+		Domains: &ipinfo.CoreDomains{Total: 2, Domains: []string{"a.com", "b.com"}},
+	}
+
+	tests := []struct {
+		fields []string
+		hdrs   string
+		row    string
+	}{
+		{
+			[]string{"country", "asn.id as asn_id"},
+			"ip,country,asn_id",
+			"8.8.8.8,US,AS15169",
+		},
+		{
+			// 'asn.asn' is the AS name, as it was before fields were paths.
+			[]string{"asn.asn"},
+			"ip,asn_asn",
+			`8.8.8.8,"Google, LLC"`,
+		},
+		{
+			[]string{"asn.id", "asn.name", "privacy.vpn"},
+			"ip,asn_id,asn_name,privacy_vpn",
+			`8.8.8.8,AS15169,"Google, LLC",`,
+		},
+		{
+			[]string{"asn", "domains"},
+			"ip,asn_id,asn_name,asn_domain,asn_route,asn_type,domains_total",
+			`8.8.8.8,AS15169,"Google, LLC",,,,2`,
+		},
+		{
+			[]string{"domains.domains", "domains.domains[1]", "company as c"},
+			`ip,domains_domains,domains_domains_1,c_name,c_domain,c_type`,
+			`8.8.8.8,"a.com,b.com",b.com,,,`,
+		},
+	}
+	for _, tt := range tests {
+		hdrs, rowFuncs, err := prepareFieldsCore(tt.fields, true, true)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		row := make([]string, len(rowFuncs))
+		for i, rowFunc := range rowFuncs {
+			row[i] = rowFunc(d)
+		}
+		if got := strings.Join(hdrs, ","); got != tt.hdrs {
+			t.Errorf("%v: expected header %q, got %q", tt.fields, tt.hdrs, got)
+		}
+		if got := strings.Join(row, ","); got != tt.row {
+			t.Errorf("%v: expected row %q, got %q", tt.fields, tt.row, got)
+		}
+	}
+
+	_, _, err := prepareFieldsCore([]string{"asn.nope"}, true, true)
+	if err == nil || !strings.Contains(err.Error(), "\n  asn.route\n") {
+		t.Errorf("expected an error listing the valid fields, got %v", err)
+	}
+}
+
+// Array indexes and wildcards select elements of lists, and non-JSON fields
+// like the country name can be selected too.
+func TestPrepareFieldsASNDetails(t *testing.T) {
+	d := &ipinfo.ASNDetails{
+		ASN:         "AS15169",
+		Country:     "US",
+		CountryName: "United States",
+		Prefixes: []ipinfo.ASNDetailsPrefix{
+			{Netblock: "8.8.4.0/24"},
+			{Netblock: "8.8.8.0/24"},
+		},
+	}
+
+	hdrs, rowFuncs, err := prepareFieldsASNDetails(
+		[]string{"country_name", "prefixes[0].netblock", "prefixes[].netblock as blocks"},
+		true, true,
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	row := make([]string, len(rowFuncs))
+	for i, rowFunc := range rowFuncs {
+		row[i] = rowFunc(d)
+	}
+	if got, want := strings.Join(hdrs, ","), "id,country_name,prefixes_0_netblock,blocks"; got != want {
+		t.Errorf("expected header %q, got %q", want, got)
+	}
+	if got, want := strings.Join(row, ","), `AS15169,United States,8.8.4.0/24,"8.8.4.0/24,8.8.8.0/24"`; got != want {
+		t.Errorf("expected row %q, got %q", want, got)
+	}
+
+	objFunc, err := prepareFieldsJSONASNDetails([]string{"prefixes[].netblock as blocks", "country"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]interface{}{
+		"asn":     "AS15169",
+		"country": "US",
+		"blocks":  []interface{}{"8.8.4.0/24", "8.8.8.0/24"},
+	}
+	if got := objFunc(d); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
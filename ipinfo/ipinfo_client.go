@@ -2,14 +2,19 @@ package main
 
 import (
 	"fmt"
+	"net"
 	"os"
 	"runtime"
 
+	"github.com/ipinfo/cli/lib"
 	"github.com/ipinfo/go/v2/ipinfo"
 )
 
 var ii *ipinfo.Client
 
+// the cache of `ii`, if any, which is closed before exiting.
+var gCache *BoltdbCache
+
 func prepareIpinfoClient(tok string) *ipinfo.Client {
 	var _ii *ipinfo.Client
 
@@ -20,19 +25,54 @@ func prepareIpinfoClient(tok string) *ipinfo.Client {
 
 	var cache *ipinfo.Cache
 	if gConfig.CacheEnabled && !fNoCache {
-		boltdbCache, err := NewBoltdbCache()
+		boltdbCache, err := NewBoltdbCache(effectiveCachePolicy())
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "warn: cache will not be used: %v\n", err)
 		} else {
 			cache = ipinfo.NewCache(boltdbCache)
+			gCache = boltdbCache
 		}
 	}
 
 	// init client.
-	_ii = ipinfo.NewClient(nil, cache, tok)
+	httpClient := newRetryClient(fRetries, fMaxBackoff)
+	if rate := requestRate(); rate > 0 {
+		// limit every attempt, including retries.
+		rt := httpClient.Transport.(*retryTransport)
+		rt.base = newRateLimitTransport(rt.base, rate)
+	}
+	_ii = ipinfo.NewClient(httpClient, cache, tok)
 	_ii.UserAgent = fmt.Sprintf(
 		"IPinfoCli/%s (os/%s - arch/%s)",
 		version, runtime.GOOS, runtime.GOARCH,
 	)
 	return _ii
 }
+
+// ipLookuper looks up IP details, either from the API or a local mmdb file.
+type ipLookuper interface {
+	GetIPInfo(ip net.IP) (*ipinfo.Core, error)
+	GetIPInfoBatch(ips []net.IP, opts ipinfo.BatchReqOpts) (ipinfo.BatchCore, error)
+	GetBatch(urls []string, opts ipinfo.BatchReqOpts) (ipinfo.Batch, error)
+	GetIPSummary(ips []net.IP) (*ipinfo.IPSummary, error)
+}
+
+// prepareIPLookuper returns a lookuper answering from the mmdb file at `db`,
+// or the configured one if `db` is empty.
+//
+// If no mmdb file is set either way, the API client is prepared in `ii` and
+// returned instead, which must have a token if `requireToken` is set.
+func prepareIPLookuper(tok string, db string, requireToken bool) (ipLookuper, error) {
+	if db == "" {
+		db = gConfig.DB
+	}
+	if db != "" {
+		return openMmdbClient(db)
+	}
+
+	ii = prepareIpinfoClient(tok)
+	if requireToken && ii.Token == "" {
+		return nil, lib.AuthErrorf("bulk lookups require a token; login via `ipinfo init`.")
+	}
+	return ii, nil
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"strings"
+	"unicode"
+
+	"github.com/ipinfo/cli/lib"
+	"golang.org/x/text/width"
+)
+
+// the country and continent names of the locales are those of CLDR, as in
+// golang.org/x/text/language/display, for the countries of the ipinfo
+// package; the labels of the friendly output are translated by hand.
+//
+//go:embed locales/*.json
+var localesFS embed.FS
+
+// the languages of the friendly output.
+var localeLangs = []string{"en", "de", "es", "ja"}
+
+// the English labels of the friendly output, which every locale translates.
+// Acronyms like 'IP', 'ASN' and 'VPN' are the same in every language.
+var localeLabels = []string{
+	"Abuse", "Address", "Anycast", "Bogon", "Carrier", "City", "Company",
+	"Continent", "Core", "Count", "Country", "Currency", "Domain", "Domains",
+	"Email", "Examples", "Hosting", "Hostname", "Location", "Mobile", "Name",
+	"Network", "Organization", "Phone", "Postal", "Privacy", "Proxy",
+	"Region", "Relay", "Route", "Service", "Share", "Summary", "Timezone",
+	"Top ASNs", "Top Carriers", "Top Cities", "Top Countries", "Top Domains",
+	"Top Privacy Services", "Top Regions", "Top Routes", "Top Usage Types",
+	"Total", "Type", "Unique",
+}
+
+// isLocaleLang returns whether `lang` is a language of the friendly output.
+func isLocaleLang(lang string) bool {
+	for _, l := range localeLangs {
+		if l == lang {
+			return true
+		}
+	}
+	return false
+}
+
+// locale has the names and labels of the friendly output in a language.
+type locale struct {
+	// the names of countries and continents by their codes.
+	Countries  map[string]string `json:"countries"`
+	Continents map[string]string `json:"continents"`
+
+	// the labels of the friendly output by their English labels.
+	Labels map[string]string `json:"labels"`
+}
+
+// the locale of the friendly output, which is English if nil.
+var gLocale *locale
+
+// setLocale sets the language of the friendly output to `lang`, or to the
+// config's lang if it's empty. Anything missing in the language falls back
+// to English.
+func setLocale(lang string) error {
+	if lang == "" {
+		lang = gConfig.Lang
+	}
+	lang = strings.ToLower(lang)
+
+	gLocale = nil
+	if lang == "" || lang == "en" {
+		return nil
+	}
+	if !isLocaleLang(lang) {
+		return lib.UsageErrorf(
+			"invalid language %v; must be one of: %v",
+			lang, strings.Join(localeLangs, ", "),
+		)
+	}
+
+	data, err := localesFS.ReadFile("locales/" + lang + ".json")
+	if err != nil {
+		return err
+	}
+	var l locale
+	if err := json.Unmarshal(data, &l); err != nil {
+		return err
+	}
+	gLocale = &l
+	return nil
+}
+
+// tr returns the label `s` of the friendly output in the locale's language.
+func tr(s string) string {
+	if gLocale != nil {
+		if t, ok := gLocale.Labels[s]; ok {
+			return t
+		}
+	}
+	return s
+}
+
+// localeCountryName returns the name of the country with the `code` in the
+// locale's language, or `name` if it has none.
+func localeCountryName(code string, name string) string {
+	if gLocale != nil {
+		if t, ok := gLocale.Countries[strings.ToUpper(code)]; ok {
+			return t
+		}
+	}
+	return name
+}
+
+// localeContinentName returns the name of the continent with the `code` in
+// the locale's language, or `name` if it has none.
+func localeContinentName(code string, name string) string {
+	if gLocale != nil {
+		if t, ok := gLocale.Continents[strings.ToUpper(code)]; ok {
+			return t
+		}
+	}
+	return name
+}
+
+// displayWidth returns the number of columns `s` takes up on a terminal, on
+// which East Asian wide characters take up 2.
+func displayWidth(s string) int {
+	n := 0
+	for _, r := range s {
+		n += runeWidth(r)
+	}
+	return n
+}
+
+// runeWidth returns the number of columns `r` takes up on a terminal: 2 for
+// East Asian wide characters and emoji, and 0 for combining marks and
+// invisible ones like zero-width joiners and variation selectors.
+func runeWidth(r rune) int {
+	switch {
+	case unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf):
+		return 0
+	}
+	switch width.LookupRune(r).Kind() {
+	case width.EastAsianWide, width.EastAsianFullwidth:
+		return 2
+	}
+	return 1
+}
+
+// padLabel pads `s` with spaces to `w` columns.
+func padLabel(s string, w int) string {
+	if n := displayWidth(s); n < w {
+		return s + strings.Repeat(" ", w-n)
+	}
+	return s
+}
+
+// labelWidth returns the width of the widest of the `labels` in the locale's
+// language.
+func labelWidth(labels ...string) int {
+	w := 0
+	for _, l := range labels {
+		if n := displayWidth(tr(l)); n > w {
+			w = n
+		}
+	}
+	return w
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// Every locale has the names of all continents, and the labels it doesn't
+// have fall back to English.
+func TestSetLocale(t *testing.T) {
+	defer setLocale("en")
+
+	for _, lang := range localeLangs[1:] {
+		if err := setLocale(lang); err != nil {
+			t.Fatalf("%v: unexpected error: %v", lang, err)
+		}
+		for _, code := range []string{"AF", "AN", "AS", "EU", "NA", "OC", "SA"} {
+			if name := localeContinentName(code, code); name == code {
+				t.Errorf("%v: no name for continent %v", lang, code)
+			}
+		}
+		if name := localeCountryName("de", "Germany"); name == "Germany" {
+			t.Errorf("%v: no name for country DE", lang)
+		}
+		if l := tr("Not A Label"); l != "Not A Label" {
+			t.Errorf("%v: expected fallback to English, got %q", lang, l)
+		}
+	}
+
+	if err := setLocale("EN"); err != nil || gLocale != nil {
+		t.Errorf("expected English, got %v", err)
+	}
+	if err := setLocale("xx"); err == nil {
+		t.Errorf("expected an error for an unknown language")
+	}
+}
+
+// Every locale file has a label for each English label, and no others.
+func TestLocaleLabels(t *testing.T) {
+	want := append([]string(nil), localeLabels...)
+	sort.Strings(want)
+	for _, lang := range localeLangs[1:] {
+		data, err := localesFS.ReadFile("locales/" + lang + ".json")
+		if err != nil {
+			t.Fatalf("%v: %v", lang, err)
+		}
+		var l locale
+		if err := json.Unmarshal(data, &l); err != nil {
+			t.Fatalf("%v: %v", lang, err)
+		}
+		got := make([]string, 0, len(l.Labels))
+		for k := range l.Labels {
+			got = append(got, k)
+		}
+		sort.Strings(got)
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("%v: expected labels %v, got %v", lang, want, got)
+		}
+	}
+}
+
+// East Asian wide characters take up 2 columns.
+func TestDisplayWidth(t *testing.T) {
+	for s, want := range map[string]int{
+		"":        0,
+		"Country": 7,
+		"Währung": 7,
+		"国":       2,
+		"エニーキャスト": 14,
+		"上位のASN":  9,
+	} {
+		if got := displayWidth(s); got != want {
+			t.Errorf("displayWidth(%q) = %v, want %v", s, got, want)
+		}
+	}
+	if got := padLabel("国", 4); got != "国  " {
+		t.Errorf("padLabel: got %q", got)
+	}
+}
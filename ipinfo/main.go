@@ -1,12 +1,13 @@
 package main
 
 import (
-	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/fatih/color"
+	"github.com/ipinfo/cli/lib"
 	"github.com/ipinfo/cli/lib/iputil"
 )
 
@@ -18,6 +19,13 @@ var fHelp bool
 var fHelpDetailed bool
 var fNoCache bool
 var fNoColor bool
+var fLang string
+var fRetries = defaultRetries
+var fMaxBackoff = defaultMaxBackoff
+var fConcurrency int
+var fBatchSize int
+var fTimeout time.Duration
+var fRate float64
 
 func main() {
 	var err error
@@ -30,6 +38,10 @@ func main() {
 
 	handleCompletions()
 
+	if err := lib.InitErrorFormat(); err != nil {
+		lib.ExitWithError(err)
+	}
+
 	if len(os.Args) > 1 {
 		cmd = os.Args[1]
 	}
@@ -98,7 +110,12 @@ func main() {
 		err = cmdDefault()
 	}
 
+	// write the accesses the cache hasn't yet.
+	if gCache != nil {
+		gCache.Close()
+	}
+
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "err: %v\n", err)
+		lib.ExitWithError(err)
 	}
 }
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ipinfo/cli/lib"
+	"github.com/ipinfo/cli/lib/iputil"
+	"github.com/ipinfo/go/v2/ipinfo"
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// mmdbClient answers IP lookups from a local mmdb file instead of the API,
+// mapping its records into the same `ipinfo.Core` shape the API returns.
+//
+// Any of the IPinfo mmdb databases can be used, e.g. the free ones from
+// `ipinfo download`; fields that the database doesn't have are left empty.
+type mmdbClient struct {
+	db *maxminddb.Reader
+}
+
+// openMmdbClient opens the mmdb file at `path` for lookups.
+func openMmdbClient(path string) (*mmdbClient, error) {
+	db, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't open mmdb file %v: %w", path, err)
+	}
+
+	return &mmdbClient{db: db}, nil
+}
+
+// GetIPInfo returns the details for the specified IP.
+func (c *mmdbClient) GetIPInfo(ip net.IP) (*ipinfo.Core, error) {
+	if ip == nil {
+		return nil, lib.UsageErrorf("an IP is required for mmdb lookups")
+	}
+
+	if iputil.IsBogonIP(ip) {
+		return &ipinfo.Core{IP: ip, Bogon: true}, nil
+	}
+
+	record := make(map[string]interface{})
+	if err := c.db.Lookup(ip, &record); err != nil {
+		return nil, fmt.Errorf("couldn't get data for %v: %w", ip, err)
+	}
+
+	return coreFromMmdbRecord(ip, record), nil
+}
+
+// GetIPInfoBatch returns the details for all `ips`. An IP that can't be
+// looked up, e.g. an IPv6 one in an IPv4-only database, is warned about and
+// left out, rather than failing the others.
+func (c *mmdbClient) GetIPInfoBatch(
+	ips []net.IP,
+	opts ipinfo.BatchReqOpts,
+) (ipinfo.BatchCore, error) {
+	res := make(ipinfo.BatchCore, len(ips))
+	for _, ip := range ips {
+		if ip == nil {
+			continue
+		}
+		core, err := c.GetIPInfo(ip)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warn: %v\n", err)
+			continue
+		}
+		res[ip.String()] = core
+	}
+
+	return res, nil
+}
+
+// GetBatch returns the details for all IP `urls`; any other kind of URL is
+// not available in an mmdb file and is skipped. An IP that can't be looked up
+// results in a `*bulkFailure`, rather than failing the others.
+func (c *mmdbClient) GetBatch(
+	urls []string,
+	opts ipinfo.BatchReqOpts,
+) (ipinfo.Batch, error) {
+	res := make(ipinfo.Batch, len(urls))
+	for _, url := range urls {
+		ip := net.ParseIP(url)
+		if ip == nil {
+			continue
+		}
+		core, err := c.GetIPInfo(ip)
+		if err != nil {
+			res[url] = &bulkFailure{IP: url, Error: err.Error()}
+			continue
+		}
+		res[url] = core
+	}
+
+	return res, nil
+}
+
+// GetIPSummary summarizes `ips` the same way the summary API does, with only
+// the data available in the mmdb file.
+func (c *mmdbClient) GetIPSummary(ips []net.IP) (*ipinfo.IPSummary, error) {
+	sum := &ipinfo.IPSummary{
+		Countries:       map[string]uint64{},
+		Cities:          map[string]uint64{},
+		Regions:         map[string]uint64{},
+		ASNs:            map[string]uint64{},
+		Companies:       map[string]uint64{},
+		IPTypes:         map[string]uint64{},
+		Routes:          map[string]uint64{},
+		Carriers:        map[string]uint64{},
+		Domains:         map[string]uint64{},
+		PrivacyServices: map[string]uint64{},
+	}
+
+	unique := make(map[string]struct{}, len(ips))
+	for _, ip := range ips {
+		if ip == nil {
+			continue
+		}
+		sum.Total++
+		if _, ok := unique[ip.String()]; ok {
+			continue
+		}
+		unique[ip.String()] = struct{}{}
+
+		d, err := c.GetIPInfo(ip)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warn: %v\n", err)
+			continue
+		}
+		addToSummary(sum, d)
+	}
+	sum.Unique = uint64(len(unique))
+
+	return sum, nil
+}
+
+// Close closes the mmdb file.
+func (c *mmdbClient) Close() error {
+	return c.db.Close()
+}
+
+// adds a single unique IP's details to the summary counts.
+func addToSummary(sum *ipinfo.IPSummary, d *ipinfo.Core) {
+	if d.Bogon {
+		sum.Bogon++
+		return
+	}
+	if d.Anycast {
+		sum.Anycast++
+	}
+	if d.Country != "" {
+		sum.Countries[d.Country]++
+	}
+	if d.Region != "" {
+		sum.Regions[joinNonEmpty(", ", d.Region, d.Country)]++
+	}
+	if d.City != "" {
+		sum.Cities[joinNonEmpty(", ", d.City, d.Region, d.Country)]++
+	}
+	if d.ASN != nil && d.ASN.ASN != "" {
+		sum.ASNs[joinNonEmpty(" ", d.ASN.ASN, d.ASN.Name)]++
+		if d.ASN.Route != "" {
+			sum.Routes[d.ASN.ASN+" "+d.ASN.Route]++
+		}
+		if d.ASN.Type != "" {
+			sum.IPTypes[d.ASN.Type]++
+		}
+	}
+	if d.Company != nil && d.Company.Name != "" {
+		sum.Companies[d.Company.Name]++
+	}
+	if d.Carrier != nil && d.Carrier.Name != "" {
+		sum.Carriers[d.Carrier.Name]++
+		sum.Mobile++
+	}
+	if d.Privacy != nil {
+		if d.Privacy.VPN {
+			sum.Privacy.VPN++
+		}
+		if d.Privacy.Proxy {
+			sum.Privacy.Proxy++
+		}
+		if d.Privacy.Hosting {
+			sum.Privacy.Hosting++
+		}
+		if d.Privacy.Relay {
+			sum.Privacy.Relay++
+		}
+		if d.Privacy.Tor {
+			sum.Privacy.Tor++
+		}
+		if d.Privacy.Service != "" {
+			sum.PrivacyServices[d.Privacy.Service]++
+		}
+	}
+}
+
+// coreFromMmdbRecord maps an mmdb record onto `ipinfo.Core`.
+//
+// The record keys differ between the IPinfo databases, e.g. the free country
+// database has "country" as the country code while IPinfo Lite has it as the
+// country name with "country_code" as the code, so several keys are tried for
+// each field.
+func coreFromMmdbRecord(ip net.IP, r map[string]interface{}) *ipinfo.Core {
+	d := &ipinfo.Core{IP: ip}
+
+	d.Hostname = mmdbStr(r, "hostname")
+	d.City = mmdbStr(r, "city")
+	d.Region = mmdbStr(r, "region")
+	d.Postal = mmdbStr(r, "postal", "postal_code")
+	d.Timezone = mmdbStr(r, "timezone")
+	d.Anycast = mmdbBool(r, "anycast", "is_anycast")
+
+	d.Country = mmdbStr(r, "country_code")
+	if country := mmdbStr(r, "country"); len(country) == 2 {
+		d.Country = strings.ToUpper(country)
+	} else if d.CountryName == "" {
+		d.CountryName = country
+	}
+	if d.Country != "" {
+		setCountryDetails(d)
+	}
+
+	d.Location = mmdbStr(r, "loc")
+	if lat, lng := mmdbStr(r, "lat", "latitude"), mmdbStr(r, "lng", "longitude"); lat != "" && lng != "" {
+		d.Location = lat + "," + lng
+	}
+
+	if asn := mmdbStr(r, "asn"); asn != "" {
+		if !strings.HasPrefix(strings.ToUpper(asn), "AS") {
+			asn = "AS" + asn
+		}
+		d.ASN = &ipinfo.CoreASN{
+			ASN:    strings.ToUpper(asn),
+			Name:   mmdbStr(r, "as_name", "name"),
+			Domain: mmdbStr(r, "as_domain", "domain"),
+			Route:  mmdbStr(r, "route"),
+			Type:   mmdbStr(r, "as_type", "type"),
+		}
+	}
+	d.Org = mmdbStr(r, "org")
+	if d.Org == "" && d.ASN != nil {
+		d.Org = joinNonEmpty(" ", d.ASN.ASN, d.ASN.Name)
+	}
+
+	if name := mmdbStr(r, "company_name"); name != "" {
+		d.Company = &ipinfo.CoreCompany{
+			Name:   name,
+			Domain: mmdbStr(r, "company_domain"),
+			Type:   mmdbStr(r, "company_type"),
+		}
+	}
+
+	if name := mmdbStr(r, "carrier", "carrier_name"); name != "" {
+		d.Carrier = &ipinfo.CoreCarrier{
+			Name: name,
+			MCC:  mmdbStr(r, "mcc"),
+			MNC:  mmdbStr(r, "mnc"),
+		}
+	}
+
+	for _, k := range []string{"vpn", "proxy", "tor", "relay", "hosting"} {
+		if _, ok := r[k]; ok {
+			d.Privacy = &ipinfo.CorePrivacy{
+				VPN:     mmdbBool(r, "vpn"),
+				Proxy:   mmdbBool(r, "proxy"),
+				Tor:     mmdbBool(r, "tor"),
+				Relay:   mmdbBool(r, "relay"),
+				Hosting: mmdbBool(r, "hosting"),
+				Service: mmdbStr(r, "service"),
+			}
+			break
+		}
+	}
+
+	return d
+}
+
+// setCountryDetails fills in all details derived from the country code, the
+// same way the API client does for API responses.
+func setCountryDetails(d *ipinfo.Core) {
+	d.CountryName = ipinfo.GetCountryName(d.Country)
+	d.IsEU = ipinfo.IsEU(d.Country)
+	d.CountryFlag.Emoji = ipinfo.GetCountryFlagEmoji(d.Country)
+	d.CountryFlag.Unicode = ipinfo.GetCountryFlagUnicode(d.Country)
+	d.CountryFlagURL = ipinfo.GetCountryFlagURL(d.Country)
+	d.CountryCurrency.Code = ipinfo.GetCountryCurrencyCode(d.Country)
+	d.CountryCurrency.Symbol = ipinfo.GetCountryCurrencySymbol(d.Country)
+	d.Continent.Code = ipinfo.GetContinentCode(d.Country)
+	d.Continent.Name = ipinfo.GetContinentName(d.Country)
+}
+
+// returns the value of the first of `keys` in `r` that is non-empty, as a
+// string.
+func mmdbStr(r map[string]interface{}, keys ...string) string {
+	for _, k := range keys {
+		var s string
+		switch v := r[k].(type) {
+		case nil:
+			continue
+		case string:
+			s = v
+		case float64:
+			s = strconv.FormatFloat(v, 'f', -1, 64)
+		case float32:
+			s = strconv.FormatFloat(float64(v), 'f', -1, 32)
+		default:
+			s = fmt.Sprintf("%v", v)
+		}
+		if s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+// returns whether any of `keys` in `r` is true; databases store these either
+// as booleans or as strings.
+func mmdbBool(r map[string]interface{}, keys ...string) bool {
+	for _, k := range keys {
+		switch v := r[k].(type) {
+		case bool:
+			if v {
+				return true
+			}
+		case string:
+			if b, _ := strconv.ParseBool(v); b {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// joins all non-empty `strs` with `sep`.
+func joinNonEmpty(sep string, strs ...string) string {
+	nonEmpty := make([]string, 0, len(strs))
+	for _, s := range strs {
+		if s != "" {
+			nonEmpty = append(nonEmpty, s)
+		}
+	}
+	return strings.Join(nonEmpty, sep)
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ipinfo/go/v2/ipinfo"
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+)
+
+// writeTestMmdb writes an mmdb file with `record` for `cidr` and returns its
+// path.
+func writeTestMmdb(t *testing.T, cidr string, record mmdbtype.Map) string {
+	t.Helper()
+	return writeTestMmdbVersion(t, 6, cidr, record)
+}
+
+// writeTestMmdbVersion is writeTestMmdb for an IPv4 or IPv6 database.
+func writeTestMmdbVersion(
+	t *testing.T,
+	ipVersion int,
+	cidr string,
+	record mmdbtype.Map,
+) string {
+	t.Helper()
+
+	tree, err := mmdbwriter.New(mmdbwriter.Options{
+		DatabaseType: "ipinfo test",
+		IPVersion:    ipVersion,
+	})
+	if err != nil {
+		t.Fatalf("new tree: %v", err)
+	}
+	_, network, _ := net.ParseCIDR(cidr)
+	if err := tree.Insert(network, record); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "test.mmdb")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	defer f.Close()
+	if _, err := tree.WriteTo(f); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	return path
+}
+
+func TestMmdbClientGetIPInfo(t *testing.T) {
+	path := writeTestMmdb(t, "8.8.8.0/24", mmdbtype.Map{
+		"country": mmdbtype.String("US"),
+		"asn":     mmdbtype.String("AS15169"),
+		"as_name": mmdbtype.String("Google LLC"),
+	})
+	c, err := openMmdbClient(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer c.Close()
+
+	d, err := c.GetIPInfo(net.ParseIP("8.8.8.8"))
+	if err != nil {
+		t.Fatalf("lookup: %v", err)
+	}
+	if d.Country != "US" || d.CountryName != "United States" {
+		t.Errorf("unexpected country %q (%q)", d.Country, d.CountryName)
+	}
+	if d.ASN == nil || d.ASN.ASN != "AS15169" || d.Org != "AS15169 Google LLC" {
+		t.Errorf("unexpected ASN %+v, org %q", d.ASN, d.Org)
+	}
+
+	d, err = c.GetIPInfo(net.ParseIP("10.0.0.1"))
+	if err != nil {
+		t.Fatalf("lookup: %v", err)
+	}
+	if !d.Bogon {
+		t.Errorf("expected 10.0.0.1 to be a bogon")
+	}
+
+	sum, err := c.GetIPSummary([]net.IP{
+		net.ParseIP("8.8.8.8"),
+		net.ParseIP("8.8.8.8"),
+		net.ParseIP("8.8.8.4"),
+		net.ParseIP("10.0.0.1"),
+	})
+	if err != nil {
+		t.Fatalf("summary: %v", err)
+	}
+	if sum.Total != 4 || sum.Unique != 3 || sum.Bogon != 1 || sum.Countries["US"] != 2 {
+		t.Errorf("unexpected summary %+v", sum)
+	}
+}
+
+// An IP that can't be looked up, like an IPv6 one in an IPv4-only database,
+// doesn't fail the lookups of the others in a batch.
+func TestMmdbClientBatchMixedFamilies(t *testing.T) {
+	path := writeTestMmdbVersion(t, 4, "8.8.8.0/24", mmdbtype.Map{
+		"country": mmdbtype.String("US"),
+	})
+	c, err := openMmdbClient(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer c.Close()
+
+	v4, v6 := "8.8.8.8", "2001:4860:4860::8888"
+	res, err := c.GetBatch([]string{v6, v4}, ipinfo.BatchReqOpts{})
+	if err != nil {
+		t.Fatalf("batch: %v", err)
+	}
+	if d, ok := res[v4].(*ipinfo.Core); !ok || d.Country != "US" {
+		t.Errorf("unexpected result for %v: %+v", v4, res[v4])
+	}
+	if f, ok := res[v6].(*bulkFailure); !ok || f.IP != v6 || f.Error == "" {
+		t.Errorf("expected a failure for %v, got %+v", v6, res[v6])
+	}
+
+	var core ipinfo.BatchCore
+	_, stderr := captureStd(t, func() {
+		core, err = c.GetIPInfoBatch(
+			[]net.IP{net.ParseIP(v6), net.ParseIP(v4)},
+			ipinfo.BatchReqOpts{},
+		)
+	})
+	if err != nil {
+		t.Fatalf("batch: %v", err)
+	}
+	if len(core) != 1 || core[v4] == nil || core[v4].Country != "US" {
+		t.Errorf("unexpected results %+v", core)
+	}
+	if !strings.Contains(stderr, v6) {
+		t.Errorf("expected a warning about %v, got %q", v6, stderr)
+	}
+}
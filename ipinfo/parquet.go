@@ -0,0 +1,830 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+	"net"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ipinfo/cli/lib"
+	"github.com/ipinfo/go/v2/ipinfo"
+)
+
+// Parquet physical types, repetitions, converted types and encodings.
+const (
+	parquetBoolean   = 0
+	parquetInt64     = 2
+	parquetDouble    = 5
+	parquetByteArray = 6
+
+	parquetRequired = 0
+	parquetOptional = 1
+	parquetRepeated = 2
+
+	parquetNoConverted = -1
+	parquetUTF8        = 0
+	parquetList        = 3
+
+	parquetPlain = 0
+	parquetRLE   = 3
+)
+
+// the number of rows buffered in a row group before it's written.
+const parquetRowGroupSize = 64 * 1024
+
+// parquetNode is a field of a Parquet schema: either a column of values, or a
+// group of fields.
+type parquetNode struct {
+	name       string
+	repetition int32
+
+	// the physical type of columns.
+	typ int32
+
+	// the converted type, UTF8 for strings and LIST for lists.
+	converted int32
+
+	// the fields of groups.
+	children []*parquetNode
+
+	// the max definition and repetition levels of values of the field.
+	maxDef int
+	maxRep int
+
+	// the path of columns in the schema.
+	path []string
+
+	// the buffered levels and values of columns.
+	defs   []int
+	reps   []int
+	bools  []bool
+	values bytes.Buffer
+}
+
+func parquetColumn(name string, typ int32, converted int32) *parquetNode {
+	return &parquetNode{
+		name:       name,
+		repetition: parquetOptional,
+		typ:        typ,
+		converted:  converted,
+	}
+}
+
+func parquetGroup(name string, children ...*parquetNode) *parquetNode {
+	return &parquetNode{
+		name:       name,
+		repetition: parquetOptional,
+		converted:  parquetNoConverted,
+		children:   children,
+	}
+}
+
+// a list of `elem` values, in the standard 3-level structure.
+func parquetListOf(name string, elem *parquetNode) *parquetNode {
+	elem.name = "element"
+	l := parquetGroup(name, &parquetNode{
+		name:       "list",
+		repetition: parquetRepeated,
+		converted:  parquetNoConverted,
+		children:   []*parquetNode{elem},
+	})
+	l.converted = parquetList
+	return l
+}
+
+// parquetSchemaFromType returns the fields of the JSON of values of type `t`,
+// with strings and IPs as UTF8 strings, numbers as INT64 or DOUBLE, booleans
+// as BOOLEAN, objects as groups and lists as LIST groups.
+func parquetSchemaFromType(t reflect.Type) []*parquetNode {
+	t = derefType(t)
+	var nodes []*parquetNode
+	for i := 0; i < t.NumField(); i++ {
+		name, ok := jsonFieldName(t.Field(i))
+		if !ok {
+			continue
+		}
+		nodes = append(nodes, parquetNodeFromType(name, t.Field(i).Type))
+	}
+	return nodes
+}
+
+func parquetNodeFromType(name string, t reflect.Type) *parquetNode {
+	t = derefType(t)
+	switch {
+	case t == ipType:
+		return parquetColumn(name, parquetByteArray, parquetUTF8)
+	case t.Kind() == reflect.Struct:
+		return parquetGroup(name, parquetSchemaFromType(t)...)
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		return parquetListOf(name, parquetNodeFromType("element", t.Elem()))
+	case t.Kind() == reflect.Bool:
+		return parquetColumn(name, parquetBoolean, parquetNoConverted)
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		return parquetColumn(name, parquetInt64, parquetNoConverted)
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		return parquetColumn(name, parquetDouble, parquetNoConverted)
+	}
+	return parquetColumn(name, parquetByteArray, parquetUTF8)
+}
+
+// parquetSchemaFromValue returns the fields of `m`, a decoded record like
+// those of mmdb files, sorted by name and typed like their values.
+func parquetSchemaFromValue(m map[string]interface{}) []*parquetNode {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	nodes := make([]*parquetNode, 0, len(names))
+	for _, name := range names {
+		nodes = append(nodes, parquetNodeFromValue(name, m[name]))
+	}
+	return nodes
+}
+
+func parquetNodeFromValue(name string, v interface{}) *parquetNode {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return parquetGroup(name, parquetSchemaFromValue(v)...)
+	case []interface{}:
+		var elem interface{}
+		if len(v) > 0 {
+			elem = v[0]
+		}
+		return parquetListOf(name, parquetNodeFromValue("element", elem))
+	case bool:
+		return parquetColumn(name, parquetBoolean, parquetNoConverted)
+	case float32, float64:
+		return parquetColumn(name, parquetDouble, parquetNoConverted)
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return parquetColumn(name, parquetInt64, parquetNoConverted)
+	}
+	return parquetColumn(name, parquetByteArray, parquetUTF8)
+}
+
+// parquetWriter writes records as a Parquet file, in row groups of
+// uncompressed and PLAIN encoded columns.
+//
+// Records are decoded JSON objects, whose fields not in the schema are
+// ignored; booleans that are missing are false, like their JSON omits them.
+type parquetWriter struct {
+	w      *bufio.Writer
+	offset int64
+
+	root    *parquetNode
+	leaves  []*parquetNode
+	rows    int64
+	grpRows int64
+	groups  []parquetRowGroup
+}
+
+// the metadata of a written row group.
+type parquetRowGroup struct {
+	columns   []parquetColumnChunk
+	totalSize int64
+	rows      int64
+}
+
+// the metadata of a written column chunk.
+type parquetColumnChunk struct {
+	leaf       *parquetNode
+	offset     int64
+	numValues  int64
+	totalSize  int64
+	dataOffset int64
+}
+
+// newParquetWriter returns a writer of records with the `fields` to `w`.
+func newParquetWriter(w io.Writer, fields []*parquetNode) (*parquetWriter, error) {
+	pw := &parquetWriter{
+		w: bufio.NewWriter(w),
+		root: &parquetNode{
+			name:       "schema",
+			repetition: parquetRequired,
+			converted:  parquetNoConverted,
+			children:   fields,
+		},
+	}
+	pw.root.prepare(nil, 0, 0, &pw.leaves)
+
+	if err := pw.write([]byte("PAR1")); err != nil {
+		return nil, err
+	}
+	return pw, nil
+}
+
+// sets the levels and paths of `n` and its fields, collecting its columns.
+func (n *parquetNode) prepare(path []string, def int, rep int, leaves *[]*parquetNode) {
+	switch n.repetition {
+	case parquetOptional:
+		def++
+	case parquetRepeated:
+		def++
+		rep++
+	}
+	n.maxDef, n.maxRep = def, rep
+	if path != nil || n.repetition != parquetRequired {
+		n.path = append(append([]string{}, path...), n.name)
+	}
+
+	if n.children == nil {
+		*leaves = append(*leaves, n)
+		return
+	}
+	for _, c := range n.children {
+		c.prepare(n.path, def, rep, leaves)
+	}
+}
+
+func (pw *parquetWriter) write(b []byte) error {
+	n, err := pw.w.Write(b)
+	pw.offset += int64(n)
+	return err
+}
+
+// Write buffers the record `v`, which is either a decoded JSON object or any
+// value that's encoded into one.
+func (pw *parquetWriter) Write(v interface{}) error {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		var err error
+		m, err = parquetRecord(v)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, c := range pw.root.children {
+		c.shred(m[c.name], 0, 0)
+	}
+	pw.rows++
+	pw.grpRows++
+
+	if pw.grpRows >= parquetRowGroupSize {
+		return pw.flushRowGroup()
+	}
+	return nil
+}
+
+// returns `v` encoded to JSON and decoded back into a generic object.
+func parquetRecord(v interface{}) (map[string]interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var m map[string]interface{}
+	if err := dec.Decode(&m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// adds the value `v` of `n` to its columns, where `rep` is the repetition
+// level of the value and `def` the definition level of its parent.
+func (n *parquetNode) shred(v interface{}, rep int, def int) {
+	if n.children == nil {
+		val, ok := n.convert(v)
+		if !ok {
+			n.add(rep, def, nil)
+			return
+		}
+		n.add(rep, n.maxDef, val)
+		return
+	}
+
+	if n.converted == parquetList {
+		list, ok := v.([]interface{})
+		if !ok {
+			n.shredNull(rep, def)
+			return
+		}
+		if len(list) == 0 {
+			n.shredNull(rep, n.maxDef)
+			return
+		}
+		repeated := n.children[0]
+		for i, elem := range list {
+			r := rep
+			if i > 0 {
+				r = repeated.maxRep
+			}
+			repeated.children[0].shred(elem, r, repeated.maxDef)
+		}
+		return
+	}
+
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		n.shredNull(rep, def)
+		return
+	}
+	for _, c := range n.children {
+		c.shred(m[c.name], rep, n.maxDef)
+	}
+}
+
+// adds a null at definition level `def` to all columns of `n`.
+func (n *parquetNode) shredNull(rep int, def int) {
+	if n.children == nil {
+		n.add(rep, def, nil)
+		return
+	}
+	for _, c := range n.children {
+		c.shredNull(rep, def)
+	}
+}
+
+// converts `v` to the column's type, or returns false if it's null or can't
+// be converted.
+func (n *parquetNode) convert(v interface{}) (interface{}, bool) {
+	switch n.typ {
+	case parquetBoolean:
+		switch v := v.(type) {
+		case nil:
+			return false, true
+		case bool:
+			return v, true
+		}
+	case parquetInt64:
+		switch v := v.(type) {
+		case json.Number:
+			i, err := v.Int64()
+			if err == nil {
+				return i, true
+			}
+			f, err := v.Float64()
+			return int64(f), err == nil
+		case string:
+			i, err := strconv.ParseInt(v, 10, 64)
+			return i, err == nil
+		case float64:
+			return int64(v), true
+		case nil:
+		default:
+			rv := reflect.ValueOf(v)
+			switch {
+			case rv.CanInt():
+				return rv.Int(), true
+			case rv.CanUint():
+				return int64(rv.Uint()), true
+			}
+		}
+	case parquetDouble:
+		switch v := v.(type) {
+		case json.Number:
+			f, err := v.Float64()
+			return f, err == nil
+		case string:
+			f, err := strconv.ParseFloat(v, 64)
+			return f, err == nil
+		case nil:
+		default:
+			rv := reflect.ValueOf(v)
+			switch {
+			case rv.CanFloat():
+				return rv.Float(), true
+			case rv.CanInt():
+				return float64(rv.Int()), true
+			case rv.CanUint():
+				return float64(rv.Uint()), true
+			}
+		}
+	case parquetByteArray:
+		switch v := v.(type) {
+		case nil:
+		case string:
+			return v, true
+		case net.IP:
+			return v.String(), true
+		case map[string]interface{}, []interface{}:
+			b, err := json.Marshal(v)
+			return string(b), err == nil
+		default:
+			return fmt.Sprint(v), true
+		}
+	}
+	return nil, false
+}
+
+// adds a value, or null if `val` is nil, with its levels to the column.
+func (n *parquetNode) add(rep int, def int, val interface{}) {
+	n.reps = append(n.reps, rep)
+	n.defs = append(n.defs, def)
+
+	var b [8]byte
+	switch val := val.(type) {
+	case bool:
+		n.bools = append(n.bools, val)
+	case int64:
+		binary.LittleEndian.PutUint64(b[:], uint64(val))
+		n.values.Write(b[:])
+	case float64:
+		binary.LittleEndian.PutUint64(b[:], math.Float64bits(val))
+		n.values.Write(b[:])
+	case string:
+		binary.LittleEndian.PutUint32(b[:4], uint32(len(val)))
+		n.values.Write(b[:4])
+		n.values.WriteString(val)
+	}
+}
+
+// writes out the buffered rows as a row group, with a single data page per
+// column.
+func (pw *parquetWriter) flushRowGroup() error {
+	if pw.grpRows == 0 {
+		return nil
+	}
+
+	grp := parquetRowGroup{rows: pw.grpRows}
+	for _, leaf := range pw.leaves {
+		var page bytes.Buffer
+		if leaf.maxRep > 0 {
+			writeParquetLevels(&page, leaf.reps, leaf.maxRep)
+		}
+		if leaf.maxDef > 0 {
+			writeParquetLevels(&page, leaf.defs, leaf.maxDef)
+		}
+		if leaf.typ == parquetBoolean {
+			packed := make([]byte, (len(leaf.bools)+7)/8)
+			for i, v := range leaf.bools {
+				if v {
+					packed[i/8] |= 1 << (i % 8)
+				}
+			}
+			page.Write(packed)
+		} else {
+			page.Write(leaf.values.Bytes())
+		}
+
+		hdr := &thriftWriter{}
+		hdr.i32Field(1, 0) // DATA_PAGE
+		hdr.i32Field(2, int32(page.Len()))
+		hdr.i32Field(3, int32(page.Len()))
+		hdr.structField(5)
+		hdr.i32Field(1, int32(len(leaf.defs)))
+		hdr.i32Field(2, parquetPlain)
+		hdr.i32Field(3, parquetRLE)
+		hdr.i32Field(4, parquetRLE)
+		hdr.structEnd()
+		hdr.structEnd()
+
+		chunk := parquetColumnChunk{
+			leaf:       leaf,
+			offset:     pw.offset,
+			dataOffset: pw.offset,
+			numValues:  int64(len(leaf.defs)),
+			totalSize:  int64(hdr.buf.Len() + page.Len()),
+		}
+		if err := pw.write(hdr.buf.Bytes()); err != nil {
+			return err
+		}
+		if err := pw.write(page.Bytes()); err != nil {
+			return err
+		}
+		grp.columns = append(grp.columns, chunk)
+		grp.totalSize += chunk.totalSize
+
+		leaf.defs, leaf.reps, leaf.bools = leaf.defs[:0], leaf.reps[:0], leaf.bools[:0]
+		leaf.values.Reset()
+	}
+
+	pw.groups = append(pw.groups, grp)
+	pw.grpRows = 0
+	return nil
+}
+
+// writes `levels` RLE encoded and prefixed by their length.
+func writeParquetLevels(buf *bytes.Buffer, levels []int, max int) {
+	width := (bits.Len(uint(max)) + 7) / 8
+	var enc bytes.Buffer
+	var tmp [binary.MaxVarintLen64]byte
+	for i := 0; i < len(levels); {
+		j := i + 1
+		for j < len(levels) && levels[j] == levels[i] {
+			j++
+		}
+		n := binary.PutUvarint(tmp[:], uint64(j-i)<<1)
+		enc.Write(tmp[:n])
+		for b := 0; b < width; b++ {
+			enc.WriteByte(byte(levels[i] >> (8 * b)))
+		}
+		i = j
+	}
+
+	var l [4]byte
+	binary.LittleEndian.PutUint32(l[:], uint32(enc.Len()))
+	buf.Write(l[:])
+	buf.Write(enc.Bytes())
+}
+
+// Close writes out the buffered rows and the file's metadata, completing the
+// file.
+func (pw *parquetWriter) Close() error {
+	if err := pw.flushRowGroup(); err != nil {
+		return err
+	}
+
+	meta := &thriftWriter{}
+	meta.i32Field(1, 1)
+
+	// the schema, flattened depth-first.
+	var schema []*parquetNode
+	var flatten func(n *parquetNode)
+	flatten = func(n *parquetNode) {
+		schema = append(schema, n)
+		for _, c := range n.children {
+			flatten(c)
+		}
+	}
+	flatten(pw.root)
+	meta.listField(2, thriftStruct, len(schema))
+	for _, n := range schema {
+		meta.structBegin()
+		if n.children == nil {
+			meta.i32Field(1, n.typ)
+		}
+		if n != pw.root {
+			meta.i32Field(3, n.repetition)
+		}
+		meta.stringField(4, n.name)
+		if n.children != nil {
+			meta.i32Field(5, int32(len(n.children)))
+		}
+		if n.converted != parquetNoConverted {
+			meta.i32Field(6, n.converted)
+		}
+		meta.structEnd()
+	}
+
+	meta.i64Field(3, pw.rows)
+	meta.listField(4, thriftStruct, len(pw.groups))
+	for _, grp := range pw.groups {
+		meta.structBegin()
+		meta.listField(1, thriftStruct, len(grp.columns))
+		for _, c := range grp.columns {
+			meta.structBegin()
+			meta.i64Field(2, c.offset)
+			meta.structField(3)
+			meta.i32Field(1, c.leaf.typ)
+			meta.listField(2, thriftI32, 2)
+			meta.i32(parquetPlain)
+			meta.i32(parquetRLE)
+			meta.listField(3, thriftBinary, len(c.leaf.path))
+			for _, p := range c.leaf.path {
+				meta.string(p)
+			}
+			meta.i32Field(4, 0) // UNCOMPRESSED
+			meta.i64Field(5, c.numValues)
+			meta.i64Field(6, c.totalSize)
+			meta.i64Field(7, c.totalSize)
+			meta.i64Field(9, c.dataOffset)
+			meta.structEnd()
+			meta.structEnd()
+		}
+		meta.i64Field(2, grp.totalSize)
+		meta.i64Field(3, grp.rows)
+		meta.structEnd()
+	}
+	meta.stringField(6, "ipinfo cli "+version)
+	meta.structEnd()
+
+	var l [4]byte
+	binary.LittleEndian.PutUint32(l[:], uint32(meta.buf.Len()))
+	for _, b := range [][]byte{meta.buf.Bytes(), l[:], []byte("PAR1")} {
+		if err := pw.write(b); err != nil {
+			return err
+		}
+	}
+	return pw.w.Flush()
+}
+
+// Thrift compact protocol types.
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter encodes the Thrift compact protocol structs of Parquet
+// metadata.
+type thriftWriter struct {
+	buf    bytes.Buffer
+	lastID int16
+	ids    []int16
+}
+
+func (w *thriftWriter) fieldHeader(id int16, typ byte) {
+	if delta := id - w.lastID; delta > 0 && delta <= 15 {
+		w.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		w.buf.WriteByte(typ)
+		w.varint(int64(id))
+	}
+	w.lastID = id
+}
+
+func (w *thriftWriter) varint(v int64) {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutVarint(tmp[:], v)
+	w.buf.Write(tmp[:n])
+}
+
+func (w *thriftWriter) i32(v int32) {
+	w.varint(int64(v))
+}
+
+func (w *thriftWriter) string(s string) {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], uint64(len(s)))
+	w.buf.Write(tmp[:n])
+	w.buf.WriteString(s)
+}
+
+func (w *thriftWriter) i32Field(id int16, v int32) {
+	w.fieldHeader(id, thriftI32)
+	w.i32(v)
+}
+
+func (w *thriftWriter) i64Field(id int16, v int64) {
+	w.fieldHeader(id, thriftI64)
+	w.varint(v)
+}
+
+func (w *thriftWriter) stringField(id int16, s string) {
+	w.fieldHeader(id, thriftBinary)
+	w.string(s)
+}
+
+func (w *thriftWriter) listField(id int16, elemType byte, n int) {
+	w.fieldHeader(id, thriftList)
+	if n < 15 {
+		w.buf.WriteByte(byte(n)<<4 | elemType)
+		return
+	}
+	w.buf.WriteByte(0xf0 | elemType)
+	var tmp [binary.MaxVarintLen64]byte
+	l := binary.PutUvarint(tmp[:], uint64(n))
+	w.buf.Write(tmp[:l])
+}
+
+// structField begins a struct field, which is ended by `structEnd`.
+func (w *thriftWriter) structField(id int16) {
+	w.fieldHeader(id, thriftStruct)
+	w.structBegin()
+}
+
+// structBegin begins a struct, e.g. a list element.
+func (w *thriftWriter) structBegin() {
+	w.ids = append(w.ids, w.lastID)
+	w.lastID = 0
+}
+
+func (w *thriftWriter) structEnd() {
+	w.buf.WriteByte(0)
+	if len(w.ids) > 0 {
+		w.lastID = w.ids[len(w.ids)-1]
+		w.ids = w.ids[:len(w.ids)-1]
+	}
+}
+
+// parquetCoreSchema returns the fields of `ipinfo.Core` results, with 'loc'
+// as 'latitude' and 'longitude' doubles, and an extra host or error field.
+func parquetCoreSchema(host bool, errField bool) []*parquetNode {
+	var fields []*parquetNode
+	if host {
+		fields = append(fields, parquetColumn("host", parquetByteArray, parquetUTF8))
+	}
+	for _, n := range parquetSchemaFromType(reflect.TypeOf(ipinfo.Core{})) {
+		if n.name == "loc" {
+			fields = append(fields,
+				parquetColumn("latitude", parquetDouble, parquetNoConverted),
+				parquetColumn("longitude", parquetDouble, parquetNoConverted),
+			)
+			continue
+		}
+		fields = append(fields, n)
+	}
+	if errField {
+		fields = append(fields, parquetColumn("error", parquetByteArray, parquetUTF8))
+	}
+	return fields
+}
+
+// parquetCoreRecord returns the record of a `*ipinfo.Core`, `*hostCore` or
+// `*bulkFailure` result for the `parquetCoreSchema`.
+func parquetCoreRecord(v interface{}) (map[string]interface{}, error) {
+	m, err := parquetRecord(v)
+	if err != nil {
+		return nil, err
+	}
+	if loc, ok := m["loc"].(string); ok {
+		if lat, lon, ok := strings.Cut(loc, ","); ok {
+			m["latitude"], m["longitude"] = lat, lon
+		}
+	}
+	return m, nil
+}
+
+// parquetASNSchema returns the fields of `ipinfo.ASNDetails` results.
+func parquetASNSchema() []*parquetNode {
+	return parquetSchemaFromType(reflect.TypeOf(ipinfo.ASNDetails{}))
+}
+
+// parseOutputFormat validates the --output-format and --output flags, and
+// returns whether to output Parquet, which is also implied by an --output file
+// ending in '.parquet'.
+func parseOutputFormat(format string, out string) (bool, error) {
+	if format == "" && outputFileFormat(out) == "parquet" {
+		format = "parquet"
+	}
+	switch {
+	case format != "" && format != "parquet":
+		return false, lib.UsageErrorf("invalid output format %v; must be 'parquet'", format)
+	case format == "parquet" && out == "":
+		return false, lib.UsageErrorf("--output-format parquet requires an --output file")
+	case format == "parquet":
+		if err := checkParquetOutput(out); err != nil {
+			return false, err
+		}
+	}
+	return format == "parquet", nil
+}
+
+// checkParquetOutput returns a usage error if the Parquet output file `path`
+// ends in '.gz', as no Parquet reader could open it gzipped.
+func checkParquetOutput(path string) error {
+	if strings.HasSuffix(path, ".gz") {
+		return lib.UsageErrorf("parquet output can't be gzipped: %v", path)
+	}
+	return nil
+}
+
+// outputParquetFile writes the output file at `path` as Parquet with the
+// `fields`, passing the writer to `write` for the records. The file is only
+// replaced if writing succeeds.
+func outputParquetFile(
+	path string,
+	fields []*parquetNode,
+	write func(w *parquetWriter) error,
+) error {
+	f, err := createOutputFile(path, false)
+	if err != nil {
+		return err
+	}
+	if err := writeParquet(f, fields, write); err != nil {
+		f.Abort()
+		return err
+	}
+	return f.Commit()
+}
+
+// writeParquet writes Parquet with the `fields` to `w`, passing the writer to
+// `write` for the records.
+func writeParquet(
+	w io.Writer,
+	fields []*parquetNode,
+	write func(w *parquetWriter) error,
+) error {
+	pw, err := newParquetWriter(w, fields)
+	if err != nil {
+		return err
+	}
+	if err := write(pw); err != nil {
+		return err
+	}
+	return pw.Close()
+}
+
+// mergeParquetSample merges the fields of `src` which are missing or null in
+// `dst` into it, so that `dst` becomes a sample of all fields of all records,
+// whose schema is that of `parquetSchemaFromValue`.
+func mergeParquetSample(dst map[string]interface{}, src map[string]interface{}) {
+	for k, v := range src {
+		switch d := dst[k].(type) {
+		case nil:
+			dst[k] = v
+		case map[string]interface{}:
+			if m, ok := v.(map[string]interface{}); ok {
+				mergeParquetSample(d, m)
+			}
+		case []interface{}:
+			if l, ok := v.([]interface{}); ok && len(d) == 0 {
+				dst[k] = l
+			}
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ipinfo/go/v2/ipinfo"
+)
+
+// Records of results read back from the file with the schema and values they
+// were written with, with 'loc' split into 'latitude' and 'longitude'.
+func TestParquetWriter(t *testing.T) {
+	var buf bytes.Buffer
+	err := writeParquet(&buf, parquetCoreSchema(true, false), func(w *parquetWriter) error {
+		for _, v := range []interface{}{
+			&ipinfo.Core{
+				IP:       net.ParseIP("8.8.8.8"),
+				City:     "Mountain View",
+				Location: "37.4056,-122.0775",
+				Privacy:  &ipinfo.CorePrivacy{Hosting: true},
+			},
+			&hostCore{Host: "dns.google", Core: &ipinfo.Core{IP: net.ParseIP("8.8.4.4")}},
+		} {
+			record, err := parquetCoreRecord(v)
+			if err != nil {
+				return err
+			}
+			if err := w.Write(record); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	b := buf.Bytes()
+	if !bytes.HasPrefix(b, []byte("PAR1")) || !bytes.HasSuffix(b, []byte("PAR1")) {
+		t.Fatalf("expected magic bytes around the file")
+	}
+	footer := int(binary.LittleEndian.Uint32(b[len(b)-8:]))
+	if footer <= 0 || footer > len(b)-12 {
+		t.Fatalf("invalid footer length %d of %d bytes", footer, len(b))
+	}
+	meta := (&thriftReader{b: b[len(b)-8-footer : len(b)-8]}).structValue()
+	if rows := meta[3]; rows != int64(2) {
+		t.Errorf("expected 2 rows, got %v", rows)
+	}
+
+	// the type, converted type and max definition level of each column.
+	type column struct {
+		typ       int64
+		converted int64
+		maxDef    int
+	}
+	columns := make(map[string]column)
+	var walk func(elems []interface{}, path string, def int) []interface{}
+	walk = func(elems []interface{}, path string, def int) []interface{} {
+		e := elems[0].(map[int16]interface{})
+		elems = elems[1:]
+		name := path + e[4].(string)
+		if e[3] != int64(parquetRequired) {
+			def++
+		}
+		n, ok := e[5].(int64)
+		if !ok {
+			c := column{typ: e[1].(int64), converted: parquetNoConverted, maxDef: def}
+			if conv, ok := e[6].(int64); ok {
+				c.converted = conv
+			}
+			columns[name] = c
+			return elems
+		}
+		for i := int64(0); i < n; i++ {
+			elems = walk(elems, name+".", def)
+		}
+		return elems
+	}
+	schema := meta[2].([]interface{})
+	root := schema[0].(map[int16]interface{})
+	for elems, i := schema[1:], int64(0); i < root[5].(int64); i++ {
+		elems = walk(elems, "", 0)
+	}
+
+	for name, want := range map[string]column{
+		"host":            {parquetByteArray, parquetUTF8, 1},
+		"ip":              {parquetByteArray, parquetUTF8, 1},
+		"city":            {parquetByteArray, parquetUTF8, 1},
+		"latitude":        {parquetDouble, parquetNoConverted, 1},
+		"longitude":       {parquetDouble, parquetNoConverted, 1},
+		"privacy.hosting": {parquetBoolean, parquetNoConverted, 2},
+	} {
+		if got := columns[name]; got != want {
+			t.Errorf("%v: expected %+v, got %+v", name, want, got)
+		}
+	}
+	if _, ok := columns["loc"]; ok {
+		t.Errorf("expected 'loc' to be replaced by 'latitude' and 'longitude'")
+	}
+
+	// the values of each column, with nil for nulls.
+	values := make(map[string][]interface{})
+	groups := meta[4].([]interface{})
+	for _, c := range groups[0].(map[int16]interface{})[1].([]interface{}) {
+		md := c.(map[int16]interface{})[3].(map[int16]interface{})
+		var path []string
+		for _, p := range md[3].([]interface{}) {
+			path = append(path, p.(string))
+		}
+		name := strings.Join(path, ".")
+		values[name] = readParquetPage(t, b[md[9].(int64):], columns[name].typ, columns[name].maxDef)
+	}
+	for name, want := range map[string][]interface{}{
+		"host":            {nil, "dns.google"},
+		"ip":              {"8.8.8.8", "8.8.4.4"},
+		"city":            {"Mountain View", nil},
+		"latitude":        {37.4056, nil},
+		"longitude":       {-122.0775, nil},
+		"privacy.hosting": {true, nil},
+	} {
+		if got := values[name]; !reflect.DeepEqual(got, want) {
+			t.Errorf("%v: expected %v, got %v", name, want, got)
+		}
+	}
+}
+
+// readParquetPage reads the values of the data page at the start of `b` of a
+// column which isn't repeated, with nil for nulls.
+func readParquetPage(t *testing.T, b []byte, typ int64, maxDef int) []interface{} {
+	t.Helper()
+	r := &thriftReader{b: b}
+	hdr := r.structValue()
+	page := b[r.off : r.off+int(hdr[3].(int64))]
+
+	// the definition levels, RLE encoded in runs of one byte values.
+	l := int(binary.LittleEndian.Uint32(page))
+	var defs []int
+	for levels := page[4 : 4+l]; len(levels) > 0; {
+		n, k := binary.Uvarint(levels)
+		for i := uint64(0); i < n>>1; i++ {
+			defs = append(defs, int(levels[k]))
+		}
+		levels = levels[k+1:]
+	}
+	data := page[4+l:]
+
+	var vals []interface{}
+	var nbool int
+	for _, def := range defs {
+		if def < maxDef {
+			vals = append(vals, nil)
+			continue
+		}
+		switch typ {
+		case parquetBoolean:
+			vals = append(vals, data[nbool/8]&(1<<(nbool%8)) != 0)
+			nbool++
+		case parquetDouble:
+			vals = append(vals, math.Float64frombits(binary.LittleEndian.Uint64(data)))
+			data = data[8:]
+		case parquetInt64:
+			vals = append(vals, int64(binary.LittleEndian.Uint64(data)))
+			data = data[8:]
+		case parquetByteArray:
+			n := int(binary.LittleEndian.Uint32(data))
+			vals = append(vals, string(data[4:4+n]))
+			data = data[4+n:]
+		default:
+			t.Fatalf("unexpected column type %v", typ)
+		}
+	}
+	return vals
+}
+
+// thriftReader decodes the Thrift compact protocol structs written by
+// `thriftWriter`, into maps of field IDs to values.
+type thriftReader struct {
+	b   []byte
+	off int
+}
+
+func (r *thriftReader) byte() byte {
+	c := r.b[r.off]
+	r.off++
+	return c
+}
+
+func (r *thriftReader) varint() int64 {
+	v, n := binary.Varint(r.b[r.off:])
+	r.off += n
+	return v
+}
+
+func (r *thriftReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.b[r.off:])
+	r.off += n
+	return v
+}
+
+func (r *thriftReader) value(typ byte) interface{} {
+	switch typ {
+	case thriftI32, thriftI64:
+		return r.varint()
+	case thriftBinary:
+		n := int(r.uvarint())
+		s := string(r.b[r.off : r.off+n])
+		r.off += n
+		return s
+	case thriftList:
+		h := r.byte()
+		n := int(h >> 4)
+		if n == 15 {
+			n = int(r.uvarint())
+		}
+		l := make([]interface{}, n)
+		for i := range l {
+			l[i] = r.value(h & 0xf)
+		}
+		return l
+	case thriftStruct:
+		return r.structValue()
+	}
+	panic(fmt.Sprintf("unexpected thrift type %v", typ))
+}
+
+func (r *thriftReader) structValue() map[int16]interface{} {
+	m := make(map[int16]interface{})
+	var id int16
+	for {
+		h := r.byte()
+		if h == 0 {
+			return m
+		}
+		if delta := int16(h >> 4); delta != 0 {
+			id += delta
+		} else {
+			id = int16(r.varint())
+		}
+		m[id] = r.value(h & 0xf)
+	}
+}
+
+func TestWriteParquetLevels(t *testing.T) {
+	var buf bytes.Buffer
+	writeParquetLevels(&buf, []int{1, 1, 1, 0, 2}, 2)
+	want := []byte{6, 0, 0, 0, 3 << 1, 1, 1 << 1, 0, 1 << 1, 2}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("expected %v, got %v", want, buf.Bytes())
+	}
+}
+
+func TestParseOutputFormat(t *testing.T) {
+	tests := []struct {
+		format  string
+		out     string
+		parquet bool
+		err     bool
+	}{
+		{"", "", false, false},
+		{"parquet", "out.pq", true, false},
+		{"", "out.parquet", true, false},
+		{"parquet", "", false, true},
+		{"", "out.parquet.gz", false, true},
+		{"parquet", "out.pq.gz", false, true},
+		{"", "out.json", false, false},
+		{"arrow", "out.arrow", false, true},
+	}
+	for _, tt := range tests {
+		parquet, err := parseOutputFormat(tt.format, tt.out)
+		if parquet != tt.parquet || (err != nil) != tt.err {
+			t.Errorf("%q, %q: expected %v, %v, got %v, %v", tt.format, tt.out, tt.parquet, tt.err, parquet, err)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ipinfo/cli/lib"
+)
+
+// the default maximum of hosts resolved at once.
+const defaultResolveConcurrency = 20
+
+// the timeout of a single DNS query to a custom resolver.
+const resolverTimeout = 10 * time.Second
+
+// the addresses a host resolved to, or the error resolving it.
+type hostIPs struct {
+	host string
+	ips  []net.IP
+	err  error
+}
+
+// newResolver returns a resolver querying the DNS server at `addr`, or the
+// system's resolver if `addr` is empty.
+//
+// `addr` is either `<ip>[:<port>]` for plain DNS, `tls://<host>[:<port>]` for
+// DNS-over-TLS or an `https://` URL for DNS-over-HTTPS. `tlsConf` configures
+// the TLS connections of the latter two, and may be nil.
+func newResolver(addr string, tlsConf *tls.Config) (*net.Resolver, error) {
+	var dial func(ctx context.Context, network, address string) (net.Conn, error)
+	switch {
+	case addr == "":
+		return net.DefaultResolver, nil
+	case strings.HasPrefix(addr, "https://"):
+		c := &http.Client{
+			Transport: &http.Transport{TLSClientConfig: tlsConf},
+			Timeout:   resolverTimeout,
+		}
+		dial = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return &dohConn{ctx: ctx, client: c, url: addr}, nil
+		}
+	case strings.HasPrefix(addr, "tls://"):
+		hostport, err := resolverHostPort(strings.TrimPrefix(addr, "tls://"), "853")
+		if err != nil {
+			return nil, err
+		}
+		d := &tls.Dialer{
+			NetDialer: &net.Dialer{Timeout: resolverTimeout},
+			Config:    tlsConf,
+		}
+		dial = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return d.DialContext(ctx, "tcp", hostport)
+		}
+	default:
+		hostport, err := resolverHostPort(addr, "53")
+		if err != nil {
+			return nil, err
+		}
+		if host, _, _ := net.SplitHostPort(hostport); net.ParseIP(host) == nil {
+			return nil, lib.UsageErrorf("invalid resolver %v: expected <ip>[:<port>], tls://<host>[:<port>] or an https:// URL", addr)
+		}
+		d := &net.Dialer{Timeout: resolverTimeout}
+		dial = func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return d.DialContext(ctx, network, hostport)
+		}
+	}
+
+	return &net.Resolver{PreferGo: true, Dial: dial}, nil
+}
+
+// adds `port` to `addr` if it has none.
+func resolverHostPort(addr string, port string) (string, error) {
+	if addr == "" {
+		return "", lib.UsageErrorf("empty resolver address")
+	}
+	if _, _, err := net.SplitHostPort(addr); err == nil {
+		return addr, nil
+	}
+	return net.JoinHostPort(strings.Trim(addr, "[]"), port), nil
+}
+
+// dohConn is a connection to a DNS-over-HTTPS server, posting each
+// length-prefixed DNS query written to it and reading back the
+// length-prefixed response, as the Go resolver expects of a stream.
+type dohConn struct {
+	ctx    context.Context
+	client *http.Client
+	url    string
+	query  bytes.Buffer
+	resp   bytes.Buffer
+}
+
+func (c *dohConn) Write(b []byte) (int, error) {
+	c.query.Write(b)
+	// wait for the whole query.
+	q := c.query.Bytes()
+	if len(q) < 2 {
+		return len(b), nil
+	}
+	n := int(q[0])<<8 | int(q[1])
+	if len(q) < 2+n {
+		return len(b), nil
+	}
+	msg := q[2 : 2+n]
+
+	req, err := http.NewRequestWithContext(c.ctx, http.MethodPost, c.url, bytes.NewReader(msg))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+	res, err := c.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("DNS-over-HTTPS server responded with %v", res.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(res.Body, 0xffff+1))
+	if err != nil {
+		return 0, err
+	}
+	if len(body) > 0xffff {
+		return 0, errors.New("DNS-over-HTTPS response too large")
+	}
+
+	c.query.Reset()
+	c.resp.Write([]byte{byte(len(body) >> 8), byte(len(body))})
+	c.resp.Write(body)
+	return len(b), nil
+}
+
+func (c *dohConn) Read(b []byte) (int, error) {
+	return c.resp.Read(b)
+}
+
+func (c *dohConn) Close() error                       { return nil }
+func (c *dohConn) LocalAddr() net.Addr                { return dohAddr(c.url) }
+func (c *dohConn) RemoteAddr() net.Addr               { return dohAddr(c.url) }
+func (c *dohConn) SetDeadline(t time.Time) error      { return nil }
+func (c *dohConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *dohConn) SetWriteDeadline(t time.Time) error { return nil }
+
+// the address of a DNS-over-HTTPS server.
+type dohAddr string
+
+func (a dohAddr) Network() string { return "https" }
+func (a dohAddr) String() string  { return string(a) }
+
+// resolveHosts resolves all `hosts` to their IPv4 and IPv6 addresses with `r`,
+// with at most `concurrency` hosts being resolved at once.
+//
+// The results are in the same order as `hosts`; a host that couldn't be
+// resolved has its `err` set.
+func resolveHosts(r *net.Resolver, hosts []string, concurrency int) []hostIPs {
+	if concurrency <= 0 {
+		concurrency = defaultResolveConcurrency
+	}
+
+	res := make([]hostIPs, len(hosts))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, host := range hosts {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int, host string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			ips, err := r.LookupIP(context.Background(), "ip", host)
+			res[i] = hostIPs{host: host, ips: ips, err: err}
+		}(i, host)
+	}
+	wg.Wait()
+
+	return res
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// the A and AAAA records of the names the stub DNS server answers.
+var stubDNSRecords = map[string]map[uint16]net.IP{
+	"test.example.": {1: net.ParseIP("192.0.2.1"), 28: net.ParseIP("2001:db8::1")},
+	"v4.example.":   {1: net.ParseIP("192.0.2.2")},
+}
+
+// answers a DNS query for a name of `stubDNSRecords` with its A or AAAA
+// record, and any other name with NXDOMAIN.
+func stubDNSAnswer(q []byte) []byte {
+	if len(q) < 12 {
+		return nil
+	}
+
+	// skip the question's name to its type.
+	end := 12
+	var name []byte
+	for end < len(q) && q[end] != 0 {
+		name = append(name, q[end+1:end+1+int(q[end])]...)
+		name = append(name, '.')
+		end += 1 + int(q[end])
+	}
+	end += 5
+	if end > len(q) {
+		return nil
+	}
+	qtype := binary.BigEndian.Uint16(q[end-4:])
+
+	records, ok := stubDNSRecords[string(name)]
+	var rdata []byte
+	if ip := records[qtype]; ip != nil && qtype == 1 {
+		rdata = ip.To4()
+	} else if ip != nil {
+		rdata = ip.To16()
+	}
+
+	res := append([]byte{}, q[:end]...)
+	res[2], res[3] = 0x81, 0x80
+	if !ok {
+		res[3] |= 3
+	}
+	binary.BigEndian.PutUint16(res[4:], 1)
+	binary.BigEndian.PutUint16(res[6:], 0)
+	binary.BigEndian.PutUint16(res[8:], 0)
+	binary.BigEndian.PutUint16(res[10:], 0)
+	if rdata != nil {
+		binary.BigEndian.PutUint16(res[6:], 1)
+		res = append(res, 0xc0, 12)
+		res = binary.BigEndian.AppendUint16(res, qtype)
+		res = binary.BigEndian.AppendUint16(res, 1)
+		res = binary.BigEndian.AppendUint32(res, 60)
+		res = binary.BigEndian.AppendUint16(res, uint16(len(rdata)))
+		res = append(res, rdata...)
+	}
+	return res
+}
+
+// serves length-prefixed DNS queries on `c` until it's closed.
+func serveStubDNSStream(c net.Conn) {
+	defer c.Close()
+	for {
+		var n uint16
+		if err := binary.Read(c, binary.BigEndian, &n); err != nil {
+			return
+		}
+		q := make([]byte, n)
+		if _, err := io.ReadFull(c, q); err != nil {
+			return
+		}
+		res := stubDNSAnswer(q)
+		c.Write(binary.BigEndian.AppendUint16(nil, uint16(len(res))))
+		c.Write(res)
+	}
+}
+
+// listenStubDNS serves plain DNS queries until the end of the test and
+// returns its address.
+func listenStubDNS(t *testing.T) string {
+	t.Helper()
+
+	udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen udp: %v", err)
+	}
+	t.Cleanup(func() { udp.Close() })
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := udp.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			udp.WriteTo(stubDNSAnswer(buf[:n]), addr)
+		}
+	}()
+	return udp.LocalAddr().String()
+}
+
+// Hosts are resolved with plain DNS, DNS-over-TLS and DNS-over-HTTPS.
+func TestNewResolver(t *testing.T) {
+	udpAddr := listenStubDNS(t)
+
+	doh := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/dns-message")
+		w.Write(stubDNSAnswer(q))
+	}))
+	defer doh.Close()
+	tlsConf := doh.Client().Transport.(*http.Transport).TLSClientConfig
+
+	dot, err := tls.Listen("tcp", "127.0.0.1:0", doh.TLS)
+	if err != nil {
+		t.Fatalf("listen tls: %v", err)
+	}
+	defer dot.Close()
+	go func() {
+		for {
+			c, err := dot.Accept()
+			if err != nil {
+				return
+			}
+			go serveStubDNSStream(c)
+		}
+	}()
+
+	for _, addr := range []string{
+		udpAddr,
+		"tls://" + dot.Addr().String(),
+		doh.URL + "/dns-query",
+	} {
+		r, err := newResolver(addr, tlsConf)
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %v", addr, err)
+		}
+
+		res := resolveHosts(r, []string{"test.example", "missing.example"}, 2)
+		if res[0].err != nil {
+			t.Fatalf("%v: unexpected error: %v", addr, res[0].err)
+		}
+		got := make(map[string]bool)
+		for _, ip := range res[0].ips {
+			got[ip.String()] = true
+		}
+		if len(got) != 2 || !got["192.0.2.1"] || !got["2001:db8::1"] {
+			t.Errorf("%v: unexpected addresses %v", addr, res[0].ips)
+		}
+		if res[1].err == nil {
+			t.Errorf("%v: expected an error for a missing host", addr)
+		}
+	}
+
+	if _, err := newResolver("dns.example", nil); err == nil {
+		t.Errorf("expected an error for a resolver that isn't an IP")
+	}
+}
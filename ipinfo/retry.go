@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// the defaults for the retry flags.
+const (
+	defaultRetries    = 3
+	defaultMaxBackoff = 30 * time.Second
+)
+
+// the delay before the first retry; it doubles after each attempt.
+const baseBackoff = 500 * time.Millisecond
+
+// retryTransport retries API requests which failed due to a network error, a
+// 5xx response or a 429 rate limit.
+//
+// Between attempts it waits for as long as the response's `Retry-After` says,
+// or otherwise for an exponentially increasing, jittered delay; either is at
+// most `maxBackoff`, and a `Retry-After` asking for longer is not retried.
+//
+// Batch lookups send each batch as its own request, so only the failed
+// batches are ever retried.
+type retryTransport struct {
+	base       http.RoundTripper
+	retries    int
+	maxBackoff time.Duration
+}
+
+// newRetryClient returns an HTTP client which retries failed requests up to
+// `retries` times.
+func newRetryClient(retries int, maxBackoff time.Duration) *http.Client {
+	return &http.Client{
+		Transport: &retryTransport{
+			base:       http.DefaultTransport,
+			retries:    retries,
+			maxBackoff: maxBackoff,
+		},
+	}
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		attemptReq := req
+		if attempt > 0 && req.Body != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			attemptReq = req.Clone(req.Context())
+			attemptReq.Body = body
+		}
+
+		resp, err := t.base.RoundTrip(attemptReq)
+		if attempt >= t.retries || !isRetryable(req, resp, err) {
+			return resp, err
+		}
+		wait, ok := t.backoff(attempt, resp)
+		if !ok {
+			return resp, err
+		}
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// reports whether the attempt that got `resp` or `err` can be retried.
+func isRetryable(req *http.Request, resp *http.Response, err error) bool {
+	// the body can't be resent.
+	if req.Body != nil && req.GetBody == nil {
+		return false
+	}
+
+	if err != nil {
+		return !errors.Is(err, context.Canceled) &&
+			!errors.Is(err, context.DeadlineExceeded)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// returns how long to wait before retrying `attempt`, and false if `resp`
+// asks to wait longer than the max backoff.
+func (t *retryTransport) backoff(
+	attempt int,
+	resp *http.Response,
+) (time.Duration, bool) {
+	if resp != nil {
+		if wait, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			return wait, wait <= t.maxBackoff
+		}
+	}
+
+	wait := t.maxBackoff
+	if attempt < 32 && baseBackoff<<attempt < wait {
+		wait = baseBackoff << attempt
+	}
+
+	// wait somewhere between half and the full delay, so that many requests
+	// failing at once don't all retry at once too.
+	half := wait / 2
+	return half + time.Duration(rand.Int63n(int64(wait-half)+1)), true
+}
+
+// parses a `Retry-After` header, which is either in seconds or a date.
+func parseRetryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			secs = 0
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	if date, err := http.ParseTime(v); err == nil {
+		wait := time.Until(date)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+	return 0, false
+}
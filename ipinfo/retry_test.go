@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ipinfo/go/v2/ipinfo"
+)
+
+// Only failed batches are retried, and they succeed once the API recovers.
+func TestRetryFailedBatchesOnly(t *testing.T) {
+	var mu sync.Mutex
+	sent := make(map[string]int)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var keys []string
+		if err := json.NewDecoder(r.Body).Decode(&keys); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		mu.Lock()
+		sent[keys[0]]++
+		n := sent[keys[0]]
+		mu.Unlock()
+
+		switch {
+		case keys[0] == "1.0.0.10" && n == 1:
+			w.Header().Set("Retry-After", "0")
+			http.Error(w, "slow down", http.StatusTooManyRequests)
+			return
+		case keys[0] == "1.0.0.20" && n < 3:
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+
+		res := make(map[string]interface{}, len(keys))
+		for _, k := range keys {
+			res[k] = map[string]string{"ip": k}
+		}
+		json.NewEncoder(w).Encode(res)
+	}))
+	defer srv.Close()
+
+	c := ipinfo.NewClient(newRetryClient(3, 10*time.Millisecond), nil, "dummy-token")
+	c.BaseURL, _ = url.Parse(srv.URL + "/")
+
+	var keys []string
+	ipSrc(25)(func(k string) error {
+		keys = append(keys, k)
+		return nil
+	})
+	res, err := c.GetBatch(keys, ipinfo.BatchReqOpts{
+		BatchSize:                    10,
+		ConcurrentBatchRequestsLimit: 3,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(res) != 25 {
+		t.Errorf("expected 25 results, got %d", len(res))
+	}
+
+	want := map[string]int{"1.0.0.0": 1, "1.0.0.10": 2, "1.0.0.20": 3}
+	for k, n := range want {
+		if sent[k] != n {
+			t.Errorf("batch %s: expected %d requests, got %d", k, n, sent[k])
+		}
+	}
+}
+
+// A request still failing after all retries returns the last response, and one
+// asking to wait longer than the max backoff isn't retried at all.
+func TestRetryGivesUp(t *testing.T) {
+	tests := []struct {
+		retryAfter string
+		want       int
+	}{
+		{"", 3},
+		{"3600", 1},
+	}
+	for _, tt := range tests {
+		sent := 0
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sent++
+			if tt.retryAfter != "" {
+				w.Header().Set("Retry-After", tt.retryAfter)
+			}
+			http.Error(w, "slow down", http.StatusTooManyRequests)
+		}))
+
+		resp, err := newRetryClient(2, 10*time.Millisecond).Get(srv.URL)
+		srv.Close()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusTooManyRequests {
+			t.Errorf("expected the last 429 response, got %d", resp.StatusCode)
+		}
+		if sent != tt.want {
+			t.Errorf("Retry-After %q: expected %d requests, got %d", tt.retryAfter, tt.want, sent)
+		}
+	}
+}
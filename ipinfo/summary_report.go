@@ -0,0 +1,246 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"os"
+	"strings"
+
+	"github.com/ipinfo/go/v2/ipinfo"
+)
+
+// sumReportRow is a row of a summary report, with its share of all IPs.
+type sumReportRow struct {
+	Name  string
+	Count uint64
+	Pct   float64
+}
+
+// sumReportSection is a section of a summary report, like "Top ASNs".
+type sumReportSection struct {
+	Title string
+	Rows  []sumReportRow
+}
+
+// sumReport is a summary in the sections of the pretty output, for rendering
+// as a report.
+type sumReport struct {
+	// the overall counts, like that of VPNs.
+	Overview []sumReportRow
+
+	// the top ASNs, countries and so on.
+	Sections []sumReportSection
+
+	// whether to draw a bar chart of the shares.
+	Chart bool
+}
+
+// sumUsageTypeName returns the name of the usage type `k` in the output.
+func sumUsageTypeName(k string) string {
+	if k == "isp" {
+		return "ISP"
+	}
+	return strings.Title(k)
+}
+
+// sumRouteName returns the name of the route `k`, which is an ASN and a
+// route, in the output.
+func sumRouteName(k string) string {
+	routeParts := strings.SplitN(k, " ", 2)
+	if len(routeParts) != 2 {
+		return k
+	}
+	return fmt.Sprintf("%s (%s)", routeParts[1], routeParts[0])
+}
+
+// newSumReport returns the report of the summary `d`.
+func newSumReport(d *ipinfo.IPSummary, chart bool) *sumReport {
+	row := func(name string, v uint64) sumReportRow {
+		var pct float64
+		if d.Total > 0 {
+			pct = (float64(v) / float64(d.Total)) * 100
+		}
+		return sumReportRow{Name: name, Count: v, Pct: pct}
+	}
+
+	r := &sumReport{Chart: chart}
+	for _, p := range []struct {
+		name string
+		v    uint64
+	}{
+		{"Total", d.Total},
+		{"Unique", d.Unique},
+		{"Anycast", d.Anycast},
+		{"Bogon", d.Bogon},
+		{"Mobile", d.Mobile},
+		{"VPN", d.Privacy.VPN},
+		{"Proxy", d.Privacy.Proxy},
+		{"Hosting", d.Privacy.Hosting},
+		{"Tor", d.Privacy.Tor},
+		{"Relay", d.Privacy.Relay},
+	} {
+		r.Overview = append(r.Overview, row(tr(p.name), p.v))
+	}
+
+	section := func(
+		title string,
+		m map[string]uint64,
+		name func(string) string,
+	) {
+		s := sumReportSection{Title: tr(title)}
+		for _, p := range orderSummaryMapping(m) {
+			s.Rows = append(s.Rows, row(name(p.k), p.v))
+		}
+		r.Sections = append(r.Sections, s)
+	}
+	asIs := func(k string) string { return k }
+	countryName := func(k string) string {
+		return localeCountryName(k, ipinfo.GetCountryName(k))
+	}
+
+	section("Top ASNs", d.ASNs, asIs)
+	section("Top Usage Types", d.IPTypes, sumUsageTypeName)
+	section("Top Routes", d.Routes, sumRouteName)
+	section("Top Countries", d.Countries, countryName)
+	section("Top Cities", d.Cities, asIs)
+	section("Top Regions", d.Regions, asIs)
+	if len(d.Carriers) > 0 {
+		section("Top Carriers", d.Carriers, asIs)
+	}
+	if len(d.PrivacyServices) > 0 {
+		section("Top Privacy Services", d.PrivacyServices, asIs)
+	}
+	if len(d.Domains) > 0 && d.Domains["total"] > 0 {
+		// the domains have their total among them.
+		domains := make(map[string]uint64, len(d.Domains))
+		for k, v := range d.Domains {
+			if k != "total" {
+				domains[k] = v
+			}
+		}
+		section("Top Domains", domains, asIs)
+	}
+	return r
+}
+
+// the width of the bar chart of a 100% share in markdown, in characters.
+const sumMarkdownBarLen = 20
+
+// sumMarkdownBar returns a bar of the share `pct` drawn with block elements,
+// in eighths of a character.
+func sumMarkdownBar(pct float64) string {
+	eighths := int(pct/100*sumMarkdownBarLen*8 + 0.5)
+	bar := strings.Repeat("█", eighths/8)
+	if rem := eighths % 8; rem > 0 {
+		bar += string([]rune("▏▎▍▌▋▊▉")[rem-1])
+	}
+	return bar
+}
+
+// sumMarkdownCell escapes `s` for a markdown table cell.
+func sumMarkdownCell(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return strings.ReplaceAll(s, "|", `\|`)
+}
+
+// outputSummaryMarkdown outputs the summary `d` as markdown, with a table per
+// section and, if `chart` is set, a bar chart of the shares in them.
+func outputSummaryMarkdown(d *ipinfo.IPSummary, chart bool) error {
+	r := newSumReport(d, chart)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", tr("Summary"))
+	fmt.Fprintf(&b, "| | %s | %s |\n", tr("Count"), tr("Share"))
+	b.WriteString("| --- | ---: | ---: |\n")
+	for _, row := range r.Overview {
+		fmt.Fprintf(
+			&b, "| %s | %d | %.1f%% |\n",
+			sumMarkdownCell(row.Name), row.Count, row.Pct,
+		)
+	}
+
+	for _, s := range r.Sections {
+		fmt.Fprintf(&b, "\n## %s\n\n", s.Title)
+		if len(s.Rows) == 0 {
+			b.WriteString("-\n")
+			continue
+		}
+
+		fmt.Fprintf(&b, "| %s | %s | %s |", tr("Name"), tr("Count"), tr("Share"))
+		if chart {
+			b.WriteString(" |")
+		}
+		b.WriteString("\n| --- | ---: | ---: |")
+		if chart {
+			b.WriteString(" --- |")
+		}
+		b.WriteString("\n")
+		for _, row := range s.Rows {
+			fmt.Fprintf(
+				&b, "| %s | %d | %.1f%% |",
+				sumMarkdownCell(row.Name), row.Count, row.Pct,
+			)
+			if chart {
+				fmt.Fprintf(&b, " `%s` |", sumMarkdownBar(row.Pct))
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	_, err := os.Stdout.WriteString(b.String())
+	return err
+}
+
+// the HTML summary report, a standalone page with inline styles so it can be
+// attached to an email as is.
+var sumHTMLTmpl = template.Must(template.New("summary").Funcs(template.FuncMap{
+	"tr": tr,
+	"pct": func(pct float64) string {
+		return fmt.Sprintf("%.1f", pct)
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{tr "Summary"}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; color: #1f2328; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; min-width: 24em; }
+th, td { border: 1px solid #d0d7de; padding: 4px 10px; text-align: left; }
+th { background: #f6f8fa; }
+td.num { text-align: right; font-variant-numeric: tabular-nums; }
+td.bar { width: 12em; }
+td.bar div { background: #0969da; height: 0.9em; }
+</style>
+</head>
+<body>
+<h1>{{tr "Summary"}}</h1>
+<table>
+<tr><th></th><th>{{tr "Count"}}</th><th>{{tr "Share"}}</th></tr>
+{{- range .Overview}}
+<tr><th>{{.Name}}</th><td class="num">{{.Count}}</td><td class="num">{{pct .Pct}}%</td></tr>
+{{- end}}
+</table>
+{{- range .Sections}}
+<h2>{{.Title}}</h2>
+{{- if .Rows}}
+<table>
+<tr><th>{{tr "Name"}}</th><th>{{tr "Count"}}</th><th>{{tr "Share"}}</th>{{if $.Chart}}<th></th>{{end}}</tr>
+{{- range .Rows}}
+<tr><td>{{.Name}}</td><td class="num">{{.Count}}</td><td class="num">{{pct .Pct}}%</td>
+{{- if $.Chart}}<td class="bar"><div style="width: {{pct .Pct}}%"></div></td>{{end}}</tr>
+{{- end}}
+</table>
+{{- else}}
+<p>-</p>
+{{- end}}
+{{- end}}
+</body>
+</html>
+`))
+
+// outputSummaryHTML outputs the summary `d` as an HTML page, with a table per
+// section and, if `chart` is set, a bar chart of the shares in them.
+func outputSummaryHTML(d *ipinfo.IPSummary, chart bool) error {
+	return sumHTMLTmpl.Execute(os.Stdout, newSumReport(d, chart))
+}
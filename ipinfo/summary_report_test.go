@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/ipinfo/go/v2/ipinfo"
+)
+
+// The report has the sections of the pretty output, with the shares of all
+// IPs, and not the domains' total among them.
+func TestSumReport(t *testing.T) {
+	d := &ipinfo.IPSummary{
+		Total:     4,
+		Unique:    4,
+		Countries: map[string]uint64{"US": 3, "DE": 1},
+		IPTypes:   map[string]uint64{"isp": 4},
+		Routes:    map[string]uint64{"AS15169 8.8.8.0/24": 2},
+		Domains:   map[string]uint64{"total": 9, "<b>x</b>.com": 1},
+	}
+	r := newSumReport(d, true)
+
+	titles := []string{}
+	for _, s := range r.Sections {
+		titles = append(titles, s.Title)
+	}
+	if got := strings.Join(titles, ","); got != "Top ASNs,Top Usage Types,Top Routes,Top Countries,Top Cities,Top Regions,Top Domains" {
+		t.Fatalf("unexpected sections %v", got)
+	}
+	if row := r.Sections[3].Rows[0]; row.Name != "United States" || row.Count != 3 || row.Pct != 75 {
+		t.Errorf("unexpected top country %+v", row)
+	}
+	if row := r.Sections[1].Rows[0]; row.Name != "ISP" {
+		t.Errorf("unexpected usage type %+v", row)
+	}
+	if row := r.Sections[2].Rows[0]; row.Name != "8.8.8.0/24 (AS15169)" {
+		t.Errorf("unexpected route %+v", row)
+	}
+	if rows := r.Sections[6].Rows; len(rows) != 1 {
+		t.Errorf("expected the domains' total to be left out, got %+v", rows)
+	}
+
+	var b bytes.Buffer
+	if err := sumHTMLTmpl.Execute(&b, r); err != nil {
+		t.Fatal(err)
+	}
+	if html := b.String(); strings.Contains(html, "<b>x</b>") ||
+		!strings.Contains(html, `style="width: 75.0%"`) {
+		t.Errorf("unexpected HTML:\n%v", html)
+	}
+}
+
+func TestSumMarkdownBar(t *testing.T) {
+	for pct, want := range map[float64]string{
+		0:    "",
+		100:  strings.Repeat("█", sumMarkdownBarLen),
+		50:   strings.Repeat("█", sumMarkdownBarLen/2),
+		2.5:  "▌",
+		33.3: "██████▋",
+	} {
+		if got := sumMarkdownBar(pct); got != want {
+			t.Errorf("sumMarkdownBar(%v) = %q, want %q", pct, got, want)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/ipinfo/cli/lib"
+	"golang.org/x/term"
+)
+
+// the fields output in a table when none are selected.
+var defaultTableFieldsCore = []string{
+	"ip",
+	"hostname",
+	"city",
+	"region",
+	"country",
+	"org",
+}
+var defaultTableFieldsASN = []string{
+	"asn",
+	"name",
+	"country",
+	"registry",
+	"allocated",
+	"num_ips",
+}
+
+// the columns whose long values are truncated to fit a table.
+var tableTruncColumns = map[string]bool{
+	"hostname":      true,
+	"org":           true,
+	"name":          true,
+	"as_name":       true,
+	"asn_name":      true,
+	"company_name":  true,
+	"abuse_address": true,
+	"abuse_name":    true,
+}
+
+const (
+	// the max width of columns which are truncated.
+	tableTruncMaxWidth = 40
+
+	// the min width columns which are truncated shrink to, to fit the table
+	// into the terminal.
+	tableTruncMinWidth = 12
+
+	// the separator between columns.
+	tableColSep = "  "
+)
+
+// resultsTable buffers rows of results to output them as a table with aligned
+// columns.
+type resultsTable struct {
+	hdrs   []string
+	header bool
+	rows   [][]string
+}
+
+// Append buffers a row.
+func (t *resultsTable) Append(row []string) {
+	t.rows = append(t.rows, row)
+}
+
+// Render returns the table fit into `width` columns by truncating long values
+// where possible, or without fitting it if `width` is 0.
+func (t *resultsTable) Render(width int) string {
+	// the width of each column is that of its widest cell, as displayed.
+	widths := make([]int, len(t.hdrs))
+	if t.header {
+		for i, hdr := range t.hdrs {
+			widths[i] = displayWidth(hdr)
+		}
+	}
+	for _, row := range t.rows {
+		for i, cell := range row {
+			if n := displayWidth(tableCell(cell)); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+
+	// truncate long values, shrinking the widest truncated column until the
+	// table fits.
+	for i, hdr := range t.hdrs {
+		if tableTruncColumns[hdr] && widths[i] > tableTruncMaxWidth {
+			widths[i] = tableTruncMaxWidth
+		}
+	}
+	if width > 0 {
+		total := len(tableColSep) * (len(widths) - 1)
+		for _, w := range widths {
+			total += w
+		}
+		for total > width {
+			widest := -1
+			for i, hdr := range t.hdrs {
+				if tableTruncColumns[hdr] && widths[i] > tableTruncMinWidth &&
+					(widest == -1 || widths[i] > widths[widest]) {
+					widest = i
+				}
+			}
+			if widest == -1 {
+				break
+			}
+			widths[widest]--
+			total--
+		}
+	}
+
+	var b strings.Builder
+	writeRow := func(row []string, c *color.Color) {
+		for i, cell := range row {
+			cell = tableCell(cell)
+			if displayWidth(cell) > widths[i] {
+				cell = truncateDisplay(cell, widths[i]-1) + "…"
+			}
+			if i < len(row)-1 {
+				cell = padLabel(cell, widths[i]) + tableColSep
+			}
+			if c != nil {
+				cell = c.Sprint(cell)
+			}
+			b.WriteString(cell)
+		}
+		b.WriteString("\n")
+	}
+	if t.header {
+		writeRow(t.hdrs, color.New(color.Bold))
+	}
+	for _, row := range t.rows {
+		writeRow(row, nil)
+	}
+
+	return b.String()
+}
+
+// Output writes the table to `w`, or when stdout is a terminal, fits it to
+// the terminal's width and pages it through $PAGER if it's taller than the
+// terminal.
+func (t *resultsTable) Output(w io.Writer) error {
+	fd := int(os.Stdout.Fd())
+	if !term.IsTerminal(fd) {
+		_, err := io.WriteString(w, t.Render(0))
+		return err
+	}
+
+	width, height, err := term.GetSize(fd)
+	if err != nil {
+		width, height = 0, 0
+	}
+	s := t.Render(width)
+	if height == 0 || strings.Count(s, "\n") < height {
+		_, err := io.WriteString(w, s)
+		return err
+	}
+
+	// let the default pager show colors and not wrap lines.
+	if os.Getenv("LESS") == "" {
+		os.Setenv("LESS", "FRSX")
+	}
+	return lib.HelpDetailed(s, func() {
+		fmt.Print(s)
+	})
+}
+
+// returns `cell` as it's shown on a single line of a table.
+func tableCell(cell string) string {
+	return strings.NewReplacer("\r\n", " ", "\n", " ", "\t", " ").Replace(cell)
+}
+
+// returns the longest prefix of `s` that's displayed in at most `w` columns.
+func truncateDisplay(s string, w int) string {
+	n := 0
+	for i, r := range s {
+		if n += runeWidth(r); n > w {
+			return s[:i]
+		}
+	}
+	return s
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/fatih/color"
+)
+
+// Columns are aligned, and long values of truncatable columns are cut to fit
+// the table into the width.
+func TestResultsTableRender(t *testing.T) {
+	noColor := color.NoColor
+	color.NoColor = true
+	defer func() { color.NoColor = noColor }()
+
+	tbl := &resultsTable{hdrs: []string{"ip", "org", "country"}, header: true}
+	tbl.Append([]string{"8.8.8.8", "AS15169 Google LLC", "US"})
+	tbl.Append([]string{"1.1.1.1", "AS13335 Cloudflare, Inc.", "AU"})
+
+	want := "" +
+		"ip       org                       country\n" +
+		"8.8.8.8  AS15169 Google LLC        US\n" +
+		"1.1.1.1  AS13335 Cloudflare, Inc.  AU\n"
+	if got := tbl.Render(0); got != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, got)
+	}
+
+	got := tbl.Render(34)
+	for _, line := range strings.Split(strings.TrimSuffix(got, "\n"), "\n") {
+		if n := len([]rune(line)); n > 34 {
+			t.Errorf("expected lines of at most 34 columns, got %d: %q", n, line)
+		}
+	}
+	if !strings.Contains(got, "AS13335 Cloud") || !strings.Contains(got, "…") {
+		t.Errorf("expected org to be truncated, got\n%s", got)
+	}
+
+	// untruncatable columns are never cut, even if the table doesn't fit.
+	if got := tbl.Render(10); !strings.Contains(got, "8.8.8.8") || !strings.Contains(got, "country") {
+		t.Errorf("expected only org to be truncated, got\n%s", got)
+	}
+}
+
+// Wide characters, like CJK and emoji, take up two columns, and invisible
+// ones none, so that columns stay aligned and truncated cells fit.
+func TestResultsTableRenderWide(t *testing.T) {
+	noColor := color.NoColor
+	color.NoColor = true
+	defer func() { color.NoColor = noColor }()
+
+	tbl := &resultsTable{hdrs: []string{"city", "org", "ip"}, header: true}
+	tbl.Append([]string{"東京", "AS2516 KDDI株式会社 東日本ネットワーク", "1.1.1.1"})
+	tbl.Append([]string{"Paris 🇫🇷", "AS3215 Orange", "2.2.2.2"})
+	tbl.Append([]string{"❤️", "AS1 x", "3.3.3.3"})
+
+	for _, w := range []int{0, 32} {
+		lines := strings.Split(strings.TrimSuffix(tbl.Render(w), "\n"), "\n")
+		col := strings.Index(lines[0], "ip")
+		for _, line := range lines[1:] {
+			ip := strings.LastIndex(line, " ") + 1
+			if got := displayWidth(line[:ip]); got != displayWidth(lines[0][:col]) {
+				t.Errorf("width %d: expected ip at column %d, got %d: %q", w, displayWidth(lines[0][:col]), got, line)
+			}
+			if w > 0 && displayWidth(line) > w {
+				t.Errorf("expected lines of at most %d columns, got %d: %q", w, displayWidth(line), line)
+			}
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"text/template"
+
+	"github.com/ipinfo/cli/lib"
+	"github.com/ipinfo/go/v2/ipinfo"
+)
+
+// the functions available to output templates besides the builtin ones.
+var outputTemplateFuncs = template.FuncMap{
+	"countryName": ipinfo.GetCountryName,
+	"flag":        ipinfo.GetCountryFlagEmoji,
+	"join":        templateJoin,
+	"json":        templateJSON,
+}
+
+// prepareOutputTemplate returns the template given by --template, or read
+// from the file at `path` given by --template-file, or nil if neither is.
+//
+// Each result is output on its own line, so a newline is appended to the
+// template unless it already ends with one.
+func prepareOutputTemplate(text string, path string) (*template.Template, error) {
+	if text != "" && path != "" {
+		return nil, lib.UsageErrorf("--template and --template-file can't both be used")
+	}
+	if path != "" {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't read template file: %w", err)
+		}
+		text = string(b)
+	}
+	if text == "" {
+		return nil, nil
+	}
+
+	if !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	tmpl, err := template.New("output").Funcs(outputTemplateFuncs).Parse(text)
+	if err != nil {
+		return nil, lib.UsageErrorf("invalid template: %v", err)
+	}
+	return tmpl, nil
+}
+
+// outputTemplate outputs `d` rendered through `tmpl`.
+func outputTemplate(tmpl *template.Template, d interface{}) error {
+	return tmpl.Execute(os.Stdout, d)
+}
+
+// joins the elements of the list `v` with `sep`, like `{{join ", " .List}}`
+// or `{{.List | join ", "}}`.
+func templateJoin(sep string, v interface{}) (string, error) {
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() {
+		return "", nil
+	}
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return "", fmt.Errorf("join of a non-list %T", v)
+	}
+	strs := make([]string, 0, rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		strs = append(strs, fmt.Sprint(rv.Index(i).Interface()))
+	}
+	return strings.Join(strs, sep), nil
+}
+
+// returns `v` as compact JSON.
+func templateJSON(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ipinfo/cli/lib"
+	"github.com/ipinfo/go/v2/ipinfo"
+)
+
+// Templates render each result on its own line, with the helper functions
+// available, and can be read from a file.
+func TestOutputTemplate(t *testing.T) {
+	d := &ipinfo.Core{
+		IP:      net.ParseIP("8.8.8.8"),
+		Country: "US",
+		Domains: &ipinfo.CoreDomains{Domains: []string{"a.com", "b.com"}},
+	}
+
+	path := filepath.Join(t.TempDir(), "tmpl")
+	if err := os.WriteFile(path, []byte("{{.IP}}\t{{.Country | countryName}}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		text string
+		path string
+		want string
+	}{
+		{"{{.IP}} {{.Country}}", "", "8.8.8.8 US\n"},
+		{"{{flag .Country}} {{.Domains.Domains | join \"|\"}}", "", "🇺🇸 a.com|b.com\n"},
+		{"{{json .Domains}}", "", `{"ip":"","total":0,"domains":["a.com","b.com"]}` + "\n"},
+		{"", path, "8.8.8.8\tUnited States\n"},
+	}
+	for _, tt := range tests {
+		tmpl, err := prepareOutputTemplate(tt.text, tt.path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		stdout, _ := captureStd(t, func() {
+			err = outputTemplate(tmpl, d)
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if stdout != tt.want {
+			t.Errorf("expected %q, got %q", tt.want, stdout)
+		}
+	}
+
+	_, err := prepareOutputTemplate("{{.IP", "")
+	if err == nil {
+		t.Errorf("expected an error for an invalid template")
+	} else if code := lib.ErrExitCode(err); code != lib.ExitUsage {
+		t.Errorf("expected exit code %v for an invalid template, got %v", lib.ExitUsage, code)
+	}
+	if tmpl, err := prepareOutputTemplate("", ""); tmpl != nil || err != nil {
+		t.Errorf("expected no template, got %v, %v", tmpl, err)
+	}
+}
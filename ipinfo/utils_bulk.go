@@ -0,0 +1,999 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/ipinfo/cli/lib"
+	"github.com/ipinfo/cli/lib/iputil"
+	"github.com/ipinfo/go/v2/ipinfo"
+	"gopkg.in/yaml.v3"
+)
+
+// the maximum amount of keys the batch API accepts in a single request.
+const batchMaxSize = 1000
+
+// returned by a stream's input source when the stream was stopped early due
+// to an output error.
+var errStreamStopped = errors.New("stream stopped")
+
+// a single chunk of keys being looked up in a stream.
+type batchChunk struct {
+	keys []string
+	res  ipinfo.Batch
+	err  error
+	done chan struct{}
+}
+
+// streamBatch reads keys lazily from `src`, looks them up in chunks of
+// `opts.BatchSize` with at most `opts.ConcurrentBatchRequestsLimit` chunks in
+// flight, and passes each chunk's results to `emit` in input order as soon as
+// the chunk and all chunks before it have completed.
+//
+// Only the chunks in flight are ever held in memory.
+func streamBatch(
+	src func(yield func(string) error) error,
+	lookup func([]string, ipinfo.BatchReqOpts) (ipinfo.Batch, error),
+	opts ipinfo.BatchReqOpts,
+	emit func(keys []string, res ipinfo.Batch) error,
+) error {
+	batchSize := int(opts.BatchSize)
+	if batchSize <= 0 || batchSize > batchMaxSize {
+		batchSize = batchMaxSize
+	}
+	concurrency := opts.ConcurrentBatchRequestsLimit
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	// every chunk is exactly one request, so don't let it be split further.
+	chunkOpts := opts
+	chunkOpts.BatchSize = uint32(batchSize)
+	chunkOpts.ConcurrentBatchRequestsLimit = 1
+
+	// `sem` bounds the chunks in flight, including ones waiting to be emitted
+	// behind a slower chunk earlier in the input.
+	sem := make(chan struct{}, concurrency)
+	queue := make(chan *batchChunk, concurrency)
+	stop := make(chan struct{})
+	errc := make(chan error, 1)
+
+	// emit chunks in order; after the first error, just drain the queue.
+	go func() {
+		var err error
+		for c := range queue {
+			<-c.done
+			if err == nil {
+				if c.err != nil {
+					err = c.err
+				} else {
+					err = emit(c.keys, c.res)
+				}
+				if err != nil {
+					close(stop)
+				}
+			}
+			<-sem
+		}
+		errc <- err
+	}()
+
+	dispatch := func(keys []string) error {
+		select {
+		case <-stop:
+			return errStreamStopped
+		default:
+		}
+		select {
+		case sem <- struct{}{}:
+		case <-stop:
+			return errStreamStopped
+		}
+
+		c := &batchChunk{keys: keys, done: make(chan struct{})}
+		go func() {
+			c.res, c.err = lookup(c.keys, chunkOpts)
+			close(c.done)
+		}()
+		queue <- c
+		return nil
+	}
+
+	chunk := make([]string, 0, batchSize)
+	srcErr := src(func(k string) error {
+		chunk = append(chunk, k)
+		if len(chunk) < batchSize {
+			return nil
+		}
+		err := dispatch(chunk)
+		chunk = make([]string, 0, batchSize)
+		return err
+	})
+	if srcErr == nil && len(chunk) > 0 {
+		srcErr = dispatch(chunk)
+	}
+	close(queue)
+
+	if err := <-errc; err != nil {
+		return err
+	}
+	return srcErr
+}
+
+// streamBulk looks up all keys from `src` with `lookup` in a stream, writing
+// each result to `w` as soon as its batch completes.
+//
+// If `cp` is non-nil, keys already recorded in it are skipped, and keys are
+// recorded in it once their results are written.
+//
+// Keys repeated within the same batch are only output once.
+func streamBulk(
+	src func(yield func(string) error) error,
+	lookup func([]string, ipinfo.BatchReqOpts) (ipinfo.Batch, error),
+	opts ipinfo.BatchReqOpts,
+	w *bulkStreamWriter,
+	cp *bulkCheckpoint,
+) error {
+	if cp != nil {
+		origSrc := src
+		src = func(yield func(string) error) error {
+			return origSrc(func(k string) error {
+				if cp.Done(k) {
+					return nil
+				}
+				return yield(k)
+			})
+		}
+	}
+
+	emit := func(keys []string, res ipinfo.Batch) error {
+		written := make([]string, 0, len(keys))
+		seen := make(map[string]struct{}, len(keys))
+		for _, k := range keys {
+			if _, ok := seen[k]; ok {
+				continue
+			}
+			seen[k] = struct{}{}
+
+			v, ok := res[k]
+			if !ok {
+				continue
+			}
+			if err := w.Write(v); err != nil {
+				return err
+			}
+
+			// failed keys must be looked up again when resuming.
+			if _, failed := v.(*bulkFailure); !failed {
+				written = append(written, k)
+			}
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+
+		if cp != nil {
+			return cp.Record(written)
+		}
+		return nil
+	}
+
+	return streamBatch(src, lookup, opts, emit)
+}
+
+// streamBulkCore is streamBulk for IPs from `src`.
+func streamBulkCore(
+	src func(yield func(net.IP) error) error,
+	lookup func([]string, ipinfo.BatchReqOpts) (ipinfo.Batch, error),
+	opts ipinfo.BatchReqOpts,
+	w *bulkStreamWriter,
+	cp *bulkCheckpoint,
+) error {
+	keySrc := func(yield func(string) error) error {
+		return src(func(ip net.IP) error {
+			if ip == nil {
+				return nil
+			}
+			return yield(ip.String())
+		})
+	}
+
+	return streamBulk(keySrc, lookup, opts, w, cp)
+}
+
+// bulkFailure is an input whose batch failed to be looked up, or a host that
+// couldn't be resolved, which is reported in place of its results with
+// --keep-going.
+type bulkFailure struct {
+	Host  string `json:"host,omitempty" yaml:"host,omitempty"`
+	IP    string `json:"ip,omitempty" yaml:"ip,omitempty"`
+	Error string `json:"error" yaml:"error"`
+}
+
+// hostCore is the result of an IP that `Host` resolved to.
+type hostCore struct {
+	Host         string `json:"host" yaml:"host"`
+	*ipinfo.Core `yaml:",inline"`
+}
+
+// keepGoingLookup wraps `lookup` so that a failed batch results in a
+// `*bulkFailure` for each of its keys, rather than in an error which stops
+// the whole lookup.
+func keepGoingLookup(
+	lookup func([]string, ipinfo.BatchReqOpts) (ipinfo.Batch, error),
+) func([]string, ipinfo.BatchReqOpts) (ipinfo.Batch, error) {
+	return func(keys []string, opts ipinfo.BatchReqOpts) (ipinfo.Batch, error) {
+		res, err := lookup(keys, opts)
+		if err == nil {
+			return res, nil
+		}
+
+		res = make(ipinfo.Batch, len(keys))
+		for _, k := range keys {
+			res[k] = &bulkFailure{IP: k, Error: err.Error()}
+		}
+		return res, nil
+	}
+}
+
+// lookupBulk looks up all `ips` with `lookup` like `GetIPInfoBatch`, into a
+// generic batch which may hold the `*bulkFailure`s of a `keepGoingLookup`.
+func lookupBulk(
+	ips []net.IP,
+	lookup func([]string, ipinfo.BatchReqOpts) (ipinfo.Batch, error),
+	opts ipinfo.BatchReqOpts,
+) (ipinfo.Batch, error) {
+	src := func(yield func(string) error) error {
+		for _, ip := range ips {
+			if ip == nil {
+				continue
+			}
+			if err := yield(ip.String()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	data := make(ipinfo.Batch, len(ips))
+	err := streamBatch(src, lookup, opts, func(keys []string, res ipinfo.Batch) error {
+		for k, v := range res {
+			data[k] = v
+		}
+		return nil
+	})
+	return data, err
+}
+
+// reports how many lookups failed when keeping going, as an error if
+// `failOnError` is set or as a warning otherwise.
+func reportBulkFailures(failed int, failOnError bool) error {
+	if failed == 0 {
+		return nil
+	}
+	if failOnError {
+		return fmt.Errorf("%d lookups failed", failed)
+	}
+	fmt.Fprintf(os.Stderr, "warn: %d lookups failed\n", failed)
+	return nil
+}
+
+// splits a `*ipinfo.Core`, `*hostCore` or `*bulkFailure` result into its
+// host, details and error for tabular output.
+func bulkRowParts(v interface{}) (string, *ipinfo.Core, string) {
+	switch v := v.(type) {
+	case *hostCore:
+		return v.Host, v.Core, ""
+	case *bulkFailure:
+		return v.Host, &ipinfo.Core{IP: net.ParseIP(v.IP)}, v.Error
+	case *ipinfo.Core:
+		return "", v, ""
+	}
+	return "", &ipinfo.Core{}, ""
+}
+
+// bulkStreamWriter writes bulk results one at a time, in a format that can be
+// appended to without knowing about the rest of the results.
+//
+// Failures are written like results, or to `errs` instead if it's set.
+type bulkStreamWriter struct {
+	buf    *bufio.Writer
+	write  func(interface{}) error
+	flush  func() error
+	errs   *bulkErrorsWriter
+	failed int
+}
+
+// bulkWriterOpts are the output options of a bulk stream writer.
+type bulkWriterOpts struct {
+	// the fields to select, if any.
+	fields []string
+
+	// the output format; JSON, or NDJSON when streaming, if none are set.
+	csvFmt  bool
+	yamlFmt bool
+	ndjson  bool
+	tmpl    *template.Template
+	table   bool
+
+	// the CSV columns, all of them if none, and the delimiter between them,
+	// a comma if 0.
+	columns   []string
+	delimiter rune
+
+	// whether to write a header for formats that have one.
+	header bool
+
+	// whether the field selection and CSV get an extra error column for
+	// failures output with the results.
+	errCol bool
+
+	// whether the field selection and CSV get an extra host column for the
+	// host results were resolved from.
+	hostCol bool
+}
+
+// newCoreStreamWriter returns a writer to stdout of `*ipinfo.Core` and
+// `*hostCore` results for either the template, NDJSON of the `fields` if
+// `ndjson` is set, the `fields` selection, CSV, YAML or otherwise NDJSON
+// output.
+//
+// Failures are written to stderr with the template, as it can't render them.
+func newCoreStreamWriter(o bulkWriterOpts) (*bulkStreamWriter, error) {
+	fields, csvFmt, yamlFmt, ndjson := o.fields, o.csvFmt, o.yamlFmt, o.ndjson
+	header, errCol, hostCol := o.header, o.errCol, o.hostCol
+
+	w := newBulkStreamWriter()
+
+	switch {
+	case o.tmpl != nil:
+		w.write = func(v interface{}) error {
+			if f, ok := v.(*bulkFailure); ok {
+				key := f.IP
+				if key == "" {
+					key = f.Host
+				}
+				fmt.Fprintf(os.Stderr, "err: %v: %v\n", key, f.Error)
+				return nil
+			}
+			return o.tmpl.Execute(w.buf, v)
+		}
+	case ndjson && len(fields) > 0:
+		objFunc, err := prepareFieldsJSONCore(fields)
+		if err != nil {
+			return nil, err
+		}
+		enc := json.NewEncoder(w.buf)
+		w.write = func(v interface{}) error {
+			var d *ipinfo.Core
+			var host string
+			switch v := v.(type) {
+			case *ipinfo.Core:
+				d = v
+			case *hostCore:
+				d, host = v.Core, v.Host
+			default:
+				return enc.Encode(v)
+			}
+
+			m := objFunc(d)
+			if host != "" {
+				m["host"] = host
+			}
+			return enc.Encode(m)
+		}
+	case ndjson:
+		w.write = json.NewEncoder(w.buf).Encode
+	case len(fields) > 0 || o.table:
+		if len(fields) == 0 {
+			fields = defaultTableFieldsCore
+		}
+		hdrs, cellFuncs, err := prepareColumnsCore(fields, true)
+		if err != nil {
+			return nil, err
+		}
+		if hostCol {
+			hdrs = append([]string{"host"}, hdrs...)
+		}
+		if errCol {
+			hdrs = append(hdrs, "error")
+		}
+		enc := func(cell string) string {
+			if o.table {
+				return cell
+			}
+			return encodeToCsvLine(cell)
+		}
+		row := func(v interface{}) []string {
+			host, d, errStr := bulkRowParts(v)
+			row := make([]string, 0, len(hdrs))
+			if hostCol {
+				row = append(row, enc(host))
+			}
+			for _, cellFunc := range cellFuncs {
+				row = append(row, enc(cellFunc(d)))
+			}
+			if errCol {
+				row = append(row, enc(errStr))
+			}
+			return row
+		}
+		if o.table {
+			w.write = w.tableWriter(hdrs, header, row)
+		} else {
+			w.write = w.fieldsWriter(hdrs, header, row)
+		}
+	case csvFmt:
+		hdrs, cellFuncs, err := prepareCSVColumnsCore(o.columns)
+		if err != nil {
+			return nil, err
+		}
+		if hostCol {
+			hdrs = append([]string{"host"}, hdrs...)
+		}
+		if errCol {
+			hdrs = append(hdrs, "error")
+		}
+
+		csvWriter := csv.NewWriter(w.buf)
+		if o.delimiter != 0 {
+			csvWriter.Comma = o.delimiter
+		}
+		hdrWritten := !header
+		w.write = func(v interface{}) error {
+			if !hdrWritten {
+				hdrWritten = true
+				if err := csvWriter.Write(hdrs); err != nil {
+					return err
+				}
+			}
+
+			host, d, errStr := bulkRowParts(v)
+			row := make([]string, 0, len(hdrs))
+			if hostCol {
+				row = append(row, host)
+			}
+			for _, cellFunc := range cellFuncs {
+				row = append(row, cellFunc(d))
+			}
+			if errCol {
+				row = append(row, errStr)
+			}
+			return csvWriter.Write(row)
+		}
+		w.flush = func() error {
+			csvWriter.Flush()
+			return csvWriter.Error()
+		}
+	case yamlFmt:
+		w.write = yaml.NewEncoder(w.buf).Encode
+	default:
+		w.write = json.NewEncoder(w.buf).Encode
+	}
+
+	return w, nil
+}
+
+// newASNStreamWriter returns a writer to stdout of `*ipinfo.ASNDetails`
+// results for either the template, NDJSON of the `fields` if `ndjson` is set,
+// the `fields` selection, YAML or otherwise NDJSON output.
+func newASNStreamWriter(o bulkWriterOpts) (*bulkStreamWriter, error) {
+	fields, yamlFmt, ndjson, header := o.fields, o.yamlFmt, o.ndjson, o.header
+
+	w := newBulkStreamWriter()
+
+	switch {
+	case o.tmpl != nil:
+		w.write = func(v interface{}) error {
+			return o.tmpl.Execute(w.buf, v)
+		}
+	case ndjson && len(fields) > 0:
+		objFunc, err := prepareFieldsJSONASNDetails(fields)
+		if err != nil {
+			return nil, err
+		}
+		enc := json.NewEncoder(w.buf)
+		w.write = func(v interface{}) error {
+			d, ok := v.(*ipinfo.ASNDetails)
+			if !ok {
+				return enc.Encode(v)
+			}
+			return enc.Encode(objFunc(d))
+		}
+	case ndjson:
+		w.write = json.NewEncoder(w.buf).Encode
+	case len(fields) > 0 || o.table:
+		if len(fields) == 0 {
+			fields = defaultTableFieldsASN
+		}
+		hdrs, cellFuncs, err := prepareColumnsASNDetails(fields, false)
+		if err != nil {
+			return nil, err
+		}
+		row := func(v interface{}) []string {
+			d := v.(*ipinfo.ASNDetails)
+			row := make([]string, len(cellFuncs))
+			for i, cellFunc := range cellFuncs {
+				row[i] = cellFunc(d)
+				if !o.table {
+					row[i] = encodeToCsvLine(row[i])
+				}
+			}
+			return row
+		}
+		if o.table {
+			w.write = w.tableWriter(hdrs, header, row)
+		} else {
+			w.write = w.fieldsWriter(hdrs, header, row)
+		}
+	case yamlFmt:
+		w.write = yaml.NewEncoder(w.buf).Encode
+	default:
+		w.write = json.NewEncoder(w.buf).Encode
+	}
+
+	return w, nil
+}
+
+func newBulkStreamWriter() *bulkStreamWriter {
+	return &bulkStreamWriter{
+		buf:   bufio.NewWriter(os.Stdout),
+		flush: func() error { return nil },
+	}
+}
+
+// fieldsWriter returns a write function for field selection rows, which
+// writes the `hdrs` before the first row if `header` is true.
+func (w *bulkStreamWriter) fieldsWriter(
+	hdrs []string,
+	header bool,
+	row func(interface{}) []string,
+) func(interface{}) error {
+	hdrWritten := !header
+	return func(v interface{}) error {
+		if !hdrWritten {
+			hdrWritten = true
+			fmt.Fprintln(w.buf, strings.Join(hdrs, ","))
+		}
+		_, err := fmt.Fprintln(w.buf, strings.Join(row(v), ","))
+		return err
+	}
+}
+
+// tableWriter returns a write function for table rows, which are buffered to
+// be output as a table with the `hdrs` as header, if `header` is true, when
+// the writer is flushed.
+func (w *bulkStreamWriter) tableWriter(
+	hdrs []string,
+	header bool,
+	row func(interface{}) []string,
+) func(interface{}) error {
+	t := &resultsTable{hdrs: hdrs, header: header}
+	w.flush = func() error {
+		return t.Output(w.buf)
+	}
+	return func(v interface{}) error {
+		t.Append(row(v))
+		return nil
+	}
+}
+
+// Write buffers a single result or `*bulkFailure`.
+func (w *bulkStreamWriter) Write(v interface{}) error {
+	if f, ok := v.(*bulkFailure); ok {
+		w.failed++
+		if w.errs != nil {
+			return w.errs.Write(f)
+		}
+	}
+	return w.write(v)
+}
+
+// Failed returns how many failures were written.
+func (w *bulkStreamWriter) Failed() int {
+	return w.failed
+}
+
+// Flush writes out all buffered results.
+func (w *bulkStreamWriter) Flush() error {
+	if err := w.flush(); err != nil {
+		return err
+	}
+	if w.errs != nil {
+		if err := w.errs.Flush(); err != nil {
+			return err
+		}
+	}
+	return w.buf.Flush()
+}
+
+// bulkErrorsWriter writes failures to a file, one JSON object per line.
+type bulkErrorsWriter struct {
+	f   *os.File
+	buf *bufio.Writer
+	enc *json.Encoder
+}
+
+// createBulkErrorsWriter creates, or truncates, the file at `path` to write
+// failures to.
+func createBulkErrorsWriter(path string) (*bulkErrorsWriter, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't create errors file: %w", err)
+	}
+
+	buf := bufio.NewWriter(f)
+	return &bulkErrorsWriter{f: f, buf: buf, enc: json.NewEncoder(buf)}, nil
+}
+
+// Write buffers a single failure.
+func (w *bulkErrorsWriter) Write(f *bulkFailure) error {
+	return w.enc.Encode(f)
+}
+
+// Flush writes out all buffered failures.
+func (w *bulkErrorsWriter) Flush() error {
+	return w.buf.Flush()
+}
+
+// Close flushes and closes the file.
+func (w *bulkErrorsWriter) Close() error {
+	if err := w.Flush(); err != nil {
+		w.f.Close()
+		return err
+	}
+	return w.f.Close()
+}
+
+// bulkInputsFromAllSrcs returns all IPs from all sources like
+// `iputil.IPListFromAllSrcs`, and separately all hostnames, without
+// duplicates. Any other input, like a hostname that isn't fully qualified,
+// e.g. 'localhost', is warned about and skipped.
+func bulkInputsFromAllSrcs(inputs []string) ([]net.IP, []string, error) {
+	var ips []net.IP
+	var hosts []string
+	seenHosts := make(map[string]struct{})
+	op := func(input string, inputType iputil.INPUT_TYPE) error {
+		switch inputType {
+		case iputil.INPUT_TYPE_IP:
+			ips = append(ips, net.ParseIP(input))
+		case iputil.INPUT_TYPE_IP_RANGE:
+			r, err := iputil.IPListFromRangeStr(input)
+			if err != nil {
+				return err
+			}
+			ips = append(ips, r...)
+		case iputil.INPUT_TYPE_CIDR:
+			r, err := iputil.IPListFromCIDR(input)
+			if err != nil {
+				return err
+			}
+			ips = append(ips, r...)
+		case iputil.INPUT_TYPE_UNKNOWN:
+			if lib.DomainRegex.FindString(input) == input {
+				if _, ok := seenHosts[input]; !ok {
+					seenHosts[input] = struct{}{}
+					hosts = append(hosts, input)
+				}
+				return nil
+			}
+			fallthrough
+		default:
+			fmt.Fprintf(os.Stderr, "warn: skipping %v, which isn't an IP, IP range, CIDR or fully qualified hostname\n", input)
+		}
+		return nil
+	}
+
+	if err := iputil.GetInputFrom(inputs, true, true, op); err != nil {
+		return nil, nil, err
+	}
+	return ips, hosts, nil
+}
+
+// bulkOutputOpts are the options of `outputBulkResults`.
+type bulkOutputOpts struct {
+	fields  []string
+	csvFmt  bool
+	yamlFmt bool
+	ndjson  bool
+	tmpl    *template.Template
+	table   bool
+
+	// the file to write the results to as Parquet, if set.
+	parquet string
+
+	// the CSV columns and delimiter, as in `bulkWriterOpts`.
+	columns   []string
+	delimiter rune
+
+	// whether to leave out the header of formats that have one.
+	noHeader bool
+
+	// whether failures get an error column in CSV and the field selection.
+	errCol bool
+
+	// where failures are written instead of with the results, if set.
+	errs *bulkErrorsWriter
+
+	// "ip" to sort the results by IP, or otherwise in input order.
+	sortBy string
+
+	// whether to output a result for every input IP, even repeated ones.
+	keepDuplicates bool
+}
+
+// a result output by `outputBulkResults` with its IP, or host for results of
+// hosts.
+type bulkResult struct {
+	key string
+	v   interface{}
+}
+
+// outputBulkResults outputs the `data` of all `ips` and then of all IPs of
+// each of the `hosts`, tagged with the host, in order. JSON and YAML map each
+// IP to its result and each host to the list of its results, or are a list of
+// all results when keeping duplicates.
+//
+// Failures in `data` and hosts which couldn't be resolved are output with the
+// results, or to `o.errs` if it's set; returns how many there were.
+func outputBulkResults(
+	ips []net.IP,
+	hosts []hostIPs,
+	data ipinfo.Batch,
+	o bulkOutputOpts,
+) (int, error) {
+	results := bulkResults(ips, hosts, data, o.keepDuplicates)
+	if o.sortBy == "ip" {
+		sort.SliceStable(results, func(i, j int) bool {
+			return lessIP(bulkResultIP(results[i].v), bulkResultIP(results[j].v))
+		})
+	}
+
+	if o.parquet != "" {
+		return outputBulkResultsParquet(results, len(hosts) > 0, o)
+	}
+
+	// templates, tables, NDJSON, the field selection and CSV are output row
+	// by row.
+	if o.tmpl != nil || o.table || o.ndjson || len(o.fields) > 0 || o.csvFmt {
+		w, err := newCoreStreamWriter(bulkWriterOpts{
+			fields:    o.fields,
+			csvFmt:    o.csvFmt,
+			ndjson:    o.ndjson,
+			tmpl:      o.tmpl,
+			table:     o.table,
+			columns:   o.columns,
+			delimiter: o.delimiter,
+			header:    !o.noHeader,
+			errCol:    o.errCol,
+			hostCol:   len(hosts) > 0,
+		})
+		if err != nil {
+			return 0, err
+		}
+		w.errs = o.errs
+
+		for _, r := range results {
+			if err := w.Write(r.v); err != nil {
+				return w.Failed(), err
+			}
+		}
+		return w.Failed(), w.Flush()
+	}
+
+	failed := 0
+	var list []interface{}
+	out := &orderedResults{vals: make(map[string]interface{}, len(results))}
+	for _, r := range results {
+		if f, ok := r.v.(*bulkFailure); ok {
+			failed++
+			if o.errs != nil {
+				if err := o.errs.Write(f); err != nil {
+					return failed, err
+				}
+				continue
+			}
+		}
+
+		if o.keepDuplicates {
+			list = append(list, r.v)
+			continue
+		}
+		if _, ok := out.vals[r.key]; !ok {
+			out.keys = append(out.keys, r.key)
+		}
+		switch v := r.v.(type) {
+		case *hostCore:
+			hostResults, _ := out.vals[r.key].([]interface{})
+			out.vals[r.key] = append(hostResults, v)
+		case *bulkFailure:
+			if v.Host == "" {
+				out.vals[r.key] = v
+			} else {
+				hostResults, _ := out.vals[r.key].([]interface{})
+				out.vals[r.key] = append(hostResults, v)
+			}
+		default:
+			out.vals[r.key] = v
+		}
+	}
+	if o.errs != nil {
+		if err := o.errs.Flush(); err != nil {
+			return failed, err
+		}
+	}
+
+	var v interface{} = out
+	if o.keepDuplicates {
+		v = list
+	}
+	if o.yamlFmt {
+		return failed, outputYAML(v)
+	}
+	return failed, outputJSON(v)
+}
+
+// returns the results of `outputBulkResults` in input order, with those of
+// repeated IPs only once unless `keepDuplicates` is set.
+func bulkResults(
+	ips []net.IP,
+	hosts []hostIPs,
+	data ipinfo.Batch,
+	keepDuplicates bool,
+) []bulkResult {
+	results := make([]bulkResult, 0, len(ips))
+
+	seen := make(map[string]struct{}, len(ips))
+	for _, ip := range ips {
+		k := ip.String()
+		if _, ok := seen[k]; ok && !keepDuplicates {
+			continue
+		}
+		seen[k] = struct{}{}
+
+		if v, ok := data[k]; ok {
+			results = append(results, bulkResult{k, v})
+		}
+	}
+
+	for _, h := range hosts {
+		if h.err != nil {
+			results = append(results, bulkResult{
+				h.host,
+				&bulkFailure{Host: h.host, Error: h.err.Error()},
+			})
+			continue
+		}
+
+		seen := make(map[string]struct{}, len(h.ips))
+		for _, ip := range h.ips {
+			k := ip.String()
+			if _, ok := seen[k]; ok {
+				continue
+			}
+			seen[k] = struct{}{}
+
+			switch d := data[k].(type) {
+			case *ipinfo.Core:
+				results = append(results, bulkResult{h.host, &hostCore{Host: h.host, Core: d}})
+			case *bulkFailure:
+				results = append(results, bulkResult{h.host, &bulkFailure{Host: h.host, IP: d.IP, Error: d.Error}})
+			}
+		}
+	}
+
+	return results
+}
+
+// writes the `results` of `outputBulkResults` to the `o.parquet` file, with a
+// host column if `hostCol` is set and an error column for failures output
+// with the results.
+func outputBulkResultsParquet(
+	results []bulkResult,
+	hostCol bool,
+	o bulkOutputOpts,
+) (int, error) {
+	failed := 0
+	schema := parquetCoreSchema(hostCol, o.errCol)
+	err := outputParquetFile(o.parquet, schema, func(w *parquetWriter) error {
+		for _, r := range results {
+			if f, ok := r.v.(*bulkFailure); ok {
+				failed++
+				if o.errs != nil {
+					if err := o.errs.Write(f); err != nil {
+						return err
+					}
+					continue
+				}
+			}
+
+			record, err := parquetCoreRecord(r.v)
+			if err != nil {
+				return err
+			}
+			if err := w.Write(record); err != nil {
+				return err
+			}
+		}
+		if o.errs != nil {
+			return o.errs.Flush()
+		}
+		return nil
+	})
+	return failed, err
+}
+
+// returns the IP of a result of `outputBulkResults`, if it has one.
+func bulkResultIP(v interface{}) net.IP {
+	switch v := v.(type) {
+	case *ipinfo.Core:
+		return v.IP
+	case *hostCore:
+		return v.IP
+	case *bulkFailure:
+		return net.ParseIP(v.IP)
+	}
+	return nil
+}
+
+// orders IPs numerically with IPv4 before IPv6, and no IP last.
+func lessIP(a, b net.IP) bool {
+	if a == nil || b == nil {
+		return b == nil && a != nil
+	}
+	if a4, b4 := a.To4() != nil, b.To4() != nil; a4 != b4 {
+		return a4
+	}
+	return bytes.Compare(a.To16(), b.To16()) < 0
+}
+
+// orderedResults are results keyed by IP or host which are output as a JSON
+// or YAML object with the keys in order.
+type orderedResults struct {
+	keys []string
+	vals map[string]interface{}
+}
+
+func (m *orderedResults) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, k := range m.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		kb, err := json.Marshal(k)
+		if err != nil {
+			return nil, err
+		}
+		vb, err := json.Marshal(m.vals[k])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(kb)
+		buf.WriteByte(':')
+		buf.Write(vb)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func (m *orderedResults) MarshalYAML() (interface{}, error) {
+	n := &yaml.Node{Kind: yaml.MappingNode}
+	for _, k := range m.keys {
+		var v yaml.Node
+		if err := v.Encode(m.vals[k]); err != nil {
+			return nil, err
+		}
+		n.Content = append(n.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: k}, &v)
+	}
+	return n, nil
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ipinfo/go/v2/ipinfo"
+)
+
+// Chunks that complete out of order must still be emitted in input order.
+func TestStreamBatchKeepsInputOrder(t *testing.T) {
+	src := func(yield func(string) error) error {
+		for i := 0; i < 95; i++ {
+			if err := yield(strconv.Itoa(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	lookup := func(keys []string, _ ipinfo.BatchReqOpts) (ipinfo.Batch, error) {
+		time.Sleep(time.Duration(rand.Intn(5)) * time.Millisecond)
+		res := make(ipinfo.Batch, len(keys))
+		for _, k := range keys {
+			res[k] = k
+		}
+		return res, nil
+	}
+
+	var got []string
+	emit := func(keys []string, res ipinfo.Batch) error {
+		if len(keys) > 10 {
+			t.Errorf("chunk of %d keys exceeds batch size", len(keys))
+		}
+		for _, k := range keys {
+			got = append(got, res[k].(string))
+		}
+		return nil
+	}
+
+	err := streamBatch(src, lookup, ipinfo.BatchReqOpts{
+		BatchSize:                    10,
+		ConcurrentBatchRequestsLimit: 4,
+	}, emit)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 95 {
+		t.Fatalf("expected 95 results, got %d", len(got))
+	}
+	for i, k := range got {
+		if k != strconv.Itoa(i) {
+			t.Fatalf("result %d out of order: got %s", i, k)
+		}
+	}
+}
+
+// A failed chunk stops the stream and its error is returned.
+func TestStreamBatchStopsOnError(t *testing.T) {
+	errLookup := errors.New("lookup failed")
+	src := func(yield func(string) error) error {
+		for i := 0; i < 1000; i++ {
+			if err := yield(strconv.Itoa(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	emitted := 0
+	lookup := func(keys []string, _ ipinfo.BatchReqOpts) (ipinfo.Batch, error) {
+		if keys[0] == "20" {
+			return nil, errLookup
+		}
+		return ipinfo.Batch{}, nil
+	}
+	emit := func(keys []string, res ipinfo.Batch) error {
+		emitted++
+		return nil
+	}
+
+	err := streamBatch(src, lookup, ipinfo.BatchReqOpts{
+		BatchSize:                    10,
+		ConcurrentBatchRequestsLimit: 1,
+	}, emit)
+	if !errors.Is(err, errLookup) {
+		t.Fatalf("expected lookup error, got %v", err)
+	}
+	if emitted != 2 {
+		t.Errorf("expected 2 chunks emitted before the error, got %d", emitted)
+	}
+}
+
+// With --keep-going, a failed batch is output with its error in place of its
+// results, and isn't recorded in the checkpoint.
+func TestStreamBulkKeepGoing(t *testing.T) {
+	var fail atomic.Bool
+	fail.Store(true)
+	newTestBatchClient(t, "1.0.0.15", &fail)
+	cp, err := openBulkCheckpoint(filepath.Join(t.TempDir(), "job.ckpt"), nil)
+	if err != nil {
+		t.Fatalf("open checkpoint: %v", err)
+	}
+	defer func() { cp.Close() }()
+
+	var w *bulkStreamWriter
+	stdout, _ := captureStd(t, func() {
+		w, err = newCoreStreamWriter(bulkWriterOpts{csvFmt: true, header: true, errCol: true})
+		if err != nil {
+			t.Fatalf("new writer: %v", err)
+		}
+		err = streamBulk(ipSrc(25), keepGoingLookup(ii.GetBatch), ipinfo.BatchReqOpts{
+			BatchSize:                    10,
+			ConcurrentBatchRequestsLimit: 2,
+		}, w, cp)
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if w.Failed() != 10 {
+		t.Errorf("expected 10 failures, got %d", w.Failed())
+	}
+
+	rows, err := csv.NewReader(strings.NewReader(stdout)).ReadAll()
+	if err != nil {
+		t.Fatalf("invalid CSV: %v", err)
+	}
+	if len(rows) != 26 || rows[0][0] != "ip" || rows[0][len(rows[0])-1] != "error" {
+		t.Fatalf("expected a header with an error column and 25 rows, got:\n%s", stdout)
+	}
+	for i, row := range rows[1:] {
+		failed := i >= 10 && i < 20
+		if row[0] != fmt.Sprintf("1.0.0.%d", i) || (row[len(row)-1] != "") != failed {
+			t.Errorf("unexpected row %d: %v", i, row)
+		}
+	}
+
+	cp.Close()
+	cp, err = openBulkCheckpoint(cp.f.Name(), nil)
+	if err != nil {
+		t.Fatalf("reopen checkpoint: %v", err)
+	}
+	if cp.Done("1.0.0.15") || !cp.Done("1.0.0.5") || !cp.Done("1.0.0.24") {
+		t.Errorf("expected only successful IPs to be checkpointed")
+	}
+}
+
+// Results of hosts are tagged with the host they were resolved from, and
+// unresolved hosts are output as failures.
+func TestOutputBulkResultsHosts(t *testing.T) {
+	ip1, ip2 := net.ParseIP("1.1.1.1"), net.ParseIP("2.2.2.2")
+	data := ipinfo.Batch{
+		"1.1.1.1": &ipinfo.Core{IP: ip1, City: "A"},
+		"2.2.2.2": &ipinfo.Core{IP: ip2, City: "B"},
+	}
+	hosts := []hostIPs{
+		{host: "a.example", ips: []net.IP{ip1, ip2, ip2}},
+		{host: "b.example", err: errors.New("no such host")},
+	}
+
+	var failed int
+	var err error
+	stdout, _ := captureStd(t, func() {
+		failed, err = outputBulkResults([]net.IP{ip1, ip1}, hosts, data, bulkOutputOpts{
+			fields: []string{"ip", "city"},
+			errCol: true,
+		})
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if failed != 1 {
+		t.Errorf("expected 1 failure, got %d", failed)
+	}
+	want := "host,ip,city,error\n" +
+		",1.1.1.1,A,\n" +
+		"a.example,1.1.1.1,A,\n" +
+		"a.example,2.2.2.2,B,\n" +
+		"b.example,,,no such host\n"
+	if stdout != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, stdout)
+	}
+
+	stdout, _ = captureStd(t, func() {
+		_, err = outputBulkResults([]net.IP{ip1}, hosts[:1], data, bulkOutputOpts{})
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var out map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(stdout), &out); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	var hostResults []map[string]interface{}
+	if err := json.Unmarshal(out["a.example"], &hostResults); err != nil {
+		t.Fatalf("expected a list of results for the host: %v", err)
+	}
+	if len(out) != 2 || len(hostResults) != 2 || hostResults[1]["host"] != "a.example" || hostResults[1]["city"] != "B" {
+		t.Errorf("unexpected output:\n%s", stdout)
+	}
+}
+
+// Results can be sorted by IP, and repeated IPs output once per input.
+func TestOutputBulkResultsOrder(t *testing.T) {
+	ips := []net.IP{
+		net.ParseIP("10.0.0.2"),
+		net.ParseIP("2001:db8::1"),
+		net.ParseIP("9.0.0.1"),
+		net.ParseIP("10.0.0.2"),
+	}
+	data := make(ipinfo.Batch)
+	for _, ip := range ips {
+		data[ip.String()] = &ipinfo.Core{IP: ip}
+	}
+
+	tests := []struct {
+		o    bulkOutputOpts
+		want string
+	}{
+		{
+			bulkOutputOpts{fields: []string{"ip"}},
+			"ip\n10.0.0.2\n2001:db8::1\n9.0.0.1\n",
+		},
+		{
+			bulkOutputOpts{fields: []string{"ip"}, sortBy: "ip"},
+			"ip\n9.0.0.1\n10.0.0.2\n2001:db8::1\n",
+		},
+		{
+			bulkOutputOpts{fields: []string{"ip"}, keepDuplicates: true},
+			"ip\n10.0.0.2\n2001:db8::1\n9.0.0.1\n10.0.0.2\n",
+		},
+		{
+			bulkOutputOpts{csvFmt: true, sortBy: "input"},
+			"",
+		},
+	}
+	for _, tt := range tests {
+		var err error
+		stdout, _ := captureStd(t, func() {
+			_, err = outputBulkResults(ips, nil, data, tt.o)
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if tt.o.csvFmt {
+			// only check the order of the rows' IPs.
+			var got []string
+			for _, line := range strings.Split(stdout, "\n")[1:] {
+				if line != "" {
+					got = append(got, strings.SplitN(line, ",", 2)[0])
+				}
+			}
+			if strings.Join(got, " ") != "10.0.0.2 2001:db8::1 9.0.0.1" {
+				t.Errorf("%+v: unexpected order %v", tt.o, got)
+			}
+			continue
+		}
+		if stdout != tt.want {
+			t.Errorf("%+v: expected:\n%s\ngot:\n%s", tt.o, tt.want, stdout)
+		}
+	}
+}
+
+// JSON and YAML objects keep their keys sorted by IP.
+func TestOutputBulkResultsOrderedObject(t *testing.T) {
+	ips := []net.IP{net.ParseIP("10.0.0.2"), net.ParseIP("9.0.0.1")}
+	data := make(ipinfo.Batch)
+	for _, ip := range ips {
+		data[ip.String()] = &ipinfo.Core{IP: ip}
+	}
+
+	for _, yamlFmt := range []bool{false, true} {
+		var err error
+		stdout, _ := captureStd(t, func() {
+			_, err = outputBulkResults(ips, nil, data, bulkOutputOpts{yamlFmt: yamlFmt, sortBy: "ip"})
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		i, j := strings.Index(stdout, "9.0.0.1"), strings.Index(stdout, "10.0.0.2")
+		if i < 0 || j < 0 || i > j {
+			t.Errorf("expected 9.0.0.1 before 10.0.0.2, got:\n%s", stdout)
+		}
+	}
+}
+
+// Hostnames that aren't fully qualified are skipped with a warning rather
+// than silently.
+func TestBulkInputsFromAllSrcsSkips(t *testing.T) {
+	stdin, err := os.Create(filepath.Join(t.TempDir(), "stdin"))
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	defer stdin.Close()
+	origStdin := os.Stdin
+	os.Stdin = stdin
+	defer func() { os.Stdin = origStdin }()
+
+	var ips []net.IP
+	var hosts []string
+	_, stderr := captureStd(t, func() {
+		ips, hosts, err = bulkInputsFromAllSrcs([]string{
+			"8.8.8.8", "localhost", "example.com", "example.com",
+		})
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(ips) != 1 || !ips[0].Equal(net.ParseIP("8.8.8.8")) {
+		t.Errorf("unexpected IPs %v", ips)
+	}
+	if len(hosts) != 1 || hosts[0] != "example.com" {
+		t.Errorf("unexpected hosts %v", hosts)
+	}
+	if !strings.Contains(stderr, "skipping localhost") {
+		t.Errorf("expected a warning about localhost, got %q", stderr)
+	}
+}
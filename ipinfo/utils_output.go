@@ -5,79 +5,20 @@ import (
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
+	"net"
 	"os"
+	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 
 	"github.com/fatih/color"
+	"github.com/ipinfo/cli/lib"
 	"github.com/ipinfo/go/v2/ipinfo"
 	"github.com/jszwec/csvutil"
 	"gopkg.in/yaml.v3"
 )
 
-var coreFields = []string{
-	"ip",
-	"hostname",
-	"anycast",
-	"city",
-	"region",
-	"country",
-	"country_name",
-	"loc",
-	"org",
-	"postal",
-	"timezone",
-	"asn",
-	"asn.id",
-	"asn.name",
-	"asn.asn",
-	"asn.domain",
-	"asn.route",
-	"asn.type",
-	"company",
-	"company.name",
-	"company.domain",
-	"company.type",
-	"carrier",
-	"carrier.name",
-	"carrier.mcc",
-	"carrier.mnc",
-	"privacy",
-	"privacy.vpn",
-	"privacy.proxy",
-	"privacy.tor",
-	"privacy.relay",
-	"privacy.hosting",
-	"privacy.service",
-	"abuse",
-	"abuse.address",
-	"abuse.country",
-	"abuse.country_name",
-	"abuse.email",
-	"abuse.name",
-	"abuse.network",
-	"abuse.phone",
-	"domains",
-	"domains.total",
-}
-
-var asnFields = []string{
-	"id",
-	"asn",
-	"name",
-	"country",
-	"country_name",
-	"allocated",
-	"registry",
-	"domain",
-	"num_ips",
-	"prefixes",
-	"prefixes6",
-	"peers",
-	"upstreams",
-	"downstreams",
-}
-
 // Converts `i` to a single CSV encoded line, where `i` may be a `string`,
 // `[]string`, `bool` or any value that can be converted to a string.
 func encodeToCsvLine(i interface{}) string {
@@ -119,6 +60,69 @@ func outputJSON(d interface{}) error {
 	return jsonEnc.Encode(d)
 }
 
+// outputNDJSON outputs `d` as a single line of compact JSON.
+func outputNDJSON(d interface{}) error {
+	return json.NewEncoder(os.Stdout).Encode(d)
+}
+
+// outputNDJSONCore outputs `d` as a single line of compact JSON, with only
+// the `fields` and the IP if any are given.
+func outputNDJSONCore(d *ipinfo.Core, fields []string) error {
+	if len(fields) == 0 {
+		return outputNDJSON(d)
+	}
+	objFunc, err := prepareFieldsJSONCore(fields)
+	if err != nil {
+		return err
+	}
+	return outputNDJSON(objFunc(d))
+}
+
+// outputNDJSONASNDetails outputs `d` as a single line of compact JSON, with
+// only the `fields` and the ASN if any are given.
+func outputNDJSONASNDetails(d *ipinfo.ASNDetails, fields []string) error {
+	if len(fields) == 0 {
+		return outputNDJSON(d)
+	}
+	objFunc, err := prepareFieldsJSONASNDetails(fields)
+	if err != nil {
+		return err
+	}
+	return outputNDJSON(objFunc(d))
+}
+
+// prepareFieldsJSONCore validates `fields` and returns a function returning
+// the JSON object of a result with only its IP and the `fields`.
+func prepareFieldsJSONCore(
+	fields []string,
+) (func(*ipinfo.Core) map[string]interface{}, error) {
+	sel, err := coreSchema.selectFields(fields)
+	if err != nil {
+		return nil, err
+	}
+	sel = coreSchema.prependField(sel, "ip")
+
+	return func(d *ipinfo.Core) map[string]interface{} {
+		return coreSchema.object(reflect.ValueOf(d), sel)
+	}, nil
+}
+
+// prepareFieldsJSONASNDetails validates `fields` and returns a function
+// returning the JSON object of a result with only its ASN and the `fields`.
+func prepareFieldsJSONASNDetails(
+	fields []string,
+) (func(*ipinfo.ASNDetails) map[string]interface{}, error) {
+	sel, err := asnSchema.selectFields(fields)
+	if err != nil {
+		return nil, err
+	}
+	sel = asnSchema.prependField(sel, "asn")
+
+	return func(d *ipinfo.ASNDetails) map[string]interface{} {
+		return asnSchema.object(reflect.ValueOf(d), sel)
+	}, nil
+}
+
 func outputCSV(v interface{}) error {
 	csvWriter := csv.NewWriter(os.Stdout)
 	csvEnc := csvutil.NewEncoder(csvWriter)
@@ -131,19 +135,115 @@ func outputCSV(v interface{}) error {
 	return nil
 }
 
+// the fields of CSV output of `ipinfo.Core` results, in order. Objects have
+// a column for each of their fields, e.g. 'asn_id' and 'company_name', which
+// are empty when the object is missing. The AS name is 'asn.asn', so that its
+// column is 'asn_asn' as it was when CSV was encoded by its csv tags.
+var coreCSVFields = []string{
+	"ip",
+	"hostname",
+	"bogon",
+	"anycast",
+	"city",
+	"region",
+	"country",
+	"country_name",
+	"country_flag",
+	"country_flag_url",
+	"country_currency",
+	"continent",
+	"isEU",
+	"loc",
+	"org",
+	"postal",
+	"timezone",
+	"asn.id",
+	"asn.asn",
+	"asn.domain",
+	"asn.route",
+	"asn.type",
+	"company",
+	"carrier",
+	"privacy",
+	"abuse",
+	"domains",
+}
+
+// prepareCSVColumnsCore validates `columns` and returns them with the
+// per-column functions returning the value of each cell, or all CSV columns
+// if no `columns` are given.
+func prepareCSVColumnsCore(
+	columns []string,
+) ([]string, []func(*ipinfo.Core) string, error) {
+	hdrs, cellFuncs, err := prepareColumnsCore(coreCSVFields, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(columns) == 0 {
+		return hdrs, cellFuncs, nil
+	}
+
+	idx := make(map[string]int, len(hdrs))
+	for i, hdr := range hdrs {
+		idx[hdr] = i
+	}
+	// 'asn_name' as in the field selection.
+	idx["asn_name"] = idx["asn_asn"]
+	selFuncs := make([]func(*ipinfo.Core) string, 0, len(columns))
+	for _, c := range columns {
+		i, ok := idx[c]
+		if !ok {
+			errStr := "column '%v' is invalid; the following are allowed:"
+			errStr += "  " + strings.Join(hdrs, "\n  ")
+			return nil, nil, lib.UsageErrorf(errStr, c)
+		}
+		selFuncs = append(selFuncs, cellFuncs[i])
+	}
+	return columns, selFuncs, nil
+}
+
+// coreCSVColumns returns the names of all CSV columns.
+func coreCSVColumns() []string {
+	hdrs, _, _ := prepareCSVColumnsCore(nil)
+	return hdrs
+}
+
+// parseCSVDelimiter parses the --delimiter flag, which is a single character
+// or 'tab', defaulting to a comma.
+func parseCSVDelimiter(s string) (rune, error) {
+	switch s {
+	case "":
+		return ',', nil
+	case "tab", "\\t":
+		return '\t', nil
+	}
+	r := []rune(s)
+	if len(r) != 1 || r[0] == '"' || r[0] == '\r' || r[0] == '\n' {
+		return 0, lib.UsageErrorf("invalid delimiter %q; must be a single character or 'tab'", s)
+	}
+	return r[0], nil
+}
+
+// outputCSVBatchCore outputs `core` as CSV with all CSV columns, sorted by IP.
 func outputCSVBatchCore(core ipinfo.BatchCore) error {
-	csvWriter := csv.NewWriter(os.Stdout)
-	csvEnc := csvutil.NewEncoder(csvWriter)
+	ips := make([]string, 0, len(core))
+	for ip := range core {
+		ips = append(ips, ip)
+	}
+	sort.Slice(ips, func(i, j int) bool {
+		return lessIP(net.ParseIP(ips[i]), net.ParseIP(ips[j]))
+	})
 
-	// print entries.
-	for _, v := range core {
-		if err := csvEnc.Encode(v); err != nil {
+	w, err := newCoreStreamWriter(bulkWriterOpts{csvFmt: true, header: true})
+	if err != nil {
+		return err
+	}
+	for _, ip := range ips {
+		if err := w.Write(core[ip]); err != nil {
 			return err
 		}
-		csvWriter.Flush()
 	}
-
-	return nil
+	return w.Flush()
 }
 
 func outputFriendlyCore(d *ipinfo.Core) {
@@ -153,21 +253,31 @@ func outputFriendlyCore(d *ipinfo.Core) {
 	fmtEntry := color.New(color.FgCyan)
 	fmtVal := color.New(color.FgGreen)
 
-	printlineGen := func(entryLen string) func(string, string) {
+	// the labels are padded to the width of the widest of them, as they are
+	// in the locale's language.
+	printlineGen := func(labels ...string) func(string, string) {
+		w := labelWidth(labels...)
 		return func(name string, val string) {
 			fmt.Printf(
 				"- %s %s\n",
-				fmtEntry.Sprintf("%-"+entryLen+"s", name),
+				fmtEntry.Sprint(padLabel(tr(name), w)),
 				fmtVal.Sprintf("%v", val),
 			)
 		}
 	}
 
-	fmtHdr.Println("Core")
+	fmtHdr.Println(tr("Core"))
 	if d.Bogon {
-		printline = printlineGen("5")
+		printline = printlineGen("IP", "Bogon")
 	} else {
-		printline = printlineGen("12")
+		labels := []string{
+			"IP", "Anycast", "Hostname", "City", "Region", "Country",
+			"Currency", "Location", "Organization", "Postal", "Timezone",
+		}
+		if gLocale != nil {
+			labels = append(labels, "Continent")
+		}
+		printline = printlineGen(labels...)
 	}
 	printline("IP", d.IP.String())
 	if d.Bogon {
@@ -182,7 +292,12 @@ func outputFriendlyCore(d *ipinfo.Core) {
 	if d.Country == "" {
 		printline("Country", "")
 	} else {
-		printline("Country", fmt.Sprintf("%v (%v)", d.CountryName, d.Country))
+		printline("Country", fmt.Sprintf("%v (%v)", localeCountryName(d.Country, d.CountryName), d.Country))
+		// only localized output has the continent, so that the default
+		// output stays as it was.
+		if gLocale != nil && d.Continent.Code != "" {
+			printline("Continent", fmt.Sprintf("%v (%v)", localeContinentName(d.Continent.Code, d.Continent.Name), d.Continent.Code))
+		}
 		printline("Currency", fmt.Sprintf("%v (%v)", d.CountryCurrency.Code, d.CountryCurrency.Symbol))
 	}
 	printline("Location", d.Location)
@@ -191,8 +306,8 @@ func outputFriendlyCore(d *ipinfo.Core) {
 	printline("Timezone", d.Timezone)
 	if d.ASN != nil {
 		fmt.Println()
-		fmtHdr.Println("ASN")
-		printline = printlineGen("6")
+		fmtHdr.Println(tr("ASN"))
+		printline = printlineGen("ID", "Name", "Domain", "Route", "Type")
 		printline("ID", d.ASN.ASN)
 		printline("Name", d.ASN.Name)
 		printline("Domain", d.ASN.Domain)
@@ -201,24 +316,26 @@ func outputFriendlyCore(d *ipinfo.Core) {
 	}
 	if d.Company != nil {
 		fmt.Println()
-		fmtHdr.Println("Company")
-		printline = printlineGen("6")
+		fmtHdr.Println(tr("Company"))
+		printline = printlineGen("Name", "Domain", "Type")
 		printline("Name", d.Company.Name)
 		printline("Domain", d.Company.Domain)
 		printline("Type", d.Company.Type)
 	}
 	if d.Carrier != nil {
 		fmt.Println()
-		fmtHdr.Println("Carrier")
-		printline = printlineGen("4")
+		fmtHdr.Println(tr("Carrier"))
+		printline = printlineGen("Name", "MCC", "MNC")
 		printline("Name", d.Carrier.Name)
 		printline("MCC", d.Carrier.MCC)
 		printline("MNC", d.Carrier.MNC)
 	}
 	if d.Privacy != nil {
 		fmt.Println()
-		fmtHdr.Println("Privacy")
-		printline = printlineGen("7")
+		fmtHdr.Println(tr("Privacy"))
+		printline = printlineGen(
+			"VPN", "Proxy", "Tor", "Relay", "Hosting", "Service",
+		)
 		printline("VPN", fmt.Sprintf("%v", d.Privacy.VPN))
 		printline("Proxy", fmt.Sprintf("%v", d.Privacy.Proxy))
 		printline("Tor", fmt.Sprintf("%v", d.Privacy.Tor))
@@ -228,10 +345,12 @@ func outputFriendlyCore(d *ipinfo.Core) {
 	}
 	if d.Abuse != nil {
 		fmt.Println()
-		fmtHdr.Println("Abuse")
-		printline = printlineGen("7")
+		fmtHdr.Println(tr("Abuse"))
+		printline = printlineGen(
+			"Address", "Country", "Email", "Name", "Network", "Phone",
+		)
 		printline("Address", d.Abuse.Address)
-		printline("Country", fmt.Sprintf("%v (%v)", d.Abuse.CountryName, d.Abuse.Country))
+		printline("Country", fmt.Sprintf("%v (%v)", localeCountryName(d.Abuse.Country, d.Abuse.CountryName), d.Abuse.Country))
 		printline("Email", d.Abuse.Email)
 		printline("Name", d.Abuse.Name)
 		printline("Network", d.Abuse.Network)
@@ -239,15 +358,16 @@ func outputFriendlyCore(d *ipinfo.Core) {
 	}
 	if d.Domains != nil && d.Domains.Total > 0 {
 		fmt.Println()
-		fmtHdr.Println("Domains")
-		printline = printlineGen("8")
+		fmtHdr.Println(tr("Domains"))
+		printline = printlineGen("Total", "Examples")
 		printline("Total", fmt.Sprintf("%v", d.Domains.Total))
 		if len(d.Domains.Domains) > 0 {
 			printline("Examples", d.Domains.Domains[0])
 			if len(d.Domains.Domains) > 1 {
+				indent := strings.Repeat(" ", labelWidth("Total", "Examples")+3)
 				for _, d := range d.Domains.Domains[1:] {
 					fmt.Printf(
-						"           %v\n", fmtVal.Sprintf("%v", d),
+						"%v%v\n", indent, fmtVal.Sprintf("%v", d),
 					)
 				}
 			}
@@ -261,175 +381,9 @@ func outputFieldBatchCore(
 	header bool,
 	inclIP bool,
 ) error {
-	// error on bad field.
-	for _, f := range fields {
-		hasField := false
-		for _, coreF := range coreFields {
-			if coreF == f {
-				hasField = true
-				break
-			}
-		}
-		if !hasField {
-			errStr := "field '%v' is invalid; the following are allowed:"
-			errStr += "  " + strings.Join(coreFields, "\n  ")
-			return fmt.Errorf(errStr, f)
-		}
-	}
-
-	// see if we should include IP as well.
-	if header && inclIP {
-		// only include it automatically if not already specified in list.
-		hasIPField := false
-		for _, f := range fields {
-			if f == "ip" {
-				hasIPField = true
-				break
-			}
-		}
-		if !hasIPField {
-			fields = append([]string{"ip"}, fields...)
-		}
-	}
-
-	hdrs := make([]string, 0, len(fields))
-	rowFuncs := make([]func(*ipinfo.Core) string, 0, len(fields))
-	for _, f := range fields {
-		switch f {
-		case "asn":
-			hdrs = append(
-				hdrs,
-				"asn_id",
-				"asn_name",
-				"asn_domain",
-				"asn_route",
-				"asn_type",
-			)
-			rowFuncs = append(rowFuncs, outputFieldCoreASN)
-		case "company":
-			hdrs = append(
-				hdrs,
-				"company_name",
-				"company_domain",
-				"company_type",
-			)
-			rowFuncs = append(rowFuncs, outputFieldCoreCompany)
-		case "carrier":
-			hdrs = append(
-				hdrs,
-				"carrier_name",
-				"carrier_mcc",
-				"carrier_mnc",
-			)
-			rowFuncs = append(rowFuncs, outputFieldCoreCarrier)
-		case "privacy":
-			hdrs = append(
-				hdrs,
-				"privacy_vpn",
-				"privacy_proxy",
-				"privacy_tor",
-				"privacy_relay",
-				"privacy_hosting",
-				"privacy_service",
-			)
-			rowFuncs = append(rowFuncs, outputFieldCorePrivacy)
-		case "abuse":
-			hdrs = append(
-				hdrs,
-				"abuse_address",
-				"abuse_country",
-				"abuse_country_name",
-				"abuse_email",
-				"abuse_name",
-				"abuse_network",
-				"abuse_phone",
-			)
-			rowFuncs = append(rowFuncs, outputFieldCoreAbuse)
-		case "domains":
-			hdrs = append(
-				hdrs,
-				"domains_total",
-			)
-			rowFuncs = append(rowFuncs, outputFieldCoreDomains)
-		default:
-			hdrs = append(hdrs, strings.ReplaceAll(f, ".", "_"))
-		}
-
-		// funcs now.
-		switch f {
-		case "ip":
-			rowFuncs = append(rowFuncs, outputFieldCoreIP)
-		case "hostname":
-			rowFuncs = append(rowFuncs, outputFieldCoreHostname)
-		case "anycast":
-			rowFuncs = append(rowFuncs, outputFieldCoreAnycast)
-		case "city":
-			rowFuncs = append(rowFuncs, outputFieldCoreCity)
-		case "region":
-			rowFuncs = append(rowFuncs, outputFieldCoreRegion)
-		case "country":
-			rowFuncs = append(rowFuncs, outputFieldCoreCountry)
-		case "country_name":
-			rowFuncs = append(rowFuncs, outputFieldCoreCountryName)
-		case "loc":
-			rowFuncs = append(rowFuncs, outputFieldCoreLoc)
-		case "org":
-			rowFuncs = append(rowFuncs, outputFieldCoreOrg)
-		case "postal":
-			rowFuncs = append(rowFuncs, outputFieldCorePostal)
-		case "timezone":
-			rowFuncs = append(rowFuncs, outputFieldCoreTimezone)
-		case "asn.id":
-			rowFuncs = append(rowFuncs, outputFieldCoreASNId)
-		case "asn.name", "asn.asn":
-			rowFuncs = append(rowFuncs, outputFieldCoreASNName)
-		case "asn.domain":
-			rowFuncs = append(rowFuncs, outputFieldCoreASNDomain)
-		case "asn.route":
-			rowFuncs = append(rowFuncs, outputFieldCoreASNRoute)
-		case "asn.type":
-			rowFuncs = append(rowFuncs, outputFieldCoreASNType)
-		case "company.name":
-			rowFuncs = append(rowFuncs, outputFieldCoreCompanyName)
-		case "company.domain":
-			rowFuncs = append(rowFuncs, outputFieldCoreCompanyDomain)
-		case "company.type":
-			rowFuncs = append(rowFuncs, outputFieldCoreCompanyType)
-		case "carrier.name":
-			rowFuncs = append(rowFuncs, outputFieldCoreCarrierName)
-		case "carrier.mcc":
-			rowFuncs = append(rowFuncs, outputFieldCoreCarrierMCC)
-		case "carrier.mnc":
-			rowFuncs = append(rowFuncs, outputFieldCoreCarrierMNC)
-		case "privacy.vpn":
-			rowFuncs = append(rowFuncs, outputFieldCorePrivacyVPN)
-		case "privacy.proxy":
-			rowFuncs = append(rowFuncs, outputFieldCorePrivacyProxy)
-		case "privacy.tor":
-			rowFuncs = append(rowFuncs, outputFieldCorePrivacyTor)
-		case "privacy.relay":
-			rowFuncs = append(rowFuncs, outputFieldCorePrivacyRelay)
-		case "privacy.hosting":
-			rowFuncs = append(rowFuncs, outputFieldCorePrivacyHosting)
-		case "privacy.service":
-			rowFuncs = append(rowFuncs, outputFieldCorePrivacyService)
-		case "abuse.address":
-			rowFuncs = append(rowFuncs, outputFieldCoreAbuseAddress)
-		case "abuse.country":
-			rowFuncs = append(rowFuncs, outputFieldCoreAbuseCountry)
-		case "abuse.country_name":
-			rowFuncs = append(rowFuncs, outputFieldCoreAbuseCountryName)
-		case "abuse.email":
-			rowFuncs = append(rowFuncs, outputFieldCoreAbuseEmail)
-		case "abuse.name":
-			rowFuncs = append(rowFuncs, outputFieldCoreAbuseName)
-		case "abuse.network":
-			rowFuncs = append(rowFuncs, outputFieldCoreAbuseNetwork)
-		case "abuse.phone":
-			rowFuncs = append(rowFuncs, outputFieldCoreAbusePhone)
-		case "domains.total":
-			rowFuncs = append(rowFuncs, outputFieldCoreDomainsTotal)
-		}
+	hdrs, rowFuncs, err := prepareFieldsCore(fields, header, inclIP)
+	if err != nil {
+		return err
 	}
 
 	fmt.Println(strings.Join(hdrs, ","))
@@ -444,429 +398,125 @@ func outputFieldBatchCore(
 	return nil
 }
 
-func outputFieldBatchASNDetails(
-	asnDetails ipinfo.BatchASNDetails,
+// prepareFieldsCore validates `fields` and returns the header and per-column
+// row functions to output them with as CSV.
+func prepareFieldsCore(
 	fields []string,
 	header bool,
-	inclASNId bool,
-) error {
-	// error on bad field.
-	for _, f := range fields {
-		hasField := false
-		for _, asnF := range asnFields {
-			if asnF == f {
-				hasField = true
-				break
-			}
-		}
-		if !hasField {
-			errStr := "field '%v' is invalid; the following are allowed:"
-			errStr += "  " + strings.Join(asnFields, "\n  ")
-			return fmt.Errorf(errStr, f)
-		}
-	}
-
-	// see if we should include ASN name as well.
-	if header && inclASNId {
-		// only include it automatically if not already specified in list.
-		hasASNIdField := false
-		for _, f := range fields {
-			if f == "id" || f == "asn" {
-				hasASNIdField = true
-				break
-			}
-		}
-		if !hasASNIdField {
-			fmt.Printf("id,")
-			fields = append([]string{"id"}, fields...)
-		}
-	}
-
-	hdrs := make([]string, 0, len(fields))
-	rowFuncs := make([]func(*ipinfo.ASNDetails) string, 0, len(fields))
-	for _, f := range fields {
-		hdrs = append(hdrs, strings.ReplaceAll(f, ".", "_"))
-
-		switch f {
-		case "id", "asn":
-			rowFuncs = append(rowFuncs, outputFieldASNId)
-		case "name":
-			rowFuncs = append(rowFuncs, outputFieldASNName)
-		case "country":
-			rowFuncs = append(rowFuncs, outputFieldASNCountry)
-		case "country_name":
-			rowFuncs = append(rowFuncs, outputFieldASNCountryName)
-		case "allocated":
-			rowFuncs = append(rowFuncs, outputFieldASNAllocated)
-		case "registry":
-			rowFuncs = append(rowFuncs, outputFieldASNRegistry)
-		case "domain":
-			rowFuncs = append(rowFuncs, outputFieldASNDomain)
-		case "num_ips":
-			rowFuncs = append(rowFuncs, outputFieldASNNumIPs)
-		case "prefixes":
-			rowFuncs = append(rowFuncs, outputFieldASNPrefixes)
-		case "prefixes6":
-			rowFuncs = append(rowFuncs, outputFieldASNPrefixes6)
-		case "peers":
-			rowFuncs = append(rowFuncs, outputFieldASNPeers)
-		case "upstreams":
-			rowFuncs = append(rowFuncs, outputFieldASNUpstreams)
-		case "downstreams":
-			rowFuncs = append(rowFuncs, outputFieldASNDownstreams)
-		}
-	}
-
-	fmt.Println(strings.Join(hdrs, ","))
-	for _, d := range asnDetails {
-		row := make([]string, len(rowFuncs))
-		for i, rowFunc := range rowFuncs {
-			row[i] = rowFunc(d)
-		}
-		fmt.Println(strings.Join(row, ","))
-	}
-
-	return nil
-}
-
-func outputFieldCoreIP(core *ipinfo.Core) string {
-	return encodeToCsvLine(core.IP)
-}
-
-func outputFieldCoreHostname(core *ipinfo.Core) string {
-	return encodeToCsvLine(core.Hostname)
-}
-
-func outputFieldCoreAnycast(core *ipinfo.Core) string {
-	return encodeToCsvLine(core.Anycast)
-}
-
-func outputFieldCoreCity(core *ipinfo.Core) string {
-	return encodeToCsvLine(core.City)
-}
-
-func outputFieldCoreRegion(core *ipinfo.Core) string {
-	return encodeToCsvLine(core.Region)
-}
-
-func outputFieldCoreCountry(core *ipinfo.Core) string {
-	return encodeToCsvLine(core.Country)
-}
-
-func outputFieldCoreCountryName(core *ipinfo.Core) string {
-	return encodeToCsvLine(core.CountryName)
-}
-
-func outputFieldCoreLoc(core *ipinfo.Core) string {
-	return encodeToCsvLine(core.Location)
-}
-
-func outputFieldCoreOrg(core *ipinfo.Core) string {
-	return encodeToCsvLine(core.Org)
-}
-
-func outputFieldCorePostal(core *ipinfo.Core) string {
-	return encodeToCsvLine(core.Postal)
-}
-
-func outputFieldCoreTimezone(core *ipinfo.Core) string {
-	return encodeToCsvLine(core.Timezone)
-}
-
-func outputFieldCoreASN(core *ipinfo.Core) string {
-	if core.ASN == nil {
-		return ",,,,"
-	}
-	return encodeToCsvLine([]string{
-		core.ASN.ASN,
-		core.ASN.Name,
-		core.ASN.Domain,
-		core.ASN.Route,
-		core.ASN.Type,
-	})
-}
-
-func outputFieldCoreASNId(core *ipinfo.Core) string {
-	if core.ASN == nil {
-		return ""
-	}
-	return encodeToCsvLine(core.ASN.ASN)
-}
-
-func outputFieldCoreASNName(core *ipinfo.Core) string {
-	if core.ASN == nil {
-		return ""
-	}
-	return encodeToCsvLine(core.ASN.Name)
-}
-
-func outputFieldCoreASNDomain(core *ipinfo.Core) string {
-	if core.ASN == nil {
-		return ""
-	}
-	return encodeToCsvLine(core.ASN.Domain)
-}
-
-func outputFieldCoreASNRoute(core *ipinfo.Core) string {
-	if core.ASN == nil {
-		return ""
-	}
-	return encodeToCsvLine(core.ASN.Route)
-}
-
-func outputFieldCoreASNType(core *ipinfo.Core) string {
-	if core.ASN == nil {
-		return ""
-	}
-	return encodeToCsvLine(core.ASN.Type)
-}
-
-func outputFieldCoreCompany(core *ipinfo.Core) string {
-	if core.Company == nil {
-		return ",,"
-	}
-	return encodeToCsvLine([]string{
-		core.Company.Name,
-		core.Company.Domain,
-		core.Company.Type,
-	})
-}
-
-func outputFieldCoreCompanyName(core *ipinfo.Core) string {
-	if core.Company == nil {
-		return ""
-	}
-	return encodeToCsvLine(core.Company.Name)
-}
-
-func outputFieldCoreCompanyDomain(core *ipinfo.Core) string {
-	if core.Company == nil {
-		return ""
-	}
-	return encodeToCsvLine(core.Company.Domain)
-}
-
-func outputFieldCoreCompanyType(core *ipinfo.Core) string {
-	if core.Company == nil {
-		return ""
-	}
-	return encodeToCsvLine(core.Company.Type)
-}
-
-func outputFieldCoreCarrier(core *ipinfo.Core) string {
-	if core.Carrier == nil {
-		return ",,"
-	}
-	return encodeToCsvLine([]string{
-		core.Carrier.Name,
-		core.Carrier.MCC,
-		core.Carrier.MNC,
-	})
-}
-
-func outputFieldCoreCarrierName(core *ipinfo.Core) string {
-	if core.Carrier == nil {
-		return ""
-	}
-	return encodeToCsvLine(core.Carrier.Name)
-}
-
-func outputFieldCoreCarrierMCC(core *ipinfo.Core) string {
-	if core.Carrier == nil {
-		return ""
-	}
-	return encodeToCsvLine(core.Carrier.MCC)
-}
-
-func outputFieldCoreCarrierMNC(core *ipinfo.Core) string {
-	if core.Carrier == nil {
-		return ""
-	}
-	return encodeToCsvLine(core.Carrier.MNC)
-}
-
-func outputFieldCorePrivacy(core *ipinfo.Core) string {
-	if core.Privacy == nil {
-		return ",,,,,"
-	}
-	return encodeToCsvLine([]string{
-		strconv.FormatBool(core.Privacy.VPN),
-		strconv.FormatBool(core.Privacy.Proxy),
-		strconv.FormatBool(core.Privacy.Tor),
-		strconv.FormatBool(core.Privacy.Relay),
-		strconv.FormatBool(core.Privacy.Hosting),
-		core.Privacy.Service,
-	})
-}
-
-func outputFieldCorePrivacyVPN(core *ipinfo.Core) string {
-	if core.Privacy == nil {
-		return ""
+	inclIP bool,
+) ([]string, []func(*ipinfo.Core) string, error) {
+	hdrs, cellFuncs, err := prepareColumnsCore(fields, header && inclIP)
+	if err != nil {
+		return nil, nil, err
 	}
-	return encodeToCsvLine(core.Privacy.VPN)
-}
 
-func outputFieldCorePrivacyProxy(core *ipinfo.Core) string {
-	if core.Privacy == nil {
-		return ""
+	rowFuncs := make([]func(*ipinfo.Core) string, 0, len(cellFuncs))
+	for _, cellFunc := range cellFuncs {
+		cellFunc := cellFunc
+		rowFuncs = append(rowFuncs, func(d *ipinfo.Core) string {
+			return encodeToCsvLine(cellFunc(d))
+		})
 	}
-	return encodeToCsvLine(core.Privacy.Proxy)
-}
 
-func outputFieldCorePrivacyTor(core *ipinfo.Core) string {
-	if core.Privacy == nil {
-		return ""
-	}
-	return encodeToCsvLine(core.Privacy.Tor)
+	return hdrs, rowFuncs, nil
 }
 
-func outputFieldCorePrivacyRelay(core *ipinfo.Core) string {
-	if core.Privacy == nil {
-		return ""
+// prepareColumnsCore validates `fields` and returns the header and
+// per-column functions returning the value of each cell, with the IP first
+// if `inclIP` is true and it isn't already specified in the list.
+func prepareColumnsCore(
+	fields []string,
+	inclIP bool,
+) ([]string, []func(*ipinfo.Core) string, error) {
+	sel, err := coreSchema.selectFields(fields)
+	if err != nil {
+		return nil, nil, err
 	}
-	return encodeToCsvLine(core.Privacy.Relay)
-}
-
-func outputFieldCorePrivacyHosting(core *ipinfo.Core) string {
-	if core.Privacy == nil {
-		return ""
+	if inclIP {
+		sel = coreSchema.prependField(sel, "ip")
 	}
-	return encodeToCsvLine(core.Privacy.Hosting)
-}
 
-func outputFieldCorePrivacyService(core *ipinfo.Core) string {
-	if core.Privacy == nil {
-		return ""
+	hdrs, cells := coreSchema.columns(sel)
+	cellFuncs := make([]func(*ipinfo.Core) string, 0, len(cells))
+	for _, cell := range cells {
+		cell := cell
+		cellFuncs = append(cellFuncs, func(d *ipinfo.Core) string {
+			return cell(reflect.ValueOf(d))
+		})
 	}
-	return encodeToCsvLine(core.Privacy.Service)
-}
 
-func outputFieldCoreAbuse(core *ipinfo.Core) string {
-	if core.Abuse == nil {
-		return ",,,,,,"
-	}
-	return encodeToCsvLine([]string{
-		core.Abuse.Address,
-		core.Abuse.Country,
-		core.Abuse.CountryName,
-		core.Abuse.Email,
-		core.Abuse.Name,
-		core.Abuse.Network,
-		core.Abuse.Phone,
-	})
+	return hdrs, cellFuncs, nil
 }
 
-func outputFieldCoreAbuseAddress(core *ipinfo.Core) string {
-	if core.Abuse == nil {
-		return ""
+func outputFieldBatchASNDetails(
+	asnDetails ipinfo.BatchASNDetails,
+	fields []string,
+	header bool,
+	inclASNId bool,
+) error {
+	hdrs, rowFuncs, err := prepareFieldsASNDetails(fields, header, inclASNId)
+	if err != nil {
+		return err
 	}
-	return encodeToCsvLine(core.Abuse.Address)
-}
 
-func outputFieldCoreAbuseCountry(core *ipinfo.Core) string {
-	if core.Abuse == nil {
-		return ""
+	fmt.Println(strings.Join(hdrs, ","))
+	for _, d := range asnDetails {
+		row := make([]string, len(rowFuncs))
+		for i, rowFunc := range rowFuncs {
+			row[i] = rowFunc(d)
+		}
+		fmt.Println(strings.Join(row, ","))
 	}
-	return encodeToCsvLine(core.Abuse.Country)
-}
 
-func outputFieldCoreAbuseCountryName(core *ipinfo.Core) string {
-	if core.Abuse == nil {
-		return ""
-	}
-	return encodeToCsvLine(core.Abuse.CountryName)
+	return nil
 }
 
-func outputFieldCoreAbuseEmail(core *ipinfo.Core) string {
-	if core.Abuse == nil {
-		return ""
+// prepareFieldsASNDetails validates `fields` and returns the header and per-column
+// row functions to output them with as CSV.
+func prepareFieldsASNDetails(
+	fields []string,
+	header bool,
+	inclASNId bool,
+) ([]string, []func(*ipinfo.ASNDetails) string, error) {
+	hdrs, cellFuncs, err := prepareColumnsASNDetails(fields, header && inclASNId)
+	if err != nil {
+		return nil, nil, err
 	}
-	return encodeToCsvLine(core.Abuse.Email)
-}
 
-func outputFieldCoreAbuseName(core *ipinfo.Core) string {
-	if core.Abuse == nil {
-		return ""
+	rowFuncs := make([]func(*ipinfo.ASNDetails) string, 0, len(cellFuncs))
+	for _, cellFunc := range cellFuncs {
+		cellFunc := cellFunc
+		rowFuncs = append(rowFuncs, func(d *ipinfo.ASNDetails) string {
+			return encodeToCsvLine(cellFunc(d))
+		})
 	}
-	return encodeToCsvLine(core.Abuse.Name)
-}
 
-func outputFieldCoreAbuseNetwork(core *ipinfo.Core) string {
-	if core.Abuse == nil {
-		return ""
-	}
-	return encodeToCsvLine(core.Abuse.Network)
+	return hdrs, rowFuncs, nil
 }
 
-func outputFieldCoreAbusePhone(core *ipinfo.Core) string {
-	if core.Abuse == nil {
-		return ""
+// prepareColumnsASNDetails validates `fields` and returns the header and
+// per-column functions returning the value of each cell, with the ASN first
+// if `inclASNId` is true and it isn't already specified in the list.
+func prepareColumnsASNDetails(
+	fields []string,
+	inclASNId bool,
+) ([]string, []func(*ipinfo.ASNDetails) string, error) {
+	sel, err := asnSchema.selectFields(fields)
+	if err != nil {
+		return nil, nil, err
 	}
-	return encodeToCsvLine(core.Abuse.Phone)
-}
-
-func outputFieldCoreDomains(core *ipinfo.Core) string {
-	if core.Domains == nil {
-		return ""
+	if inclASNId {
+		sel = asnSchema.prependField(sel, "id")
 	}
-	return encodeToCsvLine(core.Domains.Total)
-}
 
-func outputFieldCoreDomainsTotal(core *ipinfo.Core) string {
-	if core.Domains == nil {
-		return ""
+	hdrs, cells := asnSchema.columns(sel)
+	cellFuncs := make([]func(*ipinfo.ASNDetails) string, 0, len(cells))
+	for _, cell := range cells {
+		cell := cell
+		cellFuncs = append(cellFuncs, func(d *ipinfo.ASNDetails) string {
+			return cell(reflect.ValueOf(d))
+		})
 	}
-	return encodeToCsvLine(core.Domains.Total)
-}
-
-func outputFieldASNId(d *ipinfo.ASNDetails) string {
-	return encodeToCsvLine(d.ASN)
-}
-
-func outputFieldASNName(d *ipinfo.ASNDetails) string {
-	return encodeToCsvLine(d.Name)
-}
-
-func outputFieldASNCountry(d *ipinfo.ASNDetails) string {
-	return encodeToCsvLine(d.Country)
-}
-
-func outputFieldASNCountryName(d *ipinfo.ASNDetails) string {
-	return encodeToCsvLine(d.CountryName)
-}
-
-func outputFieldASNAllocated(d *ipinfo.ASNDetails) string {
-	return encodeToCsvLine(d.Allocated)
-}
-
-func outputFieldASNRegistry(d *ipinfo.ASNDetails) string {
-	return encodeToCsvLine(d.Registry)
-}
-
-func outputFieldASNDomain(d *ipinfo.ASNDetails) string {
-	return encodeToCsvLine(d.Domain)
-}
-
-func outputFieldASNNumIPs(d *ipinfo.ASNDetails) string {
-	return encodeToCsvLine(d.NumIPs)
-}
-
-func outputFieldASNPrefixes(d *ipinfo.ASNDetails) string {
-	return encodeToCsvLine(d.Prefixes)
-}
-
-func outputFieldASNPrefixes6(d *ipinfo.ASNDetails) string {
-	return encodeToCsvLine(d.Prefixes6)
-}
-
-func outputFieldASNPeers(d *ipinfo.ASNDetails) string {
-	return encodeToCsvLine(d.Peers)
-}
-
-func outputFieldASNUpstreams(d *ipinfo.ASNDetails) string {
-	return encodeToCsvLine(d.Upstreams)
-}
 
-func outputFieldASNDownstreams(d *ipinfo.ASNDetails) string {
-	return encodeToCsvLine(d.Downstreams)
+	return hdrs, cellFuncs, nil
 }
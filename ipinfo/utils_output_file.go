@@ -0,0 +1,239 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/ipinfo/cli/lib"
+	"github.com/spf13/pflag"
+)
+
+// outputFile is a file written through a temp file next to it, which only
+// replaces it once all of it is written, so that it's never left partially
+// written.
+type outputFile struct {
+	path string
+	tmp  *os.File
+	gz   *gzip.Writer
+}
+
+// createOutputFile creates the temp file of the output file at `path`, whose
+// contents are gzipped if `gz` is set.
+func createOutputFile(path string, gz bool) (*outputFile, error) {
+	tmp, err := os.CreateTemp(
+		filepath.Dir(path),
+		"."+filepath.Base(path)+".tmp-*",
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not create %v: %w", path, err)
+	}
+
+	f := &outputFile{path: path, tmp: tmp}
+	if gz {
+		f.gz = gzip.NewWriter(tmp)
+	}
+	return f, nil
+}
+
+// Write writes to the temp file.
+func (f *outputFile) Write(p []byte) (int, error) {
+	if f.gz != nil {
+		return f.gz.Write(p)
+	}
+	return f.tmp.Write(p)
+}
+
+// Commit replaces the output file by the temp file, keeping the mode of the
+// file it replaces, if any.
+func (f *outputFile) Commit() error {
+	if f.gz != nil {
+		if err := f.gz.Close(); err != nil {
+			f.Abort()
+			return err
+		}
+	}
+
+	var mode os.FileMode = 0644
+	if fi, err := os.Stat(f.path); err == nil {
+		mode = fi.Mode().Perm()
+	}
+	if err := f.tmp.Chmod(mode); err != nil {
+		f.Abort()
+		return err
+	}
+	if err := f.tmp.Sync(); err != nil {
+		f.Abort()
+		return err
+	}
+	if err := f.tmp.Close(); err != nil {
+		os.Remove(f.tmp.Name())
+		return err
+	}
+	if err := os.Rename(f.tmp.Name(), f.path); err != nil {
+		os.Remove(f.tmp.Name())
+		return fmt.Errorf("could not write %v: %w", f.path, err)
+	}
+	return nil
+}
+
+// Abort removes the temp file, leaving the output file as it was.
+func (f *outputFile) Abort() {
+	f.tmp.Close()
+	os.Remove(f.tmp.Name())
+}
+
+// redirectOutput redirects stdout to the output file at `path`, gzipped if it
+// ends in '.gz', until the returned function is called with the command's
+// error. The output file is then replaced by the output, unless there was an
+// error.
+//
+// Output isn't colored while it's redirected. If `path` is empty, stdout is
+// left as is.
+func redirectOutput(path string) (func(error) error, error) {
+	if path == "" {
+		return func(err error) error { return err }, nil
+	}
+
+	f, err := createOutputFile(path, strings.HasSuffix(path, ".gz"))
+	if err != nil {
+		return nil, err
+	}
+
+	stdout, colorOutput, noColor := os.Stdout, color.Output, color.NoColor
+	restore := func() {
+		os.Stdout, color.Output, color.NoColor = stdout, colorOutput, noColor
+	}
+
+	// gzipped output is compressed as it's written to stdout through a pipe.
+	var pw *os.File
+	copied := make(chan error, 1)
+	if f.gz == nil {
+		os.Stdout = f.tmp
+	} else {
+		var pr *os.File
+		pr, pw, err = os.Pipe()
+		if err != nil {
+			f.Abort()
+			return nil, err
+		}
+		go func() {
+			_, err := io.Copy(f, pr)
+			pr.Close()
+			copied <- err
+		}()
+		os.Stdout = pw
+	}
+	color.Output = os.Stdout
+	color.NoColor = true
+
+	return func(err error) error {
+		if pw != nil {
+			pw.Close()
+			if cerr := <-copied; err == nil {
+				err = cerr
+			}
+		}
+		restore()
+
+		if err != nil {
+			f.Abort()
+			return err
+		}
+		return f.Commit()
+	}, nil
+}
+
+// appendOutput redirects stdout to the output file at `path`, appending to it
+// rather than replacing it, until the returned function is called with the
+// command's error. It's for --checkpoint, whose resumed runs add the missing
+// results to the output of previous runs, so the file is written directly
+// rather than through a temp file, and can't be gzipped.
+//
+// Returns the file, which is nil if `path` is empty and stdout left as is.
+func appendOutput(path string) (*os.File, func(error) error, error) {
+	if path == "" {
+		return nil, func(err error) error { return err }, nil
+	}
+	if strings.HasSuffix(path, ".gz") {
+		return nil, nil, lib.UsageErrorf("--checkpoint can't be used with a gzipped --output")
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not open %v: %w", path, err)
+	}
+
+	stdout, colorOutput, noColor := os.Stdout, color.Output, color.NoColor
+	os.Stdout, color.Output, color.NoColor = f, f, true
+
+	return f, func(err error) error {
+		os.Stdout, color.Output, color.NoColor = stdout, colorOutput, noColor
+		if serr := f.Sync(); err == nil {
+			err = serr
+		}
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		return err
+	}, nil
+}
+
+// outputFileFormat returns the format of the output file `path` by its
+// extension, ignoring a '.gz' one: "json", "csv", "yaml", "ndjson",
+// "parquet", "html" or "markdown", or "" if it has none of their extensions.
+func outputFileFormat(path string) string {
+	path = strings.TrimSuffix(path, ".gz")
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return "json"
+	case ".csv":
+		return "csv"
+	case ".yaml", ".yml":
+		return "yaml"
+	case ".ndjson", ".jsonl":
+		return "ndjson"
+	case ".parquet":
+		return "parquet"
+	case ".html", ".htm":
+		return "html"
+	case ".md", ".markdown":
+		return "markdown"
+	}
+	return ""
+}
+
+// the flags which choose an output format.
+var outputFormatFlags = []string{
+	"json",
+	"csv",
+	"yaml",
+	"ndjson",
+	"jsonl",
+	"table",
+	"template",
+	"format",
+	"template-file",
+	"columns",
+	"output-format",
+	"html",
+	"markdown",
+}
+
+// inferOutputFormat sets the flag of `formats`, by format name, which matches
+// the extension of the output file `path`, unless a format was chosen by any
+// flag.
+func inferOutputFormat(path string, formats map[string]*bool) {
+	for _, name := range outputFormatFlags {
+		if pflag.CommandLine.Changed(name) {
+			return
+		}
+	}
+	if flag, ok := formats[outputFileFormat(path)]; ok {
+		*flag = true
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Output redirected to a file only replaces it once the command succeeds,
+// gzipped if its name ends in '.gz'.
+func TestRedirectOutput(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out.json")
+	if err := os.WriteFile(path, []byte("old\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	done, err := redirectOutput(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	fmt.Println("partial")
+	if err := done(errors.New("failed")); err == nil || err.Error() != "failed" {
+		t.Errorf("expected the command's error, got %v", err)
+	}
+	if b, _ := os.ReadFile(path); string(b) != "old\n" {
+		t.Errorf("expected the file to be left as is, got %q", b)
+	}
+
+	done, err = redirectOutput(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	fmt.Println("new")
+	if err := done(nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if b, _ := os.ReadFile(path); string(b) != "new\n" {
+		t.Errorf("expected %q, got %q", "new\n", b)
+	}
+	if fi, _ := os.Stat(path); fi.Mode().Perm() != 0600 {
+		t.Errorf("expected the file's mode to be kept, got %v", fi.Mode())
+	}
+
+	gzPath := filepath.Join(dir, "out.csv.gz")
+	done, err = redirectOutput(gzPath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	fmt.Println("a,b")
+	if err := done(nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	f, err := os.Open(gzPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("expected gzipped output: %v", err)
+	}
+	if b, _ := io.ReadAll(gz); string(b) != "a,b\n" {
+		t.Errorf("expected %q, got %q", "a,b\n", b)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 2 {
+		t.Errorf("expected no temp files to be left, got %v", entries)
+	}
+}
+
+func TestOutputFileFormat(t *testing.T) {
+	tests := map[string]string{
+		"out.json":       "json",
+		"out.CSV":        "csv",
+		"out.yml":        "yaml",
+		"out.ndjson.gz":  "ndjson",
+		"out.jsonl":      "ndjson",
+		"out.parquet":    "parquet",
+		"out.txt":        "",
+		"out.gz":         "",
+		"":               "",
+		"dir.csv/report": "",
+	}
+	for path, want := range tests {
+		if got := outputFileFormat(path); got != want {
+			t.Errorf("%q: expected %q, got %q", path, want, got)
+		}
+	}
+}
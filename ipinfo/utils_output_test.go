@@ -0,0 +1,133 @@
+package main
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/ipinfo/go/v2/ipinfo"
+	"github.com/jszwec/csvutil"
+)
+
+// NDJSON with a field selection keeps only those fields and the IP, nested
+// like in the full output.
+func TestOutputNDJSONCoreFields(t *testing.T) {
+	d := &ipinfo.Core{
+		IP:      net.ParseIP("8.8.8.8"),
+		City:    "Mountain View",
+		Country: "US",
+		ASN:     &ipinfo.CoreASN{ASN: "AS15169", Name: "Google LLC"},
+	}
+
+	tests := []struct {
+		fields []string
+		want   string
+	}{
+		{nil, `"city":"Mountain View"`},
+		{
+			[]string{"city", "asn.id", "asn.name", "company.name"},
+			`{"asn":{"asn":"AS15169","name":"Google LLC"},"city":"Mountain View","company":{"name":null},"ip":"8.8.8.8"}` + "\n",
+		},
+	}
+	for _, tt := range tests {
+		var err error
+		stdout, _ := captureStd(t, func() {
+			err = outputNDJSONCore(d, tt.fields)
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if tt.fields == nil {
+			if !strings.HasSuffix(stdout, "\n") || strings.Count(stdout, "\n") != 1 || !strings.Contains(stdout, tt.want) {
+				t.Errorf("expected a single line with %s, got %q", tt.want, stdout)
+			}
+			continue
+		}
+		if stdout != tt.want {
+			t.Errorf("expected %q, got %q", tt.want, stdout)
+		}
+	}
+
+	if err := outputNDJSONCore(d, []string{"nope"}); err == nil {
+		t.Errorf("expected an error for an invalid field")
+	}
+}
+
+// CSV has the same columns whatever data is available, with empty cells for
+// missing objects, and the columns, delimiter and header can be changed.
+func TestOutputBulkResultsCSV(t *testing.T) {
+	ip1, ip2 := net.ParseIP("8.8.8.8"), net.ParseIP("1.1.1.1")
+	data := ipinfo.Batch{
+		"8.8.8.8": &ipinfo.Core{
+			IP:      ip1,
+			Country: "US",
+			Privacy: &ipinfo.CorePrivacy{VPN: true},
+		},
+		"1.1.1.1": &ipinfo.Core{IP: ip2, Country: "AU"},
+	}
+
+	tests := []struct {
+		o    bulkOutputOpts
+		want string
+	}{
+		{
+			bulkOutputOpts{csvFmt: true, columns: []string{"country", "ip", "privacy_vpn"}},
+			"country,ip,privacy_vpn\nUS,8.8.8.8,true\nAU,1.1.1.1,\n",
+		},
+		{
+			bulkOutputOpts{csvFmt: true, columns: []string{"asn_asn", "asn_name"}, noHeader: true},
+			",\n,\n",
+		},
+		{
+			bulkOutputOpts{csvFmt: true, columns: []string{"ip", "asn_id"}, delimiter: '|', noHeader: true},
+			"8.8.8.8|\n1.1.1.1|\n",
+		},
+	}
+	for _, tt := range tests {
+		var err error
+		stdout, _ := captureStd(t, func() {
+			_, err = outputBulkResults([]net.IP{ip1, ip2}, nil, data, tt.o)
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if stdout != tt.want {
+			t.Errorf("expected %q, got %q", tt.want, stdout)
+		}
+	}
+
+	// all columns, whatever data each result has.
+	stdout, _ := captureStd(t, func() {
+		_, err := outputBulkResults([]net.IP{ip1, ip2}, nil, data, bulkOutputOpts{csvFmt: true})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+	lines := strings.Split(strings.TrimSuffix(stdout, "\n"), "\n")
+	if len(lines) != 3 || lines[0] != strings.Join(coreCSVColumns(), ",") {
+		t.Fatalf("unexpected CSV:\n%s", stdout)
+	}
+	for _, line := range lines {
+		if n := strings.Count(line, ","); n != len(coreCSVColumns())-1 {
+			t.Errorf("expected %d columns, got %d: %q", len(coreCSVColumns()), n+1, line)
+		}
+	}
+
+	_, err := outputBulkResults([]net.IP{ip1}, nil, data, bulkOutputOpts{csvFmt: true, columns: []string{"nope"}})
+	if err == nil {
+		t.Errorf("expected an error for an invalid column")
+	}
+}
+
+// the default CSV header is the one CSV had when it was encoded by the csv
+// tags of `ipinfo.Core`.
+func TestCoreCSVColumnsMatchTags(t *testing.T) {
+	want, err := csvutil.Header(ipinfo.Core{}, "csv")
+	if err != nil {
+		t.Fatalf("header: %v", err)
+	}
+	got := coreCSVColumns()
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
@@ -1,9 +1,6 @@
 package lib
 
 import (
-	"errors"
-	"strings"
-
 	"github.com/ipinfo/cli/lib/iputil"
 	"github.com/ipinfo/go/v2/ipinfo"
 	"github.com/spf13/pflag"
@@ -13,10 +10,14 @@ import (
 type CmdASNBulkFlags struct {
 	Token   string
 	nocache bool
-	help    bool
+	Help    bool
 	Field   []string
 	json    bool
 	Yaml    bool
+
+	// BatchOpts are the options of the batch requests; zero values use the
+	// defaults.
+	BatchOpts ipinfo.BatchReqOpts
 }
 
 // Init initializes the common flags available to CmdASNBulk with sensible
@@ -35,7 +36,7 @@ func (f *CmdASNBulkFlags) Init() {
 		_h,
 	)
 	pflag.BoolVarP(
-		&f.help,
+		&f.Help,
 		"help", "h", false,
 		_h,
 	)
@@ -58,33 +59,32 @@ func (f *CmdASNBulkFlags) Init() {
 
 // CmdASNBulk is the entrypoint for the `ipinfo asn-bulk` command.
 func CmdASNBulk(f CmdASNBulkFlags, ii *ipinfo.Client, args []string, printHelp func()) (ipinfo.BatchASNDetails, error) {
-	if f.help {
+	if f.Help {
 		printHelp()
 		return nil, nil
 	}
 
 	var asns []string
 
-	op := func(string string, inputType iputil.INPUT_TYPE) error {
-		switch inputType {
-		case iputil.INPUT_TYPE_ASN:
-			asns = append(asns, strings.ToUpper(string))
-		default:
-			return iputil.ErrInvalidInput
-		}
+	err := iputil.ASNListFuncFromAllSrcs(args, func(asn string) error {
+		asns = append(asns, asn)
 		return nil
-	}
-	err := iputil.GetInputFrom(args, true, true, op)
+	})
 	if err != nil {
 		return nil, err
 	}
 
 	if ii.Token == "" {
-		return nil, errors.New("bulk lookups require a token; login via `ipinfo init`")
+		return nil, AuthErrorf("bulk lookups require a token; login via `ipinfo init`")
 	}
 
-	return ii.GetASNDetailsBatch(asns, ipinfo.BatchReqOpts{
-		TimeoutPerBatch:              60 * 30, // 30min
-		ConcurrentBatchRequestsLimit: 20,
-	})
+	opts := f.BatchOpts
+	if opts.TimeoutPerBatch == 0 {
+		opts.TimeoutPerBatch = 60 * 30 // 30min
+	}
+	if opts.ConcurrentBatchRequestsLimit == 0 {
+		opts.ConcurrentBatchRequestsLimit = 20
+	}
+
+	return ii.GetASNDetailsBatch(asns, opts)
 }
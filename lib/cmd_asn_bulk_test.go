@@ -0,0 +1,15 @@
+package lib
+
+import (
+	"testing"
+
+	"github.com/ipinfo/go/v2/ipinfo"
+)
+
+func TestASNBulkWithoutToken(t *testing.T) {
+	ii := ipinfo.NewClient(nil, nil, "")
+	_, err := CmdASNBulk(CmdASNBulkFlags{}, ii, []string{"AS15169"}, func() {})
+	if got := ErrExitCode(err); got != ExitAuth {
+		t.Errorf("got exit code %v, want %v (%v)", got, ExitAuth, err)
+	}
+}
@@ -0,0 +1,233 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/ipinfo/cli/lib/iputil"
+	"github.com/ipinfo/go/v2/ipinfo"
+	"github.com/spf13/pflag"
+)
+
+// the exit codes of the CLIs.
+const (
+	// ExitOK is the exit code when there's no error.
+	ExitOK = 0
+
+	// ExitError is the exit code of any error not covered by the others.
+	ExitError = 1
+
+	// ExitUsage is the exit code of invalid flags, arguments or input.
+	ExitUsage = 2
+
+	// ExitAuth is the exit code of a missing or invalid token, or one without
+	// access to the API.
+	ExitAuth = 3
+
+	// ExitQuota is the exit code of an exceeded quota or rate limit.
+	ExitQuota = 4
+
+	// ExitNetwork is the exit code of a failure to reach the API.
+	ExitNetwork = 5
+)
+
+// CmdError is an error of a command with the exit code it exits with, and the
+// input it's about, if any.
+type CmdError struct {
+	// the exit code; if 0, the exit code of `Err` is used.
+	Code int
+
+	// the input, e.g. an IP, which the error is about.
+	Input string
+
+	Err error
+}
+
+func (e *CmdError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *CmdError) Unwrap() error {
+	return e.Err
+}
+
+// UsageErrorf returns an error in the usage of a command, like an invalid
+// flag value, formatted like fmt.Errorf.
+func UsageErrorf(format string, a ...interface{}) error {
+	return &CmdError{Code: ExitUsage, Err: fmt.Errorf(format, a...)}
+}
+
+// AuthErrorf returns an error due to a missing or invalid token, formatted
+// like fmt.Errorf.
+func AuthErrorf(format string, a ...interface{}) error {
+	return &CmdError{Code: ExitAuth, Err: fmt.Errorf(format, a...)}
+}
+
+// ErrWithInput returns `err` as being about `input`, or nil if `err` is nil.
+func ErrWithInput(err error, input string) error {
+	if err == nil {
+		return nil
+	}
+	return &CmdError{Input: input, Err: err}
+}
+
+// ErrExitCode returns the exit code of `err`.
+func ErrExitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+
+	// the outermost error with a code decides.
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		if cmdErr, ok := e.(*CmdError); ok && cmdErr.Code != 0 {
+			return cmdErr.Code
+		}
+	}
+
+	var respErr *ipinfo.ErrorResponse
+	if errors.As(err, &respErr) && respErr.Response != nil {
+		switch respErr.Response.StatusCode {
+		case http.StatusUnauthorized, http.StatusForbidden:
+			return ExitAuth
+		case http.StatusTooManyRequests:
+			return ExitQuota
+		}
+		return ExitError
+	}
+
+	var inputErr *iputil.InputError
+	if errors.As(err, &inputErr) {
+		return ExitUsage
+	}
+	for _, inputErr := range []error{
+		iputil.ErrInvalidInput,
+		iputil.ErrNotASN,
+		iputil.ErrNotIP,
+		iputil.ErrNotCIDR,
+		iputil.ErrNotIPRange,
+		iputil.ErrNotIP6Range,
+		iputil.ErrNotFile,
+		iputil.ErrMissingCIDRsOrIPRange,
+		iputil.ErrCannotMixCIDRAndIPs,
+		iputil.ErrIPRangeRequiresTwoIPs,
+	} {
+		if errors.Is(err, inputErr) {
+			return ExitUsage
+		}
+	}
+
+	// not any net.Error, which the syscall.Errno of file errors is too.
+	var urlErr *url.Error
+	var opErr *net.OpError
+	var dnsErr *net.DNSError
+	if errors.As(err, &urlErr) || errors.As(err, &opErr) || errors.As(err, &dnsErr) {
+		return ExitNetwork
+	}
+
+	return ExitError
+}
+
+// ErrInput returns the input `err` is about, or "" if it isn't about one.
+func ErrInput(err error) string {
+	var cmdErr *CmdError
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		if cmdErr, _ = e.(*CmdError); cmdErr != nil && cmdErr.Input != "" {
+			return cmdErr.Input
+		}
+	}
+
+	var inputErr *iputil.InputError
+	if errors.As(err, &inputErr) {
+		return inputErr.Input
+	}
+	return ""
+}
+
+// the format errors are written to stderr in; "text" or "json".
+var errorFormat = "text"
+
+// InitErrorFormat takes the `--error-format <fmt>` flag, which any command
+// accepts, out of os.Args, and sets the format errors are written in to it.
+//
+// In the "json" format, errors in parsing the command's flags are written as
+// JSON too.
+func InitErrorFormat() error {
+	args := []string{os.Args[0]}
+	for i := 1; i < len(os.Args); i++ {
+		arg := os.Args[i]
+		switch {
+		case arg == "--":
+			args = append(args, os.Args[i:]...)
+			i = len(os.Args)
+		case arg == "--error-format":
+			if i+1 == len(os.Args) {
+				return UsageErrorf("flag needs an argument: --error-format")
+			}
+			i++
+			errorFormat = os.Args[i]
+		case strings.HasPrefix(arg, "--error-format="):
+			errorFormat = strings.TrimPrefix(arg, "--error-format=")
+		default:
+			args = append(args, arg)
+		}
+	}
+	os.Args = args
+
+	switch errorFormat {
+	case "text":
+	case "json":
+		// pflag writes the error and then calls the usage func before
+		// exiting, so the error is caught in between.
+		var out bytes.Buffer
+		pflag.CommandLine.SetOutput(&out)
+		pflag.Usage = func() {
+			msg := strings.TrimSpace(out.String())
+			if msg == "" {
+				msg = "flag: help requested"
+			}
+			PrintError(&CmdError{Code: ExitUsage, Err: errors.New(msg)})
+			os.Exit(ExitUsage)
+		}
+	default:
+		v := errorFormat
+		errorFormat = "text"
+		return UsageErrorf(
+			"invalid error format %v; must be 'text' or 'json'", v,
+		)
+	}
+	return nil
+}
+
+// PrintError writes `err` to stderr in the error format, as `err: <msg>` or a
+// JSON object with its message, exit code and input.
+func PrintError(err error) {
+	if errorFormat != "json" {
+		fmt.Fprintf(os.Stderr, "err: %v\n", err)
+		return
+	}
+
+	b, _ := json.Marshal(struct {
+		Error string `json:"error"`
+		Code  int    `json:"code"`
+		Input string `json:"input"`
+	}{
+		Error: err.Error(),
+		Code:  ErrExitCode(err),
+		Input: ErrInput(err),
+	})
+	fmt.Fprintf(os.Stderr, "%s\n", b)
+}
+
+// ExitWithError writes `err` to stderr in the error format and exits with its
+// exit code.
+func ExitWithError(err error) {
+	PrintError(err)
+	os.Exit(ErrExitCode(err))
+}
@@ -0,0 +1,73 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/ipinfo/cli/lib/iputil"
+	"github.com/ipinfo/go/v2/ipinfo"
+)
+
+func TestErrExitCode(t *testing.T) {
+	apiErr := func(status int) error {
+		return &ipinfo.ErrorResponse{
+			Response: &http.Response{StatusCode: status},
+		}
+	}
+	netErr := &url.Error{
+		Op:  "Get",
+		URL: "https://ipinfo.io/8.8.8.8",
+		Err: &net.OpError{Op: "dial", Err: errors.New("connection refused")},
+	}
+
+	_, fileErr := os.Open("does-not-exist")
+	renameErr := os.Rename("does-not-exist", "does-not-exist-either")
+	syscallErr := os.NewSyscallError("fsync", syscall.EBADF)
+
+	cases := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"nil", nil, ExitOK},
+		{"other", errors.New("failed"), ExitError},
+		{"usage", UsageErrorf("invalid sort %v", "x"), ExitUsage},
+		{"invalid input", iputil.ErrInvalidInput, ExitUsage},
+		{"input error", &iputil.InputError{Input: "x", Err: iputil.ErrInvalidInput}, ExitUsage},
+		{"auth", AuthErrorf("no token"), ExitAuth},
+		{"unauthorized", apiErr(http.StatusUnauthorized), ExitAuth},
+		{"forbidden", apiErr(http.StatusForbidden), ExitAuth},
+		{"rate limited", apiErr(http.StatusTooManyRequests), ExitQuota},
+		{"server error", apiErr(http.StatusInternalServerError), ExitError},
+		{"network", netErr, ExitNetwork},
+		{"wrapped network", fmt.Errorf("batch: %w", netErr), ExitNetwork},
+		{"file", fmt.Errorf("error clearing cache: %w", fileErr), ExitError},
+		{"rename", fmt.Errorf("error writing output: %w", renameErr), ExitError},
+		{"syscall", syscallErr, ExitError},
+		{"dns", &net.DNSError{Err: "no such host", Name: "x.invalid"}, ExitNetwork},
+		{"with input", ErrWithInput(apiErr(http.StatusTooManyRequests), "8.8.8.8"), ExitQuota},
+	}
+	for _, c := range cases {
+		if got := ErrExitCode(c.err); got != c.want {
+			t.Errorf("%v: got exit code %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestErrInput(t *testing.T) {
+	if got := ErrInput(ErrWithInput(errors.New("failed"), "8.8.8.8")); got != "8.8.8.8" {
+		t.Errorf("got input %q, want 8.8.8.8", got)
+	}
+	if got := ErrInput(fmt.Errorf("failed: %w", &iputil.InputError{Input: "x", Err: iputil.ErrInvalidInput})); got != "x" {
+		t.Errorf("got input %q, want x", got)
+	}
+	if got := ErrInput(errors.New("failed")); got != "" {
+		t.Errorf("got input %q, want none", got)
+	}
+}
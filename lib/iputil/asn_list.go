@@ -0,0 +1,22 @@
+package iputil
+
+import (
+	"strings"
+)
+
+// ASNListFuncFromAllSrcs passes every ASN from all sources such as stdin,
+// argument list and files to `fn`, normalized to the uppercase "ASX" form.
+//
+// Any input that isn't an ASN is an error.
+func ASNListFuncFromAllSrcs(inputs []string, fn func(string) error) error {
+	op := func(input string, inputType INPUT_TYPE) error {
+		switch inputType {
+		case INPUT_TYPE_ASN:
+			return fn(strings.ToUpper(input))
+		default:
+			return &InputError{Input: input, Err: ErrInvalidInput}
+		}
+	}
+
+	return GetInputFrom(inputs, true, true, op)
+}
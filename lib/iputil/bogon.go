@@ -1,5 +1,9 @@
 package iputil
 
+import (
+	"net"
+)
+
 // these lists are initialized on startup inside this pkg's `init`.
 var BogonIP4List []IPRange
 var BogonIP6List []IP6Range
@@ -115,3 +119,14 @@ func IsBogonIP6(ip6 U128) bool {
 	}
 	return false
 }
+
+// IsBogonIP returns true if the IPv4 or IPv6 `ip` is a BogonIP.
+func IsBogonIP(ip net.IP) bool {
+	if ip4 := ip.To4(); ip4 != nil {
+		return IsBogonIP4(uint32(IPFromStdIP(ip4)))
+	}
+	if ip6, ok := IP6FromStdIP(ip); ok {
+		return IsBogonIP6(ip6.N)
+	}
+	return false
+}
@@ -2,6 +2,7 @@ package iputil
 
 import (
 	"errors"
+	"fmt"
 )
 
 var (
@@ -36,3 +37,20 @@ var (
 	// ErrInvalidInput is returned as a generic error for bad input.
 	ErrInvalidInput = errors.New("invalid input")
 )
+
+// InputError is returned when a specific input is invalid.
+type InputError struct {
+	// the invalid input.
+	Input string
+
+	// the reason it's invalid, e.g. ErrInvalidInput.
+	Err error
+}
+
+func (e *InputError) Error() string {
+	return fmt.Sprintf("%v %q", e.Err, e.Input)
+}
+
+func (e *InputError) Unwrap() error {
+	return e.Err
+}
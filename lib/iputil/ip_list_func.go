@@ -0,0 +1,172 @@
+package iputil
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"os"
+	"strings"
+)
+
+// IPListFuncFromAllSrcs is the same as IPListFromAllSrcs with O(1) memory by
+// passing each IP to `fn` as soon as it is found instead of collecting them.
+//
+// Iteration stops at the first error returned by `fn`, also for IPs found in
+// files, whose errors GetInputFrom ignores.
+//
+// Inputs which aren't IPs, IP ranges or CIDRs are passed to `skip` if it's
+// not nil, rather than being ignored silently.
+func IPListFuncFromAllSrcs(
+	inputs []string,
+	fn func(net.IP) error,
+	skip func(input string),
+) error {
+	op := func(input string, inputType INPUT_TYPE) error {
+		switch inputType {
+		case INPUT_TYPE_IP:
+			return fn(net.ParseIP(input))
+		case INPUT_TYPE_IP_RANGE:
+			return IPListFuncFromIPRangeStr(input, fn)
+		case INPUT_TYPE_CIDR:
+			return IPListFuncFromCIDR(input, fn)
+		}
+		if skip != nil {
+			skip(input)
+		}
+		return nil
+	}
+
+	return getInputFrom(inputs, true, true, true, op)
+}
+
+// IPListFuncFromCIDR is the same as IPListFromCIDR with O(1) memory by
+// passing each IP to `fn` instead of collecting them.
+func IPListFuncFromCIDR(cidrStr string, fn func(net.IP) error) error {
+	_, ipnet, err := net.ParseCIDR(cidrStr)
+	if err != nil {
+		return err
+	}
+
+	mask := binary.BigEndian.Uint32(ipnet.Mask)
+	start := binary.BigEndian.Uint32(ipnet.IP)
+	end := (start & mask) | (mask ^ 0xffffffff)
+
+	for i := start; i <= end; i++ {
+		ip := make(net.IP, 4)
+		binary.BigEndian.PutUint32(ip, i)
+		if err := fn(ip); err != nil {
+			return err
+		}
+
+		// prevent overflow when the CIDR ends at 255.255.255.255.
+		if i == end {
+			break
+		}
+	}
+
+	return nil
+}
+
+// IPListFuncFromIPRange is the same as IPListFromRange with O(1) memory by
+// passing each IP to `fn` instead of collecting them.
+func IPListFuncFromIPRange(
+	ipStrStart string,
+	ipStrEnd string,
+	fn func(net.IP) error,
+) error {
+	var ipStart, ipEnd net.IP
+
+	if ipStart = net.ParseIP(ipStrStart); ipStart == nil {
+		return ErrNotIP
+	}
+	if ipEnd = net.ParseIP(ipStrEnd); ipEnd == nil {
+		return ErrNotIP
+	}
+
+	start := binary.BigEndian.Uint32(ipStart.To4())
+	end := binary.BigEndian.Uint32(ipEnd.To4())
+
+	// iterate in decreasing order if range is flipped.
+	step := uint32(1)
+	if start > end {
+		step = ^uint32(0)
+	}
+	for i := start; ; i += step {
+		ip := make(net.IP, 4)
+		binary.BigEndian.PutUint32(ip, i)
+		if err := fn(ip); err != nil {
+			return err
+		}
+		if i == end {
+			break
+		}
+	}
+
+	return nil
+}
+
+// IPListFuncFromIPRangeStr passes all IPs in an IP range to `fn`.
+//
+// `rStr` must be of any of these forms:
+//
+//	<ip_range_start>-<ip_range_end>
+//	<ip_range_start>,<ip_range_end>
+func IPListFuncFromIPRangeStr(rStr string, fn func(net.IP) error) error {
+	r, err := IPRangeStrFromStr(rStr)
+	if err != nil {
+		return err
+	}
+
+	return IPListFuncFromIPRange(r.Start, r.End, fn)
+}
+
+// IPListFuncFromReader is the same as IPListFromReader with O(1) memory by
+// passing each IP to `fn` instead of collecting them.
+func IPListFuncFromReader(
+	r io.Reader,
+	breakOnEmptyLine bool,
+	fn func(net.IP) error,
+) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		ipStr := strings.TrimSpace(scanner.Text())
+		if ipStr == "" {
+			if breakOnEmptyLine {
+				break
+			}
+			continue
+		}
+
+		if StrIsIPRangeStr(ipStr) {
+			if err := IPListFuncFromIPRangeStr(ipStr, fn); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if StrIsIPStr(ipStr) {
+			if err := fn(net.ParseIP(ipStr)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if StrIsCIDRStr(ipStr) {
+			if err := IPListFuncFromCIDR(ipStr, fn); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// simply ignore anything else.
+	}
+
+	return scanner.Err()
+}
+
+// IPListFuncFromStdin passes all IPs from stdin to `fn`; the IPs should be 1
+// per line.
+func IPListFuncFromStdin(fn func(net.IP) error) error {
+	return IPListFuncFromReader(os.Stdin, true, fn)
+}
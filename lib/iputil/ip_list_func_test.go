@@ -0,0 +1,49 @@
+package iputil_test
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ipinfo/cli/lib/iputil"
+	"github.com/stretchr/testify/assert"
+)
+
+// Iteration over a file stops at the first error of `fn`, and inputs that
+// aren't IPs, ranges or CIDRs are skipped.
+func TestIPListFuncFromAllSrcs(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "ips.txt")
+	err := os.WriteFile(path, []byte("1.1.1.1\nlocalhost\n2.2.2.2\n3.3.3.3\n"), 0644)
+	if err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	// no input from stdin.
+	stdin, err := os.Create(filepath.Join(dir, "stdin"))
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	defer stdin.Close()
+	origStdin := os.Stdin
+	os.Stdin = stdin
+	defer func() { os.Stdin = origStdin }()
+
+	var got []string
+	var skipped []string
+	errStop := errors.New("stop")
+	err = iputil.IPListFuncFromAllSrcs([]string{path, "4.4.4.4"}, func(ip net.IP) error {
+		got = append(got, ip.String())
+		if ip.String() == "2.2.2.2" {
+			return errStop
+		}
+		return nil
+	}, func(input string) {
+		skipped = append(skipped, input)
+	})
+	assert.Equal(t, errStop, err)
+	assert.Equal(t, []string{"1.1.1.1", "2.2.2.2"}, got)
+	assert.Equal(t, []string{"localhost"}, skipped)
+}
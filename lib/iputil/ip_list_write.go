@@ -67,7 +67,7 @@ func IPListWriteFrom(
 				continue
 			}
 		}
-		return ErrInvalidInput
+		return &InputError{Input: input, Err: ErrInvalidInput}
 	}
 
 	return nil
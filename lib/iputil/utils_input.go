@@ -52,6 +52,18 @@ func GetInputFrom(
 	stdin bool,
 	file bool,
 	op func(input string, inputType INPUT_TYPE) error,
+) error {
+	return getInputFrom(inputs, stdin, file, false, op)
+}
+
+// getInputFrom is GetInputFrom, which also stops at the first error of `op`
+// for a line of a file if `stopOnFileErr` is set, rather than ignoring it.
+func getInputFrom(
+	inputs []string,
+	stdin bool,
+	file bool,
+	stopOnFileErr bool,
+	op func(input string, inputType INPUT_TYPE) error,
 ) error {
 	if !stdin && len(inputs) == 0 {
 		return nil
@@ -88,7 +100,7 @@ func GetInputFrom(
 		case StrIsCIDRStr(input):
 			err = op(input, INPUT_TYPE_CIDR)
 		case file && FileExists(input):
-			err = ProcessStringsFromFile(input, op)
+			err = processStringsFromFile(input, stopOnFileErr, op)
 		case StrIsASNStr(input):
 			err = op(input, INPUT_TYPE_ASN)
 		default:
@@ -103,6 +115,16 @@ func GetInputFrom(
 
 // ProcessStringsFromFile reads strings from a file and passes it to op, one per line.
 func ProcessStringsFromFile(filename string, op func(input string, inputType INPUT_TYPE) error) error {
+	return processStringsFromFile(filename, false, op)
+}
+
+// processStringsFromFile is ProcessStringsFromFile, which also stops at the
+// first error of `op` if `stopOnErr` is set, rather than ignoring it.
+func processStringsFromFile(
+	filename string,
+	stopOnErr bool,
+	op func(input string, inputType INPUT_TYPE) error,
+) error {
 	file, err := os.Open(filename)
 	if err != nil {
 		return err
@@ -119,6 +141,9 @@ func ProcessStringsFromFile(filename string, op func(input string, inputType INP
 
 	for scanner.Scan() {
 		err = InputHelper(scanner.Text(), op)
+		if err != nil && stopOnErr {
+			return err
+		}
 	}
 
 	if err := scanner.Err(); err != nil {
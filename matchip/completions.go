@@ -7,9 +7,10 @@ import (
 
 var completions = &complete.Command{
 	Flags: map[string]complete.Predictor{
-		"-e":           predict.Nothing,
-		"--expression": predict.Nothing,
-		"--help":       predict.Nothing,
+		"-e":             predict.Nothing,
+		"--expression":   predict.Nothing,
+		"--error-format": predict.Set([]string{"text", "json"}),
+		"--help":         predict.Nothing,
 	},
 }
 
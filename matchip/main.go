@@ -44,6 +44,9 @@ Options:
   General:
     --version, -v
       show binary release number.
+    --error-format <fmt>
+      write errors to stderr as 'text' or 'json'.
+      default: text.
     --help, -h
       show help.
 
@@ -139,7 +142,10 @@ func main() {
 
 	handleCompletions()
 
+	if err := lib.InitErrorFormat(); err != nil {
+		lib.ExitWithError(err)
+	}
 	if err := cmd(); err != nil {
-		fmt.Fprintf(os.Stderr, "err: %v\n", err)
+		lib.ExitWithError(err)
 	}
 }
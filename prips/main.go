@@ -38,6 +38,9 @@ Examples:
   $ echo -e '1.1.1.0/30\n8.8.8.0-8.8.8.255\n7.7.7.0,7.7.7.10' | %[1]s
 
 Options:
+  --error-format <fmt>
+    write errors to stderr as 'text' or 'json'.
+    default: text.
   --help, -h
     show help.
 `, progBase)
@@ -123,7 +126,10 @@ func main() {
 
 	handleCompletions()
 
+	if err := lib.InitErrorFormat(); err != nil {
+		lib.ExitWithError(err)
+	}
 	if err := cmd(); err != nil {
-		fmt.Fprintf(os.Stderr, "err: %v\n", err)
+		lib.ExitWithError(err)
 	}
 }
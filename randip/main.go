@@ -42,6 +42,9 @@ Description:
   $ %[1]s -6 --end eedd:8977:56d9:aac3:947b:29cc:78ea:deab
 
 Options:
+  --error-format <fmt>
+    write errors to stderr as 'text' or 'json'.
+    default: text.
   --help, -h
     show help.
   --num, -n 
@@ -154,7 +157,10 @@ func main() {
 
 	handleCompletions()
 
+	if err := lib.InitErrorFormat(); err != nil {
+		lib.ExitWithError(err)
+	}
 	if err := cmd(); err != nil {
-		fmt.Fprintf(os.Stderr, "err: %v\n", err)
+		lib.ExitWithError(err)
 	}
 }
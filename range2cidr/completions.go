@@ -10,6 +10,7 @@ var completions = &complete.Command{
 		"-v":                    predict.Nothing,
 		"--version":             predict.Nothing,
 		"-h":                    predict.Nothing,
+		"--error-format":        predict.Set([]string{"text", "json"}),
 		"--help":                predict.Nothing,
 		"--completions-install": predict.Nothing,
 		"--completions-bash":    predict.Nothing,
@@ -24,6 +24,9 @@ Description:
   $ %[1]s 8.8.8.0/24 25
 
 Options:
+  --error-format <fmt>
+    write errors to stderr as 'text' or 'json'.
+    default: text.
   --help, -h
     show help.
 
@@ -119,7 +122,10 @@ func main() {
 
 	handleCompletions()
 
+	if err := lib.InitErrorFormat(); err != nil {
+		lib.ExitWithError(err)
+	}
 	if err := cmd(); err != nil {
-		fmt.Fprintf(os.Stderr, "err: %v\n", err)
+		lib.ExitWithError(err)
 	}
 }